@@ -53,10 +53,18 @@ const SubredditDashboard = () => {
 
   const fetchSubreddits = async () => {
     try {
-      const response = await fetch(`${API_BASE_URL}/subreddits/`);
-      const data = await response.json();
-      if (fetchedSubredditsRef.current.length !== data.length) {
-        setSubreddits(data);
+      // Follow the next cursors until every page of subreddits is loaded
+      const fetched: Subreddit[] = [];
+      let cursor: string | undefined;
+      do {
+        const query = cursor ? `?cursor=${encodeURIComponent(cursor)}` : "";
+        const response = await fetch(`${API_BASE_URL}/subreddits/${query}`);
+        const data = await response.json();
+        fetched.push(...data.subreddits);
+        cursor = data.next;
+      } while (cursor);
+      if (fetchedSubredditsRef.current.length !== fetched.length) {
+        setSubreddits(fetched);
       }
     } catch (error) {
       console.error("Error fetching subreddits:", error);
@@ -71,7 +79,7 @@ const SubredditDashboard = () => {
           "Content-Type": "application/json",
         },
         body: JSON.stringify({
-          subreddit: { name: subredditName, sortBy: timeRange },
+          subreddit: { name: subredditName, listing: "top", timeWindow: timeRange },
         }),
       });
       if (!response.ok) {
@@ -93,7 +101,8 @@ const SubredditDashboard = () => {
           "Content-Type": "application/json",
         },
         body: JSON.stringify({
-          sortBy: timeRange,
+          listing: "top",
+          timeWindow: timeRange,
         }),
       });
       if (!response.ok) {
@@ -120,13 +129,14 @@ const SubredditDashboard = () => {
             "Content-Type": "application/json",
           },
           body: JSON.stringify({
-            subreddit: { name: newSubreddit, sortBy: timeRange },
+            subreddit: { name: newSubreddit, listing: "top", timeWindow: timeRange },
           }),
         });
+        const data = await response.json();
         if (!response.ok) {
-          throw new Error(`Response status: ${response.status}`);
+          toast.error(data.detail ?? `Failed to add r/${newSubreddit}`);
+          return;
         }
-        const data = await response.json();
         const addedSubreddit: Subreddit = {
           name: data.Name,
           numberOfSubscribers: data.NumberOfSubscribers,
@@ -27,6 +27,7 @@ interface SubredditPostsProps {
 
 const SubredditPosts = ({ selectedSubreddit }: SubredditPostsProps) => {
   const [posts, setPosts] = useState<Post[]>([]);
+  const [nextCursor, setNextCursor] = useState<string | undefined>();
   const [subredditName, setSubredditName] = useState("");
   const [loading, setLoading] = useState(false);
 
@@ -37,15 +38,18 @@ const SubredditPosts = ({ selectedSubreddit }: SubredditPostsProps) => {
     }
   }, [selectedSubreddit]);
 
-  const fetchPosts = async (subreddit: string) => {
+  // fetchPosts loads the first page of posts, or the page after cursor appended to the loaded ones
+  const fetchPosts = async (subreddit: string, cursor?: string) => {
     setLoading(true);
     try {
-      const response = await fetch(`${API_BASE_URL}/subreddits/${subreddit}`);
+      const query = cursor ? `?cursor=${encodeURIComponent(cursor)}` : "";
+      const response = await fetch(`${API_BASE_URL}/subreddits/${subreddit}${query}`);
       if (!response.ok) {
         throw new Error(`HTTP error! status: ${response.status}`);
       }
       const data = await response.json();
-      setPosts(data);
+      setPosts(cursor ? [...posts, ...data.posts] : data.posts);
+      setNextCursor(data.next);
     } catch (error) {
       console.error("Error fetching posts:", error);
       toast.error(`Failed to fetch posts from r/${subreddit}`);
@@ -111,6 +115,16 @@ const SubredditPosts = ({ selectedSubreddit }: SubredditPostsProps) => {
           ) : (
             <p>Nothing to display. Enter a subreddit name to view posts</p>
           )}
+          {nextCursor && (
+            <Button
+              variant="outline"
+              className="mt-4 w-full"
+              disabled={loading}
+              onClick={() => fetchPosts(subredditName, nextCursor)}
+            >
+              {loading ? "Loading..." : "Load more"}
+            </Button>
+          )}
         </CardContent>
       </Card>
     </div>
@@ -2,24 +2,80 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/samratjha96/hecate/internal/database"
 	"github.com/samratjha96/hecate/internal/hecate"
+	"github.com/samratjha96/hecate/internal/reddit"
+	"github.com/samratjha96/hecate/internal/searchquery"
 )
 
 const (
-	statusOK       = http.StatusOK
-	statusCreated  = http.StatusCreated
-	statusBadReq   = http.StatusBadRequest
-	statusIntError = http.StatusInternalServerError
+	statusOK              = http.StatusOK
+	statusCreated         = http.StatusCreated
+	statusAccepted        = http.StatusAccepted
+	statusBadReq          = http.StatusBadRequest
+	statusForbidden       = http.StatusForbidden
+	statusNotFound        = http.StatusNotFound
+	statusConflict        = http.StatusConflict
+	statusTooManyRequests = http.StatusTooManyRequests
+	statusIntError        = http.StatusInternalServerError
+	statusBadGateway      = http.StatusBadGateway
 )
 
+const (
+	// defaultPageLimit is the page size of listings that are not given a limit
+	defaultPageLimit = 25
+	maxPageLimit     = 100
+)
+
+// ingestErrorResponse maps an ingestion error to an HTTP status and a machine-readable error code.
+// Codes for Reddit refusing a subscription name its kind, which defaults to a subreddit.
+func ingestErrorResponse(err error, kind string) (int, string) {
+	// refused is what Reddit refused: a subreddit, a user, or the subreddit a saved search is restricted to
+	refused := "subreddit"
+	switch kind {
+	case hecate.KindSearch:
+		refused = "search_subreddit"
+	case hecate.KindUser:
+		refused = "user"
+	}
+
+	switch {
+	case errors.Is(err, hecate.ErrInvalidRequest):
+		return statusBadReq, "invalid_request"
+	case errors.Is(err, reddit.ErrInvalidName):
+		return statusBadReq, "invalid_subreddit_name"
+	case errors.Is(err, reddit.ErrInvalidUsername):
+		return statusBadReq, "invalid_username"
+	case errors.Is(err, reddit.ErrNotFound):
+		return statusNotFound, refused + "_not_found"
+	case errors.Is(err, reddit.ErrPrivate):
+		return statusForbidden, refused + "_private"
+	case errors.Is(err, reddit.ErrBanned):
+		return statusForbidden, refused + "_banned"
+	case errors.Is(err, reddit.ErrQuarantined):
+		return statusForbidden, refused + "_quarantined"
+	case errors.Is(err, reddit.ErrRateLimited):
+		return statusTooManyRequests, "rate_limited"
+	case errors.Is(err, reddit.ErrUpstreamUnavailable):
+		return statusBadGateway, "upstream_unavailable"
+	default:
+		return statusIntError, "internal_error"
+	}
+}
+
 // ingestSubredditHandler handles the ingestion of a single subreddit
-func ingestSubredditHandler(db *database.DB) http.HandlerFunc {
+func ingestSubredditHandler(db database.Store, client *reddit.Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var subreddit hecate.SubscribeFrontendRequest
 		if err := decodeJSONBody(w, r, &subreddit); err != nil {
@@ -28,10 +84,11 @@ func ingestSubredditHandler(db *database.DB) http.HandlerFunc {
 		}
 
 		log.Printf("Ingesting subreddit: %s", subreddit.Subreddit.Name)
-		subscriptions, err := hecate.IngestSubreddit(r.Context(), db, subreddit.Subreddit)
+		subscriptions, err := hecate.IngestSubreddit(r.Context(), db, client, subreddit.Subreddit)
 		if err != nil {
 			log.Printf("Failed to ingest subreddit %s: %v", subreddit.Subreddit.Name, err)
-			respondWithError(w, statusIntError, fmt.Sprintf("Failed to ingest subreddit: %v", err))
+			status, errorCode := ingestErrorResponse(err, hecate.KindSubreddit)
+			respondWithError(w, r, status, errorCode, fmt.Sprintf("Failed to ingest subreddit: %v", err))
 			return
 		}
 
@@ -40,8 +97,8 @@ func ingestSubredditHandler(db *database.DB) http.HandlerFunc {
 	}
 }
 
-// ingestAllSubredditsHandler handles the ingestion of all subreddits
-func ingestAllSubredditsHandler(db *database.DB) http.HandlerFunc {
+// ingestAllSubredditsHandler handles enqueueing a job that ingests every subscription
+func ingestAllSubredditsHandler(jobs *hecate.JobRunner) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var request hecate.IngestAllFrontendRequest
 		if err := decodeJSONBody(w, r, &request); err != nil {
@@ -49,95 +106,531 @@ func ingestAllSubredditsHandler(db *database.DB) http.HandlerFunc {
 			return
 		}
 
-		log.Printf("Ingesting all subreddits with sort: %s", request.SortBy)
-		if err := hecate.IngestAllSubreddit(r.Context(), db, request.SortBy); err != nil {
-			log.Printf("Failed to ingest all subreddits: %v", err)
-			respondWithError(w, statusIntError, fmt.Sprintf("Failed to ingest all subreddits: %v", err))
+		log.Printf("Enqueueing ingestion of all subreddits with listing: %s, time window: %s", request.Listing, request.ResolvedTimeWindow())
+		job, err := jobs.EnqueueIngestAll(request)
+		if err != nil {
+			log.Printf("Failed to enqueue ingestion of all subreddits: %v", err)
+			respondWithError(w, r, statusIntError, "internal_error", fmt.Sprintf("Failed to enqueue ingestion of all subreddits: %v", err))
 			return
 		}
 
-		log.Println("Successfully ingested all subreddits")
-		respondWithJson(w, statusOK, map[string]string{"status": "success"})
+		w.Header().Set("Location", fmt.Sprintf("/api/jobs/%d", job.Id))
+		respondWithJson(w, statusAccepted, job)
 	}
 }
 
-// subredditGetHandler handles retrieving all subreddits
-func subredditGetHandler(db *database.DB) http.HandlerFunc {
+// subredditGetHandler handles retrieving a page of subreddits
+func subredditGetHandler(db database.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		log.Println("Retrieving all subreddits")
-		subreddits, err := hecate.GetAllSubreddits(db)
+		pagination, ok := parsePagination(w, r)
+		if !ok {
+			return
+		}
+
+		log.Println("Retrieving subreddits")
+		response, err := hecate.GetSubreddits(db, pagination)
+		if errors.Is(err, database.ErrInvalidCursor) {
+			respondWithError(w, r, statusBadReq, "invalid_cursor", "Cursor must be the next cursor of a previous page")
+			return
+		}
 		if err != nil {
 			log.Printf("Failed to retrieve subreddits: %v", err)
-			respondWithError(w, statusIntError, fmt.Sprintf("Failed to retrieve subreddits: %v", err))
+			respondWithError(w, r, statusIntError, "internal_error", fmt.Sprintf("Failed to retrieve subreddits: %v", err))
 			return
 		}
-		log.Printf("Retrieved %d subreddits", len(subreddits))
-		respondWithJson(w, statusOK, subreddits)
+		setNextLink(w, r, pagination, response.Next)
+		respondWithJson(w, statusOK, response)
 	}
 }
 
-// subredditPostsGetHandler handles retrieving posts for a specific subreddit
-func subredditPostsGetHandler(db *database.DB) http.HandlerFunc {
+// subredditPostsGetHandler handles retrieving a page of posts for a specific subreddit
+func subredditPostsGetHandler(db database.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		pagination, ok := parsePagination(w, r)
+		if !ok {
+			return
+		}
+
+		query, ok := parsePostQuery(w, r)
+		if !ok {
+			return
+		}
+
 		subredditName := chi.URLParam(r, "subredditName")
 		log.Printf("Retrieving posts for subreddit: %s", subredditName)
-		posts, err := hecate.GetAllPostsForSubreddit(db, subredditName)
+		response, err := hecate.GetPostsForSubreddit(db, subredditName, query, pagination)
+		if errors.Is(err, database.ErrInvalidCursor) {
+			respondWithError(w, r, statusBadReq, "invalid_cursor", "Cursor must be the next cursor of a previous page with the same sort")
+			return
+		}
 		if err != nil {
 			log.Printf("Failed to retrieve posts for subreddit %s: %v", subredditName, err)
-			respondWithError(w, statusIntError, fmt.Sprintf("Failed to retrieve posts: %v", err))
+			respondWithError(w, r, statusIntError, "internal_error", fmt.Sprintf("Failed to retrieve posts: %v", err))
 			return
 		}
-		log.Printf("Retrieved %d posts for subreddit: %s", len(posts), subredditName)
-		respondWithJson(w, statusOK, posts)
+		setNextLink(w, r, pagination, response.Next)
+		respondWithJson(w, statusOK, response)
 	}
 }
 
-// searchPostsHandler handles searching posts across all subreddits
-func searchPostsHandler(db *database.DB) http.HandlerFunc {
+// ingestSearchHandler handles saving a search query and ingesting its results
+func ingestSearchHandler(db database.Store, client *reddit.Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		query := r.URL.Query().Get("q")
-		if query == "" {
-			respondWithError(w, statusBadReq, "Search query is required")
+		var request hecate.SearchSubscribeFrontendRequest
+		if err := decodeJSONBody(w, r, &request); err != nil {
+			log.Printf("Failed to decode request body: %v", err)
 			return
 		}
 
-		log.Printf("Searching posts with query: %s", query)
-		posts, err := db.SearchPosts(query)
+		log.Printf("Ingesting saved search: %q", request.Search.Query)
+		search, err := hecate.IngestSearch(r.Context(), db, client, request.Search)
 		if err != nil {
-			log.Printf("Failed to search posts: %v", err)
-			respondWithError(w, statusIntError, fmt.Sprintf("Failed to search posts: %v", err))
+			log.Printf("Failed to ingest saved search %q: %v", request.Search.Query, err)
+			status, errorCode := ingestErrorResponse(err, hecate.KindSearch)
+			respondWithError(w, r, status, errorCode, fmt.Sprintf("Failed to ingest saved search: %v", err))
 			return
 		}
 
-		response := hecate.SearchPostsResponse{
-			Posts: make([]hecate.SubredditPostFrontendResponse, len(posts)),
+		log.Printf("Successfully ingested saved search %d: %q", search.Id, search.Query)
+		respondWithJson(w, statusCreated, search)
+	}
+}
+
+// savedSearchesGetHandler handles retrieving all saved searches
+func savedSearchesGetHandler(db database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Println("Retrieving all saved searches")
+		searches, err := hecate.GetSavedSearches(db)
+		if err != nil {
+			log.Printf("Failed to retrieve saved searches: %v", err)
+			respondWithError(w, r, statusIntError, "internal_error", fmt.Sprintf("Failed to retrieve saved searches: %v", err))
+			return
 		}
-		for i, post := range posts {
-			response.Posts[i] = hecate.SubredditPostFrontendResponse{
-				Title:         post.Title,
-				Content:       post.Content,
-				DiscussionURL: post.DiscussionURL,
-				CommentCount:  post.CommentCount,
-				Upvotes:       post.Upvotes,
-				SubredditName: post.SubredditName,
-			}
+		respondWithJson(w, statusOK, searches)
+	}
+}
+
+// savedSearchPostsGetHandler handles retrieving a page of posts ingested through a saved search
+func savedSearchPostsGetHandler(db database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		searchId, err := strconv.ParseInt(chi.URLParam(r, "searchId"), 10, 64)
+		if err != nil {
+			respondWithError(w, r, statusBadReq, "invalid_id", "Saved search id must be a number")
+			return
+		}
+		pagination, ok := parsePagination(w, r)
+		if !ok {
+			return
+		}
+
+		log.Printf("Retrieving posts for saved search: %d", searchId)
+		response, err := hecate.GetPostsForSavedSearch(db, searchId, pagination)
+		if errors.Is(err, database.ErrNotFound) {
+			respondWithError(w, r, statusNotFound, "search_not_found", fmt.Sprintf("Saved search not found: %d", searchId))
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to retrieve posts for saved search %d: %v", searchId, err)
+			respondWithError(w, r, statusIntError, "internal_error", fmt.Sprintf("Failed to retrieve posts: %v", err))
+			return
+		}
+		setNextLink(w, r, pagination, response.Next)
+		respondWithJson(w, statusOK, response)
+	}
+}
+
+// ingestUserHandler handles following a Reddit user and ingesting their posts
+func ingestUserHandler(db database.Store, client *reddit.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request hecate.UserSubscribeFrontendRequest
+		if err := decodeJSONBody(w, r, &request); err != nil {
+			log.Printf("Failed to decode request body: %v", err)
+			return
+		}
+
+		log.Printf("Ingesting followed user: %s", request.User.Name)
+		user, err := hecate.IngestUser(r.Context(), db, client, request.User)
+		if err != nil {
+			log.Printf("Failed to ingest followed user %s: %v", request.User.Name, err)
+			status, errorCode := ingestErrorResponse(err, hecate.KindUser)
+			respondWithError(w, r, status, errorCode, fmt.Sprintf("Failed to ingest followed user: %v", err))
+			return
+		}
+
+		log.Printf("Successfully ingested followed user: %s", user.Name)
+		respondWithJson(w, statusCreated, user)
+	}
+}
+
+// followedUsersGetHandler handles retrieving all followed users
+func followedUsersGetHandler(db database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Println("Retrieving all followed users")
+		users, err := hecate.GetFollowedUsers(db)
+		if err != nil {
+			log.Printf("Failed to retrieve followed users: %v", err)
+			respondWithError(w, r, statusIntError, "internal_error", fmt.Sprintf("Failed to retrieve followed users: %v", err))
+			return
+		}
+		respondWithJson(w, statusOK, users)
+	}
+}
+
+// userPostsGetHandler handles retrieving a page of posts submitted by a followed user
+func userPostsGetHandler(db database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pagination, ok := parsePagination(w, r)
+		if !ok {
+			return
+		}
+
+		username := chi.URLParam(r, "username")
+		log.Printf("Retrieving posts for followed user: %s", username)
+		response, err := hecate.GetPostsForUser(db, username, pagination)
+		if errors.Is(err, database.ErrNotFound) {
+			respondWithError(w, r, statusNotFound, "user_not_found", fmt.Sprintf("Followed user not found: %s", username))
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to retrieve posts for followed user %s: %v", username, err)
+			respondWithError(w, r, statusIntError, "internal_error", fmt.Sprintf("Failed to retrieve posts: %v", err))
+			return
+		}
+		setNextLink(w, r, pagination, response.Next)
+		respondWithJson(w, statusOK, response)
+	}
+}
+
+// scheduleErrorResponse maps a schedule error to an HTTP status and a machine-readable error code
+func scheduleErrorResponse(err error) (int, string) {
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		return statusNotFound, "not_found"
+	case errors.Is(err, hecate.ErrScheduleRunning):
+		return statusConflict, "schedule_running"
+	default:
+		return ingestErrorResponse(err, "")
+	}
+}
+
+// schedulesGetHandler handles retrieving all ingestion schedules
+func schedulesGetHandler(scheduler *hecate.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Println("Retrieving all schedules")
+		schedules, err := scheduler.Schedules()
+		if err != nil {
+			log.Printf("Failed to retrieve schedules: %v", err)
+			respondWithError(w, r, statusIntError, "internal_error", fmt.Sprintf("Failed to retrieve schedules: %v", err))
+			return
+		}
+		respondWithJson(w, statusOK, schedules)
+	}
+}
+
+// scheduleConfigureHandler handles creating or updating the schedule of a subscription listing
+func scheduleConfigureHandler(scheduler *hecate.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request hecate.ScheduleFrontendRequest
+		if err := decodeJSONBody(w, r, &request); err != nil {
+			log.Printf("Failed to decode request body: %v", err)
+			return
+		}
+
+		schedule, err := scheduler.Configure(request)
+		if err != nil {
+			log.Printf("Failed to configure %s schedule for %s: %v", request.Kind, request.Name, err)
+			status, errorCode := scheduleErrorResponse(err)
+			respondWithError(w, r, status, errorCode, fmt.Sprintf("Failed to configure schedule: %v", err))
+			return
+		}
+		respondWithJson(w, statusOK, schedule)
+	}
+}
+
+// schedulePauseHandler handles pausing or resuming a schedule
+func schedulePauseHandler(scheduler *hecate.Scheduler, paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scheduleId, err := strconv.ParseInt(chi.URLParam(r, "scheduleId"), 10, 64)
+		if err != nil {
+			respondWithError(w, r, statusBadReq, "invalid_id", "Schedule id must be a number")
+			return
+		}
+
+		schedule, err := scheduler.SetPaused(scheduleId, paused)
+		if err != nil {
+			log.Printf("Failed to update schedule %d: %v", scheduleId, err)
+			status, errorCode := scheduleErrorResponse(err)
+			respondWithError(w, r, status, errorCode, fmt.Sprintf("Failed to update schedule: %v", err))
+			return
+		}
+		respondWithJson(w, statusOK, schedule)
+	}
+}
+
+// scheduleTriggerHandler handles running a schedule immediately
+func scheduleTriggerHandler(scheduler *hecate.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scheduleId, err := strconv.ParseInt(chi.URLParam(r, "scheduleId"), 10, 64)
+		if err != nil {
+			respondWithError(w, r, statusBadReq, "invalid_id", "Schedule id must be a number")
+			return
+		}
+
+		log.Printf("Triggering schedule: %d", scheduleId)
+		schedule, err := scheduler.Trigger(scheduleId)
+		if err != nil {
+			log.Printf("Failed to trigger schedule %d: %v", scheduleId, err)
+			status, errorCode := scheduleErrorResponse(err)
+			respondWithError(w, r, status, errorCode, fmt.Sprintf("Failed to trigger schedule: %v", err))
+			return
+		}
+		respondWithJson(w, statusAccepted, schedule)
+	}
+}
+
+// jobsGetHandler handles retrieving the most recent jobs
+func jobsGetHandler(jobs *hecate.JobRunner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Println("Retrieving jobs")
+		response, err := jobs.Jobs()
+		if err != nil {
+			log.Printf("Failed to retrieve jobs: %v", err)
+			respondWithError(w, r, statusIntError, "internal_error", fmt.Sprintf("Failed to retrieve jobs: %v", err))
+			return
+		}
+		respondWithJson(w, statusOK, response)
+	}
+}
+
+// jobGetHandler handles retrieving the state, progress and result of a job
+func jobGetHandler(jobs *hecate.JobRunner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobId, err := strconv.ParseInt(chi.URLParam(r, "jobId"), 10, 64)
+		if err != nil {
+			respondWithError(w, r, statusBadReq, "invalid_id", "Job id must be a number")
+			return
+		}
+
+		job, err := jobs.Job(jobId)
+		if errors.Is(err, database.ErrNotFound) {
+			respondWithError(w, r, statusNotFound, "job_not_found", fmt.Sprintf("Job not found: %d", jobId))
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to retrieve job %d: %v", jobId, err)
+			respondWithError(w, r, statusIntError, "internal_error", fmt.Sprintf("Failed to retrieve job: %v", err))
+			return
+		}
+		respondWithJson(w, statusOK, job)
+	}
+}
+
+// jobCancelHandler handles cancelling a queued or running job
+func jobCancelHandler(jobs *hecate.JobRunner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobId, err := strconv.ParseInt(chi.URLParam(r, "jobId"), 10, 64)
+		if err != nil {
+			respondWithError(w, r, statusBadReq, "invalid_id", "Job id must be a number")
+			return
+		}
+
+		job, err := jobs.Cancel(jobId)
+		switch {
+		case errors.Is(err, database.ErrNotFound):
+			respondWithError(w, r, statusNotFound, "job_not_found", fmt.Sprintf("Job not found: %d", jobId))
+		case errors.Is(err, hecate.ErrJobFinished):
+			respondWithError(w, r, statusConflict, "job_finished", err.Error())
+		case err != nil:
+			log.Printf("Failed to cancel job %d: %v", jobId, err)
+			respondWithError(w, r, statusIntError, "internal_error", fmt.Sprintf("Failed to cancel job: %v", err))
+		default:
+			respondWithJson(w, statusAccepted, job)
+		}
+	}
+}
+
+// postCommentsGetHandler handles retrieving the comment tree of a post
+func postCommentsGetHandler(db database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		postId := chi.URLParam(r, "postId")
+		log.Printf("Retrieving comments for post: %s", postId)
+		comments, err := hecate.GetPostComments(db, postId)
+		if errors.Is(err, database.ErrNotFound) {
+			respondWithError(w, r, statusNotFound, "post_not_found", fmt.Sprintf("Post not found: %s", postId))
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to retrieve comments for post %s: %v", postId, err)
+			respondWithError(w, r, statusIntError, "internal_error", fmt.Sprintf("Failed to retrieve comments: %v", err))
+			return
+		}
+		respondWithJson(w, statusOK, comments)
+	}
+}
+
+// searchPostsHandler handles full-text search over posts, optionally restricted to a subreddit
+func searchPostsHandler(db database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("q")
+		if strings.TrimSpace(query) == "" {
+			respondWithError(w, r, statusBadReq, "missing_query", "Search query is required")
+			return
+		}
+		subreddit := r.URL.Query().Get("subreddit")
+
+		log.Printf("Searching posts with query: %s (Subreddit: %s)", query, subreddit)
+		response, err := hecate.SearchPosts(db, query, subreddit)
+		var syntaxErr *searchquery.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			respondWithError(w, r, statusBadReq, "invalid_query", fmt.Sprintf("Invalid search query at %v", syntaxErr))
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to search posts: %v", err)
+			respondWithError(w, r, statusIntError, "internal_error", fmt.Sprintf("Failed to search posts: %v", err))
+			return
 		}
 
-		log.Printf("Found %d posts matching query: %s", len(posts), query)
+		log.Printf("Found %d posts matching query: %s", len(response.Posts), query)
 		respondWithJson(w, statusOK, response)
 	}
 }
 
-// decodeJSONBody decodes the JSON body of a request into a given struct
+// decodeJSONBody decodes the JSON body of a request into a given struct. It responds
+// with a 400 and returns an error when the body is missing or malformed.
 func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
-	if r.Body == nil {
+	if r.Body == nil || r.Body == http.NoBody {
+		respondWithError(w, r, statusBadReq, "missing_body", "Request body is required")
 		return fmt.Errorf("request body is empty")
 	}
 	defer r.Body.Close()
 
 	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
-		respondWithError(w, statusBadReq, fmt.Sprintf("Invalid request body: %v", err))
+		if errors.Is(err, io.EOF) {
+			respondWithError(w, r, statusBadReq, "missing_body", "Request body is required")
+			return fmt.Errorf("request body is empty")
+		}
+		respondWithError(w, r, statusBadReq, "invalid_body", fmt.Sprintf("Invalid request body: %v", err))
 		return fmt.Errorf("failed to decode JSON body: %w", err)
 	}
 	return nil
 }
+
+// parsePagination reads the limit and cursor query parameters of a listing. It responds
+// with a 400 and returns false when they are invalid.
+func parsePagination(w http.ResponseWriter, r *http.Request) (database.Paginate, bool) {
+	pagination := database.Paginate{Limit: defaultPageLimit, Cursor: r.URL.Query().Get("cursor")}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > maxPageLimit {
+			respondWithError(w, r, statusBadReq, "invalid_limit", fmt.Sprintf("Limit must be a number from 1 to %d", maxPageLimit))
+			return pagination, false
+		}
+		pagination.Limit = parsed
+	}
+	if pagination.Cursor != "" {
+		if _, err := database.DecodeCursor(pagination.Cursor); err != nil {
+			respondWithError(w, r, statusBadReq, "invalid_cursor", "Cursor must be the next cursor of a previous page")
+			return pagination, false
+		}
+	}
+	return pagination, true
+}
+
+// parsePostQuery reads how a listing of posts is sorted and filtered from the query
+// string, responding with a 400 and returning false when a parameter is invalid.
+// Dates are either YYYY-MM-DD, midnight UTC, or RFC 3339 times.
+func parsePostQuery(w http.ResponseWriter, r *http.Request) (database.PostQuery, bool) {
+	params := r.URL.Query()
+	query := database.PostQuery{
+		Sort:  database.PostSort(params.Get("sort")),
+		Flair: params.Get("flair"),
+	}
+
+	invalid := func(code, format string, args ...any) (database.PostQuery, bool) {
+		respondWithError(w, r, statusBadReq, code, fmt.Sprintf(format, args...))
+		return query, false
+	}
+
+	if query.Sort != "" && !slices.Contains(database.PostSorts, query.Sort) {
+		sorts := make([]string, len(database.PostSorts))
+		for i, sort := range database.PostSorts {
+			sorts[i] = string(sort)
+		}
+		return invalid("invalid_sort", "Sort must be one of %s", strings.Join(sorts, ", "))
+	}
+
+	switch params.Get("order") {
+	case "", "desc":
+	case "asc":
+		query.Ascending = true
+	default:
+		return invalid("invalid_sort", "Order must be asc or desc")
+	}
+
+	for _, param := range []struct {
+		name  string
+		value **int
+	}{{"min_score", &query.MinScore}, {"min_comments", &query.MinComments}} {
+		if raw := params.Get(param.name); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return invalid("invalid_filter", "%s must be a whole number", param.name)
+			}
+			*param.value = &n
+		}
+	}
+
+	for _, param := range []struct {
+		name  string
+		value *time.Time
+	}{{"after", &query.After}, {"before", &query.Before}} {
+		if raw := params.Get(param.name); raw != "" {
+			t, err := parseDate(raw)
+			if err != nil {
+				return invalid("invalid_filter", "%s must be a date such as 2025-01-01 or an RFC 3339 time", param.name)
+			}
+			*param.value = t
+		}
+	}
+
+	if postType := params.Get("type"); postType != "" {
+		if postType != "self" && postType != "link" {
+			return invalid("invalid_filter", "Type must be self or link")
+		}
+		isSelf := postType == "self"
+		query.IsSelf = &isSelf
+	}
+
+	if raw := params.Get("nsfw"); raw != "" {
+		nsfw, err := strconv.ParseBool(raw)
+		if err != nil {
+			return invalid("invalid_filter", "nsfw must be true or false")
+		}
+		query.Nsfw = &nsfw
+	}
+
+	if err := query.Validate(); err != nil {
+		return invalid("invalid_filter", "%v", err)
+	}
+	return query, true
+}
+
+// parseDate parses a YYYY-MM-DD date as midnight UTC, or an RFC 3339 time
+func parseDate(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+// setNextLink points the Link header at the following page of a listing, if there is one
+func setNextLink(w http.ResponseWriter, r *http.Request, pagination database.Paginate, next string) {
+	if next == "" {
+		return
+	}
+	query := r.URL.Query()
+	query.Set("limit", strconv.Itoa(pagination.Limit))
+	query.Set("cursor", next)
+	w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, query.Encode()))
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/go-chi/chi/v5"
+	"github.com/samratjha96/hecate/internal/database"
+	"github.com/samratjha96/hecate/internal/hecate"
+	"github.com/samratjha96/hecate/internal/reddit"
+)
+
+// testAPI is the router of the API over a migrated in-memory SQLite store and a fake Reddit
+type testAPI struct {
+	router  chi.Router
+	openAPI *openapi3.T
+	db      *database.DB
+}
+
+// newTestAPI builds the router the way main does. Reddit is served by newFakeReddit.
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	db, err := database.NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	openAPI, err := loadOpenAPI()
+	if err != nil {
+		t.Fatalf("loadOpenAPI: %v", err)
+	}
+	client := reddit.NewClient("hecate-test", reddit.WithBaseURL(newFakeReddit(t).URL))
+	jobs, err := hecate.NewJobRunner(db, client)
+	if err != nil {
+		t.Fatalf("NewJobRunner: %v", err)
+	}
+	scheduler := hecate.NewScheduler(db, client, hecate.DefaultScheduleIntervals, 0)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := scheduler.Stop(ctx); err != nil {
+			t.Errorf("Scheduler.Stop: %v", err)
+		}
+		if err := jobs.Stop(ctx); err != nil {
+			t.Errorf("JobRunner.Stop: %v", err)
+		}
+	})
+
+	return &testAPI{router: newRouter(openAPI, db, client, jobs, scheduler), openAPI: openAPI, db: db}
+}
+
+// newFakeReddit serves r/golang, its posts by spez and their comments. Requests for
+// r/slow hang until the client gives up, and every other subreddit or user is not found.
+func newFakeReddit(t *testing.T) *httptest.Server {
+	post := func(id string) string {
+		return fmt.Sprintf(`{"kind": "t3", "data": {"id": %q, "name": "t3_%s", "title": "Post %s about Go", "selftext": "Generics and goroutines",
+			"author": "spez", "subreddit": "golang", "ups": 10, "num_comments": 1, "created": 1731330000, "is_self": true,
+			"permalink": "/r/golang/comments/%s/", "subreddit_subscribers": 1000}}`, id, id, id, id)
+	}
+	listing := fmt.Sprintf(`{"kind": "Listing", "data": {"after": null, "children": [%s, %s]}}`, post("p1"), post("p2"))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if strings.Contains(path, "slow") {
+			<-r.Context().Done()
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case path == "/r/golang/about.json":
+			fmt.Fprint(w, `{"kind": "t5", "data": {"display_name": "golang", "name": "t5_2rc7j", "title": "The Go Programming Language", "subscribers": 1000}}`)
+		case strings.HasPrefix(path, "/r/golang/"), path == "/search.json", path == "/user/spez/submitted.json":
+			fmt.Fprint(w, listing)
+		case strings.HasPrefix(path, "/comments/"):
+			id := strings.TrimSuffix(strings.TrimPrefix(path, "/comments/"), ".json")
+			fmt.Fprintf(w, `[{"kind": "Listing", "data": {"children": [%s]}}, {"kind": "Listing", "data": {"children": [
+				{"kind": "t1", "data": {"id": "c%s", "parent_id": "t3_%s", "author": "gopher", "body": "Nice", "score": 3, "depth": 0, "created_utc": 1731330600, "replies": ""}}]}}]`,
+				post(id), id, id)
+		case strings.HasPrefix(path, "/r/secret/"):
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"reason": "private", "message": "Forbidden", "error": 403}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found", "error": 404}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newJSONRequest creates a request with a JSON body, unless body is empty
+func newJSONRequest(method, target, body string) *http.Request {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	return request
+}
+
+// serve sends a request to the router, with a JSON body unless body is empty
+func (api *testAPI) serve(method, target, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	api.router.ServeHTTP(recorder, newJSONRequest(method, target, body))
+	return recorder
+}
+
+// waitForJob polls a job until it is no longer queued or running
+func (api *testAPI) waitForJob(t *testing.T, id int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var job hecate.JobFrontendResponse
+		response := api.serve(http.MethodGet, fmt.Sprintf("/api/jobs/%d", id), "")
+		if err := json.Unmarshal(response.Body.Bytes(), &job); err != nil {
+			t.Fatalf("failed to decode job %d: %v", id, err)
+		}
+		if job.Status != hecate.JobQueued && job.Status != hecate.JobRunning {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %d did not finish", id)
+}
+
+// assertProblem checks that a response is an RFC 7807 problem with the given status and code
+func assertProblem(t *testing.T, response *httptest.ResponseRecorder, target string, status int, code string) {
+	t.Helper()
+	if response.Code != status {
+		t.Errorf("got status %d, want %d: %s", response.Code, status, response.Body)
+	}
+	if contentType := response.Result().Header.Get("Content-Type"); contentType != problemContentType {
+		t.Errorf("got Content-Type %q, want %q", contentType, problemContentType)
+	}
+
+	var body problem
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode problem %s: %v", response.Body, err)
+	}
+	if body.Code != code {
+		t.Errorf("got code %q, want %q (%s)", body.Code, code, body.Detail)
+	}
+	if body.Status != status {
+		t.Errorf("got status %d in the body, want %d", body.Status, status)
+	}
+	if path, _, _ := strings.Cut(target, "?"); body.Instance != path {
+		t.Errorf("got instance %q, want %q", body.Instance, path)
+	}
+	if body.RequestId == "" {
+		t.Errorf("problem has no requestId")
+	}
+}
+
+// routeRequest is a request of a route and the response it is expected to get
+type routeRequest struct {
+	method string
+	target string
+	body   string
+	// setup prepares the state the request needs
+	setup  func(t *testing.T, api *testAPI)
+	status int
+	// contentType is checked on successful responses
+	contentType string
+	// code is the error code of problem responses
+	code string
+}
+
+func (tt routeRequest) name() string {
+	return tt.method + " " + tt.target
+}
+
+// routeRequests succeed on every route. They build on each other: the listings read
+// what was ingested before them.
+var routeRequests = []routeRequest{
+	{method: "GET", target: "/health", status: http.StatusOK, contentType: "text/plain; charset=utf-8"},
+	{method: "GET", target: "/api/openapi.json", status: http.StatusOK},
+	{method: "POST", target: "/api/subreddits/ingest", body: `{"subreddit": {"name": "golang", "comments": {"depth": 1}}}`, status: http.StatusCreated},
+	{method: "GET", target: "/api/subreddits", status: http.StatusOK},
+	{method: "GET", target: "/api/subreddits/golang?sort=upvotes&limit=1", status: http.StatusOK},
+	{method: "GET", target: "/api/subreddits/search?q=generics", status: http.StatusOK},
+	{method: "GET", target: "/api/posts/p1/comments", status: http.StatusOK},
+	{method: "POST", target: "/api/searches/ingest", body: `{"search": {"query": "goroutines"}}`, status: http.StatusCreated},
+	{method: "GET", target: "/api/searches", status: http.StatusOK},
+	{method: "GET", target: "/api/searches/1", status: http.StatusOK},
+	{method: "POST", target: "/api/users/ingest", body: `{"user": {"name": "spez"}}`, status: http.StatusCreated},
+	{method: "GET", target: "/api/users", status: http.StatusOK},
+	{method: "GET", target: "/api/users/spez", status: http.StatusOK},
+	{method: "POST", target: "/api/schedules", body: `{"kind": "subreddit", "name": "golang", "interval": "1h"}`, status: http.StatusOK},
+	{method: "GET", target: "/api/schedules", status: http.StatusOK},
+	{method: "POST", target: "/api/schedules/1/pause", status: http.StatusOK},
+	{method: "POST", target: "/api/schedules/1/resume", status: http.StatusOK},
+	{method: "POST", target: "/api/schedules/1/trigger", status: http.StatusAccepted},
+	{method: "POST", target: "/api/subreddits/ingest-all", body: `{"listing": "new"}`, status: http.StatusAccepted},
+	{method: "GET", target: "/api/jobs", status: http.StatusOK},
+	{method: "GET", target: "/api/jobs/1", status: http.StatusOK},
+	// A job waiting behind a hanging ingestion can still be cancelled
+	{method: "DELETE", target: "/api/jobs/2", setup: enqueueHangingJob, status: http.StatusAccepted},
+}
+
+// enqueueHangingJob enqueues an ingest-all job that hangs on r/slow
+func enqueueHangingJob(t *testing.T, api *testAPI) {
+	t.Helper()
+	if _, err := api.db.UpsertSubreddit("slow", reddit.SubredditAbout{}); err != nil {
+		t.Fatalf("UpsertSubreddit: %v", err)
+	}
+	if response := api.serve("POST", "/api/subreddits/ingest-all", `{}`); response.Code != http.StatusAccepted {
+		t.Fatalf("got status %d enqueueing a job: %s", response.Code, response.Body)
+	}
+}
+
+// problemRequests fail with a problem, after setUpProblems
+var problemRequests = []routeRequest{
+	{method: "GET", target: "/api/subreddits?limit=0", status: http.StatusBadRequest, code: "invalid_limit"},
+	{method: "GET", target: "/api/subreddits?cursor=not-a-cursor", status: http.StatusBadRequest, code: "invalid_cursor"},
+	{method: "GET", target: "/api/subreddits/golang?cursor=not-a-cursor", status: http.StatusBadRequest, code: "invalid_cursor"},
+	{method: "GET", target: "/api/subreddits/golang?sort=oldest", status: http.StatusBadRequest, code: "invalid_sort"},
+	{method: "GET", target: "/api/subreddits/search?q=title:", status: http.StatusBadRequest, code: "invalid_query"},
+	{method: "POST", target: "/api/subreddits/ingest", body: `{"subreddit": `, status: http.StatusBadRequest, code: "invalid_body"},
+	{method: "POST", target: "/api/subreddits/ingest", body: `{"subreddit": {"name": "no spaces"}}`, status: http.StatusBadRequest, code: "invalid_subreddit_name"},
+	{method: "POST", target: "/api/schedules", body: `{"kind": "subreddit", "name": "slow", "interval": "1s"}`, status: http.StatusBadRequest, code: "invalid_request"},
+	{method: "GET", target: "/api/jobs/first", status: http.StatusBadRequest, code: "invalid_id"},
+	{method: "GET", target: "/api/nothing", status: http.StatusNotFound, code: "route_not_found"},
+	{method: "POST", target: "/api/subreddits/ingest", body: `{"subreddit": {"name": "missing"}}`, status: http.StatusNotFound, code: "subreddit_not_found"},
+	{method: "POST", target: "/api/users/ingest", body: `{"user": {"name": "missing"}}`, status: http.StatusNotFound, code: "user_not_found"},
+	{method: "POST", target: "/api/searches/ingest", body: `{"search": {"query": "go", "subreddit": "missing"}}`, status: http.StatusNotFound, code: "search_subreddit_not_found"},
+	{method: "POST", target: "/api/searches/ingest", body: `{"search": {"query": "go", "subreddit": "secret"}}`, status: http.StatusForbidden, code: "search_subreddit_private"},
+	{method: "POST", target: "/api/subreddits/ingest", body: `{"subreddit": {"name": "secret"}}`, status: http.StatusForbidden, code: "subreddit_private"},
+	{method: "GET", target: "/api/searches/99", status: http.StatusNotFound, code: "search_not_found"},
+	{method: "GET", target: "/api/users/missing", status: http.StatusNotFound, code: "user_not_found"},
+	{method: "GET", target: "/api/posts/missing/comments", status: http.StatusNotFound, code: "post_not_found"},
+	{method: "POST", target: "/api/schedules/99/pause", status: http.StatusNotFound, code: "not_found"},
+	{method: "GET", target: "/api/jobs/99", status: http.StatusNotFound, code: "job_not_found"},
+	{method: "PUT", target: "/api/subreddits", status: http.StatusMethodNotAllowed, code: "method_not_allowed"},
+	{method: "DELETE", target: "/api/schedules/1/trigger", status: http.StatusMethodNotAllowed, code: "method_not_allowed"},
+	{method: "DELETE", target: "/api/jobs/1", status: http.StatusConflict, code: "job_finished"},
+	{method: "POST", target: "/api/schedules/1/trigger", status: http.StatusConflict, code: "schedule_running"},
+}
+
+// setUpProblems leaves job 1 finished and schedule 1 running, hanging on r/slow
+func setUpProblems(t *testing.T, api *testAPI) {
+	t.Helper()
+	if response := api.serve("POST", "/api/subreddits/ingest-all", `{}`); response.Code != http.StatusAccepted {
+		t.Fatalf("got status %d enqueueing a job: %s", response.Code, response.Body)
+	}
+	api.waitForJob(t, 1)
+	if _, err := api.db.UpsertSubreddit("slow", reddit.SubredditAbout{}); err != nil {
+		t.Fatalf("UpsertSubreddit: %v", err)
+	}
+	if response := api.serve("POST", "/api/schedules", `{"kind": "subreddit", "name": "slow"}`); response.Code != http.StatusOK {
+		t.Fatalf("got status %d configuring a schedule: %s", response.Code, response.Body)
+	}
+	if response := api.serve("POST", "/api/schedules/1/trigger", ""); response.Code != http.StatusAccepted {
+		t.Fatalf("got status %d triggering a schedule: %s", response.Code, response.Body)
+	}
+}
+
+// internalErrorTargets are listings that fail with a 500 once the store is closed
+var internalErrorTargets = []string{"/api/subreddits", "/api/searches", "/api/users", "/api/schedules", "/api/jobs"}
+
+// runRequests sends the requests in order, each in a subtest checking its response
+func runRequests(t *testing.T, api *testAPI, requests []routeRequest, check func(t *testing.T, tt routeRequest, request *http.Request, response *httptest.ResponseRecorder)) {
+	for _, tt := range requests {
+		t.Run(tt.name(), func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup(t, api)
+			}
+			request := newJSONRequest(tt.method, tt.target, tt.body)
+			response := httptest.NewRecorder()
+			api.router.ServeHTTP(response, request)
+			check(t, tt, request, response)
+		})
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	runRequests(t, newTestAPI(t), routeRequests, func(t *testing.T, tt routeRequest, request *http.Request, response *httptest.ResponseRecorder) {
+		if response.Code != tt.status {
+			t.Fatalf("got status %d, want %d: %s", response.Code, tt.status, response.Body)
+		}
+		contentType := tt.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		if got := response.Result().Header.Get("Content-Type"); got != contentType {
+			t.Errorf("got Content-Type %q, want %q", got, contentType)
+		}
+	})
+}
+
+func TestProblems(t *testing.T) {
+	api := newTestAPI(t)
+	setUpProblems(t, api)
+	runRequests(t, api, problemRequests, func(t *testing.T, tt routeRequest, request *http.Request, response *httptest.ResponseRecorder) {
+		assertProblem(t, response, tt.target, tt.status, tt.code)
+	})
+
+	t.Run("internal error", func(t *testing.T) {
+		api := newTestAPI(t)
+		api.db.Close()
+		for _, target := range internalErrorTargets {
+			assertProblem(t, api.serve("GET", target, ""), target, http.StatusInternalServerError, "internal_error")
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/samratjha96/hecate/internal/reddit"
+)
+
+// ErrNotFound is returned when a requested row does not exist
+var ErrNotFound = errors.New("not found")
+
+type CommentDao struct {
+	CommentId       string
+	ParentCommentId string
+	Author          string
+	Content         string
+	Upvotes         int
+	Depth           int
+	TimePosted      time.Time
+}
+
+// UpsertComments inserts or updates the comment tree of a post in a single transaction,
+// linking every reply to its parent comment. It returns the number of comments written.
+func (db *DB) UpsertComments(postId string, comments []reddit.Comment) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var postRowId int64
+	err = tx.QueryRow(`SELECT id FROM posts WHERE post_id = $1`, postId).Scan(&postRowId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("post %s: %w", postId, ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up post %s: %w", postId, err)
+	}
+
+	stmt, err := tx.Prepare(`
+        INSERT INTO comments (post_id, parent_comment_id, comment_id, author, content, upvotes, depth, posted_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        ON CONFLICT (comment_id) DO UPDATE SET
+            parent_comment_id = EXCLUDED.parent_comment_id,
+            author = EXCLUDED.author,
+            content = EXCLUDED.content,
+            upvotes = EXCLUDED.upvotes,
+            depth = EXCLUDED.depth,
+            posted_at = EXCLUDED.posted_at,
+            updated_at = CURRENT_TIMESTAMP
+        RETURNING id
+    `)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare comment upsert: %w", err)
+	}
+	defer stmt.Close()
+
+	count := 0
+	var upsert func(comments []reddit.Comment, parentRowId sql.NullInt64) error
+	upsert = func(comments []reddit.Comment, parentRowId sql.NullInt64) error {
+		for _, comment := range comments {
+			var rowId int64
+			err := stmt.QueryRow(postRowId, parentRowId, comment.CommentId, comment.Author, comment.Content,
+				comment.Upvotes, comment.Depth, comment.TimePosted).Scan(&rowId)
+			if err != nil {
+				return fmt.Errorf("failed to upsert comment %s: %w", comment.CommentId, err)
+			}
+			count++
+
+			if err := upsert(comment.Replies, sql.NullInt64{Int64: rowId, Valid: true}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := upsert(comments, sql.NullInt64{}); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit comments: %w", err)
+	}
+	log.Printf("Upserted %d comments for post: %s", count, postId)
+	return count, nil
+}
+
+// GetPostComments retrieves all comments of a post, ordered so parents precede their replies
+func (db *DB) GetPostComments(postId string) ([]CommentDao, error) {
+	var postRowId int64
+	err := db.QueryRow(`SELECT id FROM posts WHERE post_id = $1`, postId).Scan(&postRowId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("post %s: %w", postId, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up post %s: %w", postId, err)
+	}
+
+	query := `
+        SELECT c.comment_id, COALESCE(parent.comment_id, ''), COALESCE(c.author, ''), c.content,
+               COALESCE(c.upvotes, 0), COALESCE(c.depth, 0), c.posted_at
+        FROM comments c
+        LEFT JOIN comments parent ON parent.id = c.parent_comment_id
+        WHERE c.post_id = $1
+        ORDER BY c.depth, c.upvotes DESC, c.id
+    `
+	rows, err := db.Query(query, postRowId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query comments for post %s: %w", postId, err)
+	}
+	defer rows.Close()
+
+	var comments []CommentDao
+	for rows.Next() {
+		var c CommentDao
+		var postedAt sql.NullTime
+		if err := rows.Scan(&c.CommentId, &c.ParentCommentId, &c.Author, &c.Content, &c.Upvotes, &c.Depth, &postedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan comment row: %w", err)
+		}
+		c.TimePosted = postedAt.Time
+		comments = append(comments, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating comment rows: %w", err)
+	}
+
+	return comments, nil
+}
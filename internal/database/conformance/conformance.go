@@ -0,0 +1,839 @@
+// Package conformance checks that a database.Store behaves the way the service layer
+// relies on, so the SQLite and PostgreSQL backends are held to the same contract. The
+// backends' tests run the checks; every check writes rows of its own, so the checks can
+// share one freshly migrated store.
+package conformance
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samratjha96/hecate/internal/database"
+	"github.com/samratjha96/hecate/internal/reddit"
+	"github.com/samratjha96/hecate/internal/searchquery"
+)
+
+type check struct {
+	name string
+	run  func(store database.Store) error
+}
+
+var checks = []check{
+	{"subreddits", checkSubreddits},
+	{"posts", checkPosts},
+	{"post sources", checkPostSources},
+	{"batch posts", checkBatchPosts},
+	{"pagination", checkPagination},
+	{"sorting and filtering", checkSortingAndFiltering},
+	{"search", checkSearch},
+	{"comments", checkComments},
+	{"jobs", checkJobs},
+	{"saved searches", checkSavedSearches},
+	{"followed users", checkFollowedUsers},
+	{"schedules", checkSchedules},
+}
+
+// Run migrates the store and runs every check against it as a subtest. The store
+// should be empty, as the checks count the rows they find.
+func Run(t *testing.T, store database.Store) {
+	t.Helper()
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	for _, c := range checks {
+		t.Run(c.name, func(t *testing.T) {
+			if err := c.run(store); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// baseTime is when the posts of the checks were submitted, at whole seconds so every
+// backend stores it exactly
+var baseTime = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// newPost returns a post with every field set, submitted hoursAgo before baseTime
+func newPost(postId, subreddit, title, content string, hoursAgo int) reddit.RedditPost {
+	return reddit.RedditPost{
+		PostId:        postId,
+		Fullname:      "t3_" + postId,
+		Title:         title,
+		Content:       content,
+		Author:        "author_" + postId,
+		Subreddit:     subreddit,
+		DiscussionUrl: "https://www.reddit.com/r/" + subreddit + "/comments/" + postId,
+		Url:           "https://example.com/" + postId,
+		Domain:        "example.com",
+		Thumbnail:     "self",
+		Flair:         "Discussion",
+		CommentCount:  7,
+		Upvotes:       100 - hoursAgo,
+		UpvoteRatio:   0.5,
+		TimePosted:    baseTime.Add(-time.Duration(hoursAgo) * time.Hour),
+	}
+}
+
+func checkSubreddits(store database.Store) error {
+	about := reddit.SubredditAbout{Title: "Conformance", NumberOfSubscribers: 10, CreatedAt: baseTime}
+	posts := []reddit.RedditPost{
+		newPost("sub1", "ConformanceSub", "first", "", 1),
+		newPost("sub2", "ConformanceSub", "second", "", 2),
+	}
+
+	counts, err := store.UpsertSubredditWithPosts("ConformanceSub", about, posts)
+	if err != nil {
+		return err
+	}
+	if counts != (database.UpsertCounts{Inserted: 2}) {
+		return fmt.Errorf("first upsert counted %+v, want 2 inserted", counts)
+	}
+
+	counts, err = store.UpsertSubredditWithPosts("conformancesub", about, posts)
+	if err != nil {
+		return err
+	}
+	if counts != (database.UpsertCounts{Updated: 2}) {
+		return fmt.Errorf("second upsert counted %+v, want 2 updated", counts)
+	}
+
+	name, err := store.GetSubredditName("CONFORMANCESUB")
+	if err != nil {
+		return err
+	}
+	if name != "conformancesub" {
+		return fmt.Errorf("canonical name is %q, want the casing of the last upsert", name)
+	}
+
+	if err := store.UpdateSubscriberCount("ConformanceSUB", 42); err != nil {
+		return err
+	}
+	subscription := database.SubredditSubscriptionDao{Listing: "new", TimeWindow: "week", Limit: 50, MaxPosts: 200, Comments: true, CommentLimit: 20}
+	if err := store.SetSubredditSubscription("CONFORMANCEsub", subscription); err != nil {
+		return err
+	}
+	subreddits, err := store.GetAllSubreddits()
+	if err != nil {
+		return err
+	}
+	if len(subreddits) != 1 {
+		return fmt.Errorf("got %d subreddits, want names matching case-insensitively to share one", len(subreddits))
+	}
+	if subreddits[0].NumberOfSubscribers != 42 || subreddits[0].Title != "Conformance" {
+		return fmt.Errorf("stored subreddit is %+v", subreddits[0])
+	}
+	if subreddits[0].Subscription != subscription {
+		return fmt.Errorf("stored subreddit subscription is %+v, want %+v", subreddits[0].Subscription, subscription)
+	}
+	// Subscriptions without comments store none of their settings
+	subscription = database.SubredditSubscriptionDao{Listing: "hot", CommentDepth: 3}
+	if err := store.SetSubredditSubscription("conformancesub", subscription); err != nil {
+		return err
+	}
+	stored, err := store.GetSubredditSubscription("ConformanceSub")
+	if err != nil {
+		return err
+	}
+	if want := (database.SubredditSubscriptionDao{Listing: "hot"}); stored != want {
+		return fmt.Errorf("stored subreddit subscription is %+v, want %+v", stored, want)
+	}
+	if !subreddits[0].CreatedAt.Equal(baseTime) {
+		return fmt.Errorf("subreddit created at %v, want %v", subreddits[0].CreatedAt, baseTime)
+	}
+
+	if _, err := store.GetSubredditName("missing"); !errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("looking up a missing subreddit returned %v, want ErrNotFound", err)
+	}
+	if _, err := store.GetSubredditSubscription("missing"); !errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("looking up the subscription of a missing subreddit returned %v, want ErrNotFound", err)
+	}
+	return nil
+}
+
+func checkPosts(store database.Store) error {
+	older := newPost("post1", "ConformancePosts", "older", "body", 5)
+	older.Nsfw = true
+	older.CrosspostParents = []reddit.CrosspostParent{{PostId: "parent", Subreddit: "golang", Permalink: "/r/golang/parent"}}
+	newer := newPost("post2", "ConformancePosts", "newer", "", 1)
+	newer.IsSelf = true
+
+	for _, post := range []reddit.RedditPost{older, newer} {
+		inserted, err := store.UpsertPost(post, "ConformancePosts")
+		if err != nil {
+			return err
+		}
+		if !inserted {
+			return fmt.Errorf("post %s was not reported as new", post.PostId)
+		}
+	}
+
+	older.Title = "older, edited"
+	inserted, err := store.UpsertPost(older, "ConformancePosts")
+	if err != nil {
+		return err
+	}
+	if inserted {
+		return fmt.Errorf("updating post %s reported it as new", older.PostId)
+	}
+
+	page, err := store.GetSubredditPosts("conformanceposts", database.PostQuery{}, database.Paginate{Limit: 10})
+	if err != nil {
+		return err
+	}
+	posts := page.Items
+	if page.Next != "" {
+		return fmt.Errorf("a single page has a next cursor")
+	}
+	if len(posts) != 2 || posts[0].PostId != "post2" || posts[1].PostId != "post1" {
+		return fmt.Errorf("got posts %v, want post2 and post1, newest first", postIds(posts))
+	}
+
+	got := posts[1]
+	if got.Title != "older, edited" || got.Content != "body" || got.Author != "author_post1" || got.Fullname != "t3_post1" ||
+		got.Url != older.Url || got.Domain != older.Domain || got.DiscussionURL != older.DiscussionUrl ||
+		got.Flair != older.Flair || got.Thumbnail != older.Thumbnail || !got.Nsfw || got.IsSelf ||
+		got.CommentCount != older.CommentCount || got.Upvotes != older.Upvotes || got.UpvoteRatio != older.UpvoteRatio ||
+		got.SubredditName != "ConformancePosts" {
+		return fmt.Errorf("stored post is %+v", got)
+	}
+	if len(got.CrosspostParents) != 1 || got.CrosspostParents[0] != older.CrosspostParents[0] {
+		return fmt.Errorf("crosspost parents are %+v", got.CrosspostParents)
+	}
+	if !posts[0].IsSelf || posts[0].CrosspostParents != nil {
+		return fmt.Errorf("stored post is %+v", posts[0])
+	}
+	return nil
+}
+
+func checkPostSources(store database.Store) error {
+	post := newPost("source1", "ConformanceSources", "sourced", "", 1)
+	if _, err := store.UpsertPost(post, "ConformanceSources"); err != nil {
+		return err
+	}
+	for i := 0; i < 2; i++ {
+		if err := store.AddPostSource(post.PostId, database.SourceTypeUser, "someone"); err != nil {
+			return err
+		}
+	}
+
+	page, err := store.GetSourcePosts(database.SourceTypeUser, "someone", database.Paginate{Limit: 10})
+	if err != nil {
+		return err
+	}
+	if posts := page.Items; len(posts) != 1 || posts[0].PostId != post.PostId {
+		return fmt.Errorf("got source posts %v, want only %s", postIds(posts), post.PostId)
+	}
+
+	page, err = store.GetSourcePosts(database.SourceTypeSearch, "someone", database.Paginate{Limit: 10})
+	if err != nil {
+		return err
+	}
+	if len(page.Items) != 0 {
+		return fmt.Errorf("got %v for another source type, want none", postIds(page.Items))
+	}
+	return nil
+}
+
+func checkBatchPosts(store database.Store) error {
+	posts := []reddit.RedditPost{
+		newPost("batch1", "ConformanceBatchA", "first of the batch", "", 1),
+		newPost("batch2", "ConformanceBatchB", "second of the batch", "", 2),
+	}
+	counts, err := store.UpsertPosts(posts, "", database.SourceTypeSearch, "batch")
+	if err != nil {
+		return err
+	}
+	if counts != (database.UpsertCounts{Inserted: 2}) {
+		return fmt.Errorf("got %+v for new posts, want 2 inserted", counts)
+	}
+
+	posts[0].Upvotes = 500
+	counts, err = store.UpsertPosts(posts, "", database.SourceTypeSearch, "batch")
+	if err != nil {
+		return err
+	}
+	if counts != (database.UpsertCounts{Updated: 2}) {
+		return fmt.Errorf("got %+v for stored posts, want 2 updated", counts)
+	}
+
+	page, err := store.GetSourcePosts(database.SourceTypeSearch, "batch", database.Paginate{Limit: 10})
+	if err != nil {
+		return err
+	}
+	if got := postIds(page.Items); strings.Join(got, ",") != "batch1,batch2" {
+		return fmt.Errorf("got source posts %v, want batch1,batch2", got)
+	}
+	if page.Items[0].Upvotes != 500 {
+		return fmt.Errorf("got %d upvotes after the second batch, want 500", page.Items[0].Upvotes)
+	}
+
+	// Without a subreddit name every post keeps its own
+	stored, err := store.GetSubredditPosts("ConformanceBatchB", database.PostQuery{}, database.Paginate{Limit: 10})
+	if err != nil {
+		return err
+	}
+	if got := postIds(stored.Items); len(got) != 1 || got[0] != "batch2" {
+		return fmt.Errorf("got %v in ConformanceBatchB, want batch2", got)
+	}
+	return nil
+}
+
+func checkPagination(store database.Store) error {
+	// Posts submitted at the same time are ordered by when they were stored, newest first
+	for i, hoursAgo := range []int{5, 4, 3, 3, 1} {
+		post := newPost(fmt.Sprintf("page%d", i), "ConformancePages", "paged", "", hoursAgo)
+		if _, err := store.UpsertPost(post, "ConformancePages"); err != nil {
+			return err
+		}
+	}
+
+	var pages [][]string
+	pagination := database.Paginate{Limit: 2}
+	for {
+		page, err := store.GetSubredditPosts("conformancepages", database.PostQuery{}, pagination)
+		if err != nil {
+			return err
+		}
+		pages = append(pages, postIds(page.Items))
+		if page.Next == "" || len(pages) > 3 {
+			break
+		}
+		pagination.Cursor = page.Next
+	}
+	if fmt.Sprint(pages) != "[[page4 page3] [page2 page1] [page0]]" {
+		return fmt.Errorf("got pages %v, want [[page4 page3] [page2 page1] [page0]]", pages)
+	}
+
+	all, err := store.GetAllSubreddits()
+	if err != nil {
+		return err
+	}
+	var paged []string
+	pagination = database.Paginate{Limit: 1}
+	for {
+		page, err := store.GetSubreddits(pagination)
+		if err != nil {
+			return err
+		}
+		for _, subreddit := range page.Items {
+			paged = append(paged, subreddit.Name)
+		}
+		if page.Next == "" || len(paged) > len(all) {
+			break
+		}
+		pagination.Cursor = page.Next
+	}
+	if len(all) == 0 || len(paged) != len(all) || paged[0] != all[0].Name {
+		return fmt.Errorf("paging through subreddits one at a time found %v, want all %d", paged, len(all))
+	}
+
+	_, err = store.GetSubredditPosts("conformancepages", database.PostQuery{}, database.Paginate{Limit: 2, Cursor: "not a cursor"})
+	if !errors.Is(err, database.ErrInvalidCursor) {
+		return fmt.Errorf("a malformed cursor returned %v, want ErrInvalidCursor", err)
+	}
+	return nil
+}
+
+func checkSortingAndFiltering(store database.Store) error {
+	type fields struct {
+		upvotes, comments int
+		flair             string
+		isSelf, nsfw      bool
+	}
+	// Posted one hour apart, oldest first. sort1 and sort3 tie on upvotes.
+	for i, f := range []fields{
+		{96, 3, "Discussion", true, false},
+		{50, 20, "News", false, true},
+		{96, 10, "news", false, false},
+		{10, 0, "", true, false},
+	} {
+		post := newPost(fmt.Sprintf("sort%d", i+1), "ConformanceSorting", "sorted", "", 4-i)
+		post.Upvotes, post.CommentCount, post.Flair, post.IsSelf, post.Nsfw = f.upvotes, f.comments, f.flair, f.isSelf, f.nsfw
+		if _, err := store.UpsertPost(post, "ConformanceSorting"); err != nil {
+			return err
+		}
+	}
+
+	minScore, minComments, yes, no := 50, 10, true, false
+	cases := []struct {
+		name  string
+		query database.PostQuery
+		want  string
+	}{
+		{"newest", database.PostQuery{}, "[sort4 sort3 sort2 sort1]"},
+		{"oldest", database.PostQuery{Sort: database.SortCreated, Ascending: true}, "[sort1 sort2 sort3 sort4]"},
+		{"last updated", database.PostQuery{Sort: database.SortUpdated}, "[sort4 sort3 sort2 sort1]"},
+		// Ties are broken by when the posts were stored, in the same direction
+		{"most upvoted", database.PostQuery{Sort: database.SortUpvotes}, "[sort3 sort1 sort2 sort4]"},
+		{"least upvoted", database.PostQuery{Sort: database.SortUpvotes, Ascending: true}, "[sort4 sort2 sort1 sort3]"},
+		{"most comments", database.PostQuery{Sort: database.SortComments}, "[sort2 sort3 sort1 sort4]"},
+		{"minimum score", database.PostQuery{MinScore: &minScore}, "[sort3 sort2 sort1]"},
+		{"minimum comments", database.PostQuery{MinComments: &minComments}, "[sort3 sort2]"},
+		{"date range", database.PostQuery{After: baseTime.Add(-3 * time.Hour), Before: baseTime.Add(-time.Hour)}, "[sort3 sort2]"},
+		{"flair", database.PostQuery{Flair: "NEWS"}, "[sort3 sort2]"},
+		{"self posts", database.PostQuery{IsSelf: &yes}, "[sort4 sort1]"},
+		{"link posts", database.PostQuery{IsSelf: &no}, "[sort3 sort2]"},
+		{"nsfw", database.PostQuery{Nsfw: &yes}, "[sort2]"},
+		{"combined", database.PostQuery{Sort: database.SortUpvotes, Ascending: true, Flair: "news", Nsfw: &no}, "[sort3]"},
+	}
+	for _, c := range cases {
+		// Page one post at a time, so every cursor is followed
+		var ids []string
+		pagination := database.Paginate{Limit: 1}
+		for {
+			page, err := store.GetSubredditPosts("conformancesorting", c.query, pagination)
+			if err != nil {
+				return fmt.Errorf("%s: %w", c.name, err)
+			}
+			ids = append(ids, postIds(page.Items)...)
+			if page.Next == "" || len(ids) > 4 {
+				break
+			}
+			pagination.Cursor = page.Next
+		}
+		if fmt.Sprint(ids) != c.want {
+			return fmt.Errorf("%s: got %v, want %s", c.name, ids, c.want)
+		}
+	}
+
+	page, err := store.GetSubredditPosts("conformancesorting", database.PostQuery{Sort: database.SortUpvotes}, database.Paginate{Limit: 1})
+	if err != nil {
+		return err
+	}
+	_, err = store.GetSubredditPosts("conformancesorting", database.PostQuery{}, database.Paginate{Limit: 1, Cursor: page.Next})
+	if !errors.Is(err, database.ErrInvalidCursor) {
+		return fmt.Errorf("a cursor of another sort order returned %v, want ErrInvalidCursor", err)
+	}
+
+	negative := -1
+	for _, query := range []database.PostQuery{
+		{Sort: "random"},
+		{MinComments: &negative},
+		{After: baseTime, Before: baseTime},
+	} {
+		_, err := store.GetSubredditPosts("conformancesorting", query, database.Paginate{Limit: 1})
+		if !errors.Is(err, database.ErrInvalidPostQuery) {
+			return fmt.Errorf("query %+v returned %v, want ErrInvalidPostQuery", query, err)
+		}
+	}
+	return nil
+}
+
+func checkSearch(store database.Store) error {
+	posts := []reddit.RedditPost{
+		newPost("search1", "ConformanceSearch", "Zebrafish regenerate their hearts", "A study of zebrafish.", 3),
+		newPost("search2", "ConformanceSearch", "Aquarium tips", "My zebrafish tank is cloudy", 2),
+		newPost("search3", "ConformanceOther", "Zebrafish genetics", "", 1),
+	}
+	for _, post := range posts {
+		if _, err := store.UpsertPost(post, post.Subreddit); err != nil {
+			return err
+		}
+	}
+
+	cases := []struct {
+		query, subreddit string
+		want             []string
+		// ranked cases check the order of the results too
+		ranked bool
+	}{
+		// The title weighs more than the body
+		{"zebrafish", "conformancesearch", []string{"search1", "search2"}, true},
+		{"zebra*", "", []string{"search1", "search2", "search3"}, false},
+		{`"regenerate their hearts"`, "", []string{"search1"}, true},
+		{"zebrafish -genetics subreddit:ConformanceOther", "", nil, true},
+		{"subreddit:conformanceother", "", []string{"search3"}, true},
+	}
+	for _, c := range cases {
+		results, err := store.SearchPosts(c.query, c.subreddit)
+		if err != nil {
+			return fmt.Errorf("search %q: %w", c.query, err)
+		}
+		var got []string
+		for _, result := range results {
+			got = append(got, result.PostId)
+		}
+		if !c.ranked {
+			sort.Strings(got)
+		}
+		if strings.Join(got, ",") != strings.Join(c.want, ",") {
+			return fmt.Errorf("search %q in %q found %v, want %v", c.query, c.subreddit, got, c.want)
+		}
+	}
+
+	results, err := store.SearchPosts("regenerate", "")
+	if err != nil {
+		return err
+	}
+	if len(results) != 1 || results[0].Score <= 0 ||
+		!strings.Contains(results[0].Snippet, database.SnippetStart+"regenerate"+database.SnippetEnd) {
+		return fmt.Errorf("search results are %+v, want a scored snippet marking the match", results)
+	}
+
+	var syntaxErr *searchquery.SyntaxError
+	if _, err := store.SearchPosts("(zebrafish", ""); !errors.As(err, &syntaxErr) {
+		return fmt.Errorf("a malformed query returned %v, want a *searchquery.SyntaxError", err)
+	}
+	return nil
+}
+
+func checkComments(store database.Store) error {
+	if _, err := store.UpsertComments("missing", nil); !errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("commenting on a missing post returned %v, want ErrNotFound", err)
+	}
+	if _, err := store.GetPostComments("missing"); !errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("reading comments of a missing post returned %v, want ErrNotFound", err)
+	}
+
+	post := newPost("comments1", "ConformanceComments", "discussed", "", 1)
+	if _, err := store.UpsertPost(post, "ConformanceComments"); err != nil {
+		return err
+	}
+	tree := []reddit.Comment{{
+		CommentId:  "c1",
+		Author:     "first",
+		Content:    "top level",
+		Upvotes:    5,
+		TimePosted: baseTime,
+		Replies: []reddit.Comment{{
+			CommentId:  "c2",
+			ParentId:   "c1",
+			Author:     "second",
+			Content:    "reply",
+			Depth:      1,
+			TimePosted: baseTime,
+			Replies: []reddit.Comment{{
+				CommentId: "c3", ParentId: "c2", Author: "third", Content: "nested reply", Depth: 2, TimePosted: baseTime,
+			}},
+		}},
+	}}
+
+	for i := 0; i < 2; i++ {
+		count, err := store.UpsertComments(post.PostId, tree)
+		if err != nil {
+			return err
+		}
+		if count != 3 {
+			return fmt.Errorf("wrote %d comments, want 3", count)
+		}
+	}
+
+	comments, err := store.GetPostComments(post.PostId)
+	if err != nil {
+		return err
+	}
+	if len(comments) != 3 {
+		return fmt.Errorf("got %d comments, want 3 after upserting the tree twice", len(comments))
+	}
+	for i, want := range []struct{ id, parent string }{{"c1", ""}, {"c2", "c1"}, {"c3", "c2"}} {
+		if comments[i].CommentId != want.id || comments[i].ParentCommentId != want.parent {
+			return fmt.Errorf("comment %d is %+v, want %s replying to %q", i, comments[i], want.id, want.parent)
+		}
+	}
+	if comments[0].Upvotes != 5 || comments[0].Author != "first" || !comments[0].TimePosted.Equal(baseTime) {
+		return fmt.Errorf("stored comment is %+v", comments[0])
+	}
+	return nil
+}
+
+func checkJobs(store database.Store) error {
+	if _, err := store.GetJob(999999); !errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("getting a missing job returned %v, want ErrNotFound", err)
+	}
+
+	id, err := store.CreateJob("conformance", "queued", `{"all":true}`)
+	if err != nil {
+		return err
+	}
+	if err := store.StartJob(id, "running", baseTime); err != nil {
+		return err
+	}
+	if err := store.UpdateJobProgress(id, 2, 5); err != nil {
+		return err
+	}
+	job, err := store.GetJob(id)
+	if err != nil {
+		return err
+	}
+	if job.Status != "running" || job.Completed != 2 || job.Total != 5 || job.Request != `{"all":true}` ||
+		job.StartedAt == nil || !job.StartedAt.Equal(baseTime) || job.FinishedAt != nil || job.Result != "" {
+		return fmt.Errorf("running job is %+v", job)
+	}
+
+	finishedAt := baseTime.Add(time.Minute)
+	if err := store.FinishJob(id, "succeeded", `{"ok":true}`, "", finishedAt); err != nil {
+		return err
+	}
+	job, err = store.GetJob(id)
+	if err != nil {
+		return err
+	}
+	if job.Status != "succeeded" || job.Result != `{"ok":true}` || job.FinishedAt == nil || !job.FinishedAt.Equal(finishedAt) {
+		return fmt.Errorf("finished job is %+v", job)
+	}
+
+	queued, err := store.CreateJob("conformance", "queued", "{}")
+	if err != nil {
+		return err
+	}
+	running, err := store.CreateJob("conformance", "queued", "{}")
+	if err != nil {
+		return err
+	}
+	if err := store.StartJob(running, "running", baseTime); err != nil {
+		return err
+	}
+	failed, err := store.FailUnfinishedJobs("failed", "interrupted", "queued", "running")
+	if err != nil {
+		return err
+	}
+	if failed != 2 {
+		return fmt.Errorf("failed %d unfinished jobs, want 2", failed)
+	}
+
+	jobs, err := store.GetRecentJobs()
+	if err != nil {
+		return err
+	}
+	if len(jobs) != 3 || jobs[0].Id != running || jobs[1].Id != queued || jobs[2].Id != id {
+		return fmt.Errorf("got %d recent jobs, want the 3 created, newest first", len(jobs))
+	}
+	if jobs[0].Status != "failed" || jobs[0].Error != "interrupted" || jobs[2].Status != "succeeded" {
+		return fmt.Errorf("recent jobs are %+v", jobs)
+	}
+	return nil
+}
+
+func checkSavedSearches(store database.Store) error {
+	if _, err := store.GetSavedSearch(999999); !errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("getting a missing saved search returned %v, want ErrNotFound", err)
+	}
+
+	id, err := store.UpsertSavedSearch("rust async", "Programming", "new", "all")
+	if err != nil {
+		return err
+	}
+	again, err := store.UpsertSavedSearch("rust async", "programming", "top", "week")
+	if err != nil {
+		return err
+	}
+	if again != id {
+		return fmt.Errorf("upserting in the same subreddit returned id %d, want %d", again, id)
+	}
+	other, err := store.UpsertSavedSearch("rust async", "", "new", "all")
+	if err != nil {
+		return err
+	}
+	if other == id {
+		return fmt.Errorf("a search across all subreddits reused id %d", id)
+	}
+
+	search, err := store.GetSavedSearch(id)
+	if err != nil {
+		return err
+	}
+	if search.Query != "rust async" || search.Sort != "top" || search.TimeWindow != "week" {
+		return fmt.Errorf("saved search is %+v", search)
+	}
+
+	searches, err := store.GetAllSavedSearches()
+	if err != nil {
+		return err
+	}
+	if len(searches) != 2 || searches[0].Id != id || searches[1].Id != other {
+		return fmt.Errorf("got saved searches %+v, want %d and %d", searches, id, other)
+	}
+
+	page, err := store.GetSavedSearches(database.Paginate{Limit: 1})
+	if err != nil {
+		return err
+	}
+	if len(page.Items) != 1 || page.Items[0].Id != id || page.Next == "" {
+		return fmt.Errorf("first page of saved searches is %+v, want %d and a cursor", page, id)
+	}
+	page, err = store.GetSavedSearches(database.Paginate{Limit: 1, Cursor: page.Next})
+	if err != nil {
+		return err
+	}
+	if len(page.Items) != 1 || page.Items[0].Id != other || page.Next != "" {
+		return fmt.Errorf("last page of saved searches is %+v, want only %d", page, other)
+	}
+	return nil
+}
+
+func checkFollowedUsers(store database.Store) error {
+	if _, err := store.GetFollowedUser("nobody"); !errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("getting a missing user returned %v, want ErrNotFound", err)
+	}
+
+	id, err := store.UpsertFollowedUser("Spez", "new", "")
+	if err != nil {
+		return err
+	}
+	again, err := store.UpsertFollowedUser("spez", "top", "month")
+	if err != nil {
+		return err
+	}
+	if again != id {
+		return fmt.Errorf("upserting a differently cased name returned id %d, want %d", again, id)
+	}
+	if _, err := store.UpsertFollowedUser("alice", "new", ""); err != nil {
+		return err
+	}
+
+	user, err := store.GetFollowedUser("SPEZ")
+	if err != nil {
+		return err
+	}
+	if user.Name != "spez" || user.Listing != "top" || user.TimeWindow != "month" {
+		return fmt.Errorf("followed user is %+v", user)
+	}
+
+	users, err := store.GetAllFollowedUsers()
+	if err != nil {
+		return err
+	}
+	if len(users) != 2 || users[0].Name != "alice" || users[1].Name != "spez" {
+		return fmt.Errorf("got followed users %+v, want alice and spez", users)
+	}
+
+	// Pages list users in the order they were followed
+	page, err := store.GetFollowedUsers(database.Paginate{Limit: 1})
+	if err != nil {
+		return err
+	}
+	if len(page.Items) != 1 || page.Items[0].Name != "spez" || page.Next == "" {
+		return fmt.Errorf("first page of followed users is %+v, want spez and a cursor", page)
+	}
+	page, err = store.GetFollowedUsers(database.Paginate{Limit: 1, Cursor: page.Next})
+	if err != nil {
+		return err
+	}
+	if len(page.Items) != 1 || page.Items[0].Name != "alice" || page.Next != "" {
+		return fmt.Errorf("last page of followed users is %+v, want only alice", page)
+	}
+	return nil
+}
+
+func checkSchedules(store database.Store) error {
+	if _, err := store.GetSchedule(999999); !errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("getting a missing schedule returned %v, want ErrNotFound", err)
+	}
+	if err := store.SetSchedulePaused(999999, true); !errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("pausing a missing schedule returned %v, want ErrNotFound", err)
+	}
+
+	nextRunAt := baseTime.Add(time.Hour)
+	id, err := store.EnsureSchedule("user", "Spez", "new", "", nextRunAt)
+	if err != nil {
+		return err
+	}
+	again, err := store.EnsureSchedule("user", "spez", "new", "", baseTime.Add(2*time.Hour))
+	if err != nil {
+		return err
+	}
+	if again != id {
+		return fmt.Errorf("ensuring a differently cased name returned id %d, want %d", again, id)
+	}
+
+	// A later next run keeps the earlier one
+	if _, err := store.UpsertSchedule("user", "SPEZ", "new", "", 30*time.Minute, baseTime.Add(3*time.Hour)); err != nil {
+		return err
+	}
+	schedule, err := store.GetSchedule(id)
+	if err != nil {
+		return err
+	}
+	if schedule.Interval != 30*time.Minute || !schedule.NextRunAt.Equal(nextRunAt) || schedule.Paused || schedule.LastRunAt != nil {
+		return fmt.Errorf("schedule is %+v", schedule)
+	}
+
+	other, err := store.EnsureSchedule("subreddit", "golang", "", "", baseTime.Add(4*time.Hour))
+	if err != nil {
+		return err
+	}
+	all, err := store.GetAllSchedules()
+	if err != nil {
+		return err
+	}
+	if len(all) != 2 || all[0].Id != id || all[1].Id != other {
+		return fmt.Errorf("got %d schedules, want both ordered by next run", len(all))
+	}
+
+	due, err := store.GetDueSchedules(nextRunAt)
+	if err != nil {
+		return err
+	}
+	if len(due) != 1 || due[0].Id != id {
+		return fmt.Errorf("got %d due schedules, want only %d", len(due), id)
+	}
+	if err := store.SetSchedulePaused(id, true); err != nil {
+		return err
+	}
+	due, err = store.GetDueSchedules(nextRunAt)
+	if err != nil {
+		return err
+	}
+	if len(due) != 0 {
+		return fmt.Errorf("a paused schedule is due")
+	}
+
+	ranAt := nextRunAt.Add(time.Minute)
+	if err := store.RecordScheduleRun(id, ranAt, ranAt.Add(time.Hour), "rate limited"); err != nil {
+		return err
+	}
+	schedule, err = store.GetSchedule(id)
+	if err != nil {
+		return err
+	}
+	if schedule.LastRunAt == nil || !schedule.LastRunAt.Equal(ranAt) || !schedule.NextRunAt.Equal(ranAt.Add(time.Hour)) ||
+		schedule.LastError != "rate limited" || !schedule.Paused {
+		return fmt.Errorf("schedule after a run is %+v", schedule)
+	}
+
+	// A moved schedule keeps its interval and state
+	if err := store.MoveSchedule("user", "SPEZ", "new", "top", "week"); err != nil {
+		return err
+	}
+	schedule, err = store.GetSchedule(id)
+	if err != nil {
+		return err
+	}
+	if schedule.Listing != "top" || schedule.TimeWindow != "week" || schedule.Interval != 30*time.Minute ||
+		!schedule.Paused || schedule.LastError != "rate limited" {
+		return fmt.Errorf("moved schedule is %+v", schedule)
+	}
+
+	// Moving onto a listing that has a schedule updates that one and removes the moved one
+	existing, err := store.EnsureSchedule("user", "spez", "hot", "", baseTime.Add(5*time.Hour))
+	if err != nil {
+		return err
+	}
+	if err := store.MoveSchedule("user", "Spez", "top", "hot", "day"); err != nil {
+		return err
+	}
+	if _, err := store.GetSchedule(id); !errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("getting a schedule moved onto another returned %v, want ErrNotFound", err)
+	}
+	schedule, err = store.GetSchedule(existing)
+	if err != nil {
+		return err
+	}
+	if schedule.Listing != "hot" || schedule.TimeWindow != "day" {
+		return fmt.Errorf("schedule moved onto is %+v", schedule)
+	}
+	return nil
+}
+
+func postIds(posts []database.SubredditPostDao) []string {
+	ids := make([]string, len(posts))
+	for i, post := range posts {
+		ids[i] = post.PostId
+	}
+	return ids
+}
@@ -13,6 +13,10 @@ import (
 const (
 	dbFileName = "hecate.db"
 	dirPerms   = 0755
+	// dbOptions let concurrent ingestion workers read while one writes and wait for
+	// the write lock instead of failing with "database is locked". Transactions take
+	// the write lock up front so they wait too, rather than failing when upgrading.
+	dbOptions = "?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate"
 )
 
 type DB struct {
@@ -31,7 +35,7 @@ func NewDB() (*DB, error) {
 	}
 
 	dbPath := filepath.Join(dataDir, dbFileName)
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open("sqlite3", dbPath+dbOptions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -44,49 +48,211 @@ func NewDB() (*DB, error) {
 	return &DB{db}, nil
 }
 
-// CreateTables creates the necessary tables in the database
-func (db *DB) CreateTables() error {
+// NewMemoryDB creates a database that only lives in memory, for checks that must not
+// touch the data directory. It uses a single connection, since every connection to
+// ":memory:" opens a database of its own.
+func NewMemoryDB() (*DB, error) {
+	db, err := sql.Open("sqlite3", ":memory:?_txlock=immediate")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open in-memory database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping in-memory database: %w", err)
+	}
+	return &DB{db}, nil
+}
+
+// adoptLegacySchema brings a database created before versioned migrations up to the
+// shape the first migration expects: it adds the columns introduced since the tables
+// were created and merges case-insensitive duplicate subreddits. Databases that already
+// track migrations, or have no tables yet, are left alone.
+func (db *DB) adoptLegacySchema() error {
+	tracked, err := db.tableExists("schema_migrations")
+	if err != nil {
+		return err
+	}
+	legacy, err := db.tableExists("subreddits")
+	if err != nil {
+		return err
+	}
+	if tracked || !legacy {
+		return nil
+	}
+
+	log.Println("Adopting database created before schema migrations")
+	if err := db.addMissingColumns(); err != nil {
+		return err
+	}
+	return db.mergeDuplicateSubreddits()
+}
+
+// mergeDuplicateSubreddits merges subreddits whose names only differ in case, moving
+// their posts under a single canonical name, and then enforces case-insensitive names
+// with a unique index. It only runs until that index exists.
+func (db *DB) mergeDuplicateSubreddits() error {
+	var indexCount int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_subreddits_name_nocase'`).Scan(&indexCount)
+	if err != nil {
+		return fmt.Errorf("failed to check subreddit name index: %w", err)
+	}
+	if indexCount > 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Keep the row resolved from about.json most recently, falling back to the oldest row
 	queries := []string{
-		`CREATE TABLE IF NOT EXISTS subreddits (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT UNIQUE NOT NULL,
-			num_subscribers INTEGER DEFAULT 0,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS posts (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			post_id TEXT UNIQUE NOT NULL,
-			subreddit_name TEXT NOT NULL,
-			title TEXT NOT NULL,
-			content TEXT,
-			discussion_url TEXT,
-			comment_count INTEGER,
-			upvotes INTEGER,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS comments (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			post_id INTEGER,
-			parent_comment_id INTEGER,
-			content TEXT NOT NULL,
-			comment_id TEXT UNIQUE NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (post_id) REFERENCES posts(id),
-			FOREIGN KEY (parent_comment_id) REFERENCES comments(id)
+		`CREATE TEMP TABLE canonical_subreddits AS
+			SELECT id, name FROM (
+				SELECT id, name, ROW_NUMBER() OVER (
+					PARTITION BY LOWER(name)
+					ORDER BY fullname IS NULL, updated_at DESC, id
+				) AS rank
+				FROM subreddits
+			) WHERE rank = 1`,
+		`UPDATE posts SET subreddit_name = (
+			SELECT c.name FROM canonical_subreddits c WHERE LOWER(c.name) = LOWER(posts.subreddit_name)
+		) WHERE EXISTS (
+			SELECT 1 FROM canonical_subreddits c
+			WHERE LOWER(c.name) = LOWER(posts.subreddit_name) AND c.name != posts.subreddit_name
 		)`,
+		`DELETE FROM subreddits WHERE id NOT IN (SELECT id FROM canonical_subreddits)`,
+		`DROP TABLE canonical_subreddits`,
+		`CREATE UNIQUE INDEX idx_subreddits_name_nocase ON subreddits(name COLLATE NOCASE)`,
+		`CREATE INDEX IF NOT EXISTS idx_posts_subreddit_name_nocase ON posts(subreddit_name COLLATE NOCASE, created_at)`,
 	}
-
 	for i, query := range queries {
-		if _, err := db.Exec(query); err != nil {
-			return fmt.Errorf("failed to create table (query %d): %w", i+1, err)
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to merge duplicate subreddits (query %d): %w", i+1, err)
 		}
 	}
 
-	log.Println("Successfully created all necessary tables")
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit subreddit merge: %w", err)
+	}
+	log.Println("Merged subreddits with case-insensitive duplicate names")
 	return nil
 }
 
+// columnAddition is a column added to a table after the table was first created
+type columnAddition struct {
+	table      string
+	column     string
+	definition string
+}
+
+// columnAdditions are the columns legacy databases may be missing
+var columnAdditions = []columnAddition{
+	{"comments", "author", "TEXT"},
+	{"comments", "upvotes", "INTEGER DEFAULT 0"},
+	{"comments", "depth", "INTEGER DEFAULT 0"},
+	{"comments", "posted_at", "TIMESTAMP"},
+	{"comments", "updated_at", "TIMESTAMP"},
+	{"posts", "fullname", "TEXT"},
+	{"posts", "author", "TEXT"},
+	{"posts", "url", "TEXT"},
+	{"posts", "domain", "TEXT"},
+	{"posts", "is_self", "BOOLEAN DEFAULT 0"},
+	{"posts", "thumbnail", "TEXT"},
+	{"posts", "flair", "TEXT"},
+	{"posts", "stickied", "BOOLEAN DEFAULT 0"},
+	{"posts", "pinned", "BOOLEAN DEFAULT 0"},
+	{"posts", "nsfw", "BOOLEAN DEFAULT 0"},
+	{"posts", "spoiler", "BOOLEAN DEFAULT 0"},
+	{"posts", "upvote_ratio", "REAL"},
+	{"posts", "crosspost_parents", "TEXT"},
+	{"subreddits", "fullname", "TEXT"},
+	{"subreddits", "title", "TEXT"},
+	{"subreddits", "public_description", "TEXT"},
+	{"subreddits", "subreddit_created_at", "TIMESTAMP"},
+	{"subreddits", "nsfw", "BOOLEAN DEFAULT 0"},
+	{"subreddits", "active_users", "INTEGER DEFAULT 0"},
+	{"subreddits", "icon_url", "TEXT"},
+	{"subreddits", "updated_at", "TIMESTAMP"},
+	// What a subreddit was subscribed with, which its schedule refreshes. Subreddits
+	// subscribed before these were stored have none, and comment_depth and comment_limit
+	// are NULL for subscriptions that do not ingest comments.
+	{"subreddits", "listing", "TEXT"},
+	{"subreddits", "time_window", "TEXT"},
+	{"subreddits", "post_limit", "INTEGER"},
+	{"subreddits", "max_posts", "INTEGER"},
+	{"subreddits", "comment_depth", "INTEGER"},
+	{"subreddits", "comment_limit", "INTEGER"},
+}
+
+// addMissingColumns adds every column in columnAdditions that an existing table does not have yet
+func (db *DB) addMissingColumns() error {
+	for _, addition := range columnAdditions {
+		tableExists, err := db.tableExists(addition.table)
+		if err != nil {
+			return err
+		}
+		if !tableExists {
+			continue
+		}
+
+		exists, err := db.columnExists(addition.table, addition.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", addition.table, addition.column, addition.definition)
+		if _, err := db.Exec(query); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", addition.table, addition.column, err)
+		}
+		log.Printf("Added column %s.%s", addition.table, addition.column)
+	}
+	return nil
+}
+
+// tableExists reports whether a table exists
+func (db *DB) tableExists(table string) (bool, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = $1`, table).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check table %s: %w", table, err)
+	}
+	return count > 0, nil
+}
+
+// columnExists reports whether a table has a column
+func (db *DB) columnExists(table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			columnType string
+			notNull    int
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return false, fmt.Errorf("failed to scan column of %s: %w", table, err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
 // Close closes the database connection
 func (db *DB) Close() error {
 	if err := db.DB.Close(); err != nil {
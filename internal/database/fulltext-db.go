@@ -0,0 +1,189 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/samratjha96/hecate/internal/searchquery"
+)
+
+const (
+	// SnippetStart and SnippetEnd surround the matched terms in search snippets. They are
+	// control characters so callers can escape the snippet before marking the matches up.
+	SnippetStart = "\x02"
+	SnippetEnd   = "\x03"
+
+	searchLimit   = 100
+	snippetTokens = 24
+)
+
+// SearchResultDao is a post matching a full-text search
+type SearchResultDao struct {
+	SubredditPostDao
+	// Snippet is the best matching fragment of the title or body, with the matched
+	// terms between SnippetStart and SnippetEnd
+	Snippet string
+	// Score is the relevance of the post, BM25 on SQLite and ts_rank_cd on PostgreSQL.
+	// SQLite builds without FTS5 weigh title matches 10 and body matches 1 per term.
+	// Higher is better.
+	Score float64
+}
+
+// searchScanner scans the snippet and score selected after PostColumns
+type searchScanner struct {
+	row    RowScanner
+	result *SearchResultDao
+}
+
+func (s searchScanner) Scan(dest ...any) error {
+	return s.row.Scan(append(dest, &s.result.Snippet, &s.result.Score)...)
+}
+
+// SearchPosts runs a search query written in the searchquery language, returning a
+// *searchquery.SyntaxError when it is malformed. Posts matching the query's text are
+// ranked with BM25, title matches weighing more than body matches, and queries that
+// only filter return the newest posts first. An empty subreddit searches across all
+// subreddits. Without the FTS5 index the text is matched with LIKE instead.
+func (db *DB) SearchPosts(query, subreddit string) ([]SearchResultDao, error) {
+	parsed, err := searchquery.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	indexed, err := db.tableExists("posts_fts")
+	if err != nil {
+		return nil, err
+	}
+	if !indexed {
+		return db.searchPostsWithoutIndex(parsed, subreddit)
+	}
+
+	// Rank the posts matching the query's text, the other matches come last
+	ranking := `SELECT NULL AS rowid, NULL AS snippet, NULL AS score WHERE 0`
+	var args []any
+	if match := parsed.Match(searchquery.SQLite); match != "" {
+		ranking = `
+			SELECT rowid,
+				snippet(posts_fts, -1, '` + SnippetStart + `', '` + SnippetEnd + `', '…', ` + fmt.Sprint(snippetTokens) + `) AS snippet,
+				-bm25(posts_fts, 10.0, 1.0) AS score
+			FROM posts_fts
+			WHERE posts_fts MATCH $1`
+		args = append(args, match)
+	}
+
+	compiled := parsed.Compile(searchquery.SQLite, len(args)+1)
+	args = append(args, compiled.Args...)
+	sqlQuery := `
+		SELECT ` + PostColumns + `, COALESCE(m.snippet, ''), COALESCE(m.score, 0)
+		FROM posts p
+		LEFT JOIN (` + ranking + `) m ON m.rowid = p.id
+		WHERE ` + compiled.Where
+	if subreddit != "" {
+		args = append(args, subreddit)
+		sqlQuery += fmt.Sprintf(` AND p.subreddit_name = $%d COLLATE NOCASE`, len(args))
+	}
+	sqlQuery += fmt.Sprintf(` ORDER BY m.score IS NULL, m.score DESC, p.created_at DESC LIMIT %d`, searchLimit)
+	return db.querySearchResults(sqlQuery, args)
+}
+
+// searchPostsWithoutIndex searches posts on SQLite builds without FTS5, matching text with
+// LIKE and scoring each term 10 for a title match and 1 for a body match
+func (db *DB) searchPostsWithoutIndex(parsed *searchquery.Query, subreddit string) ([]SearchResultDao, error) {
+	terms := parsed.Terms()
+	score := "0"
+	var args []any
+	for _, term := range terms {
+		args = append(args, searchquery.LikePattern(term))
+		score += fmt.Sprintf(` + 10.0 * (p.title LIKE $%[1]d ESCAPE '\') + (COALESCE(p.content, '') LIKE $%[1]d ESCAPE '\')`, len(args))
+	}
+
+	compiled := parsed.Compile(searchquery.SQLiteLike, len(args)+1)
+	args = append(args, compiled.Args...)
+	sqlQuery := `
+		SELECT ` + PostColumns + `, '', ` + score + ` AS score
+		FROM posts p
+		WHERE ` + compiled.Where
+	if subreddit != "" {
+		args = append(args, subreddit)
+		sqlQuery += fmt.Sprintf(` AND p.subreddit_name = $%d COLLATE NOCASE`, len(args))
+	}
+	sqlQuery += fmt.Sprintf(` ORDER BY score DESC, p.created_at DESC LIMIT %d`, searchLimit)
+
+	results, err := db.querySearchResults(sqlQuery, args)
+	if err != nil || len(terms) == 0 {
+		return results, err
+	}
+	matcher := termMatcher(terms)
+	for i := range results {
+		results[i].Snippet = likeSnippet(matcher, results[i].Title, results[i].Content)
+	}
+	return results, nil
+}
+
+// querySearchResults runs a search selecting PostColumns, a snippet and a score
+func (db *DB) querySearchResults(sqlQuery string, args []any) ([]SearchResultDao, error) {
+	rows, err := db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search posts: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResultDao
+	for rows.Next() {
+		var result SearchResultDao
+		post, err := ScanPost(searchScanner{row: rows, result: &result})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan search result row: %w", err)
+		}
+		result.SubredditPostDao = post
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating search result rows: %w", err)
+	}
+
+	return results, nil
+}
+
+// termMatcher matches any of the terms, ignoring case
+func termMatcher(terms []string) *regexp.Regexp {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	return regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+}
+
+// likeSnippet builds a snippet the way FTS5 does for searches without the index: up to
+// snippetTokens words around the first match, from the title when it matches and the
+// body otherwise, with the matches marked
+func likeSnippet(matcher *regexp.Regexp, title, content string) string {
+	text := title
+	if !matcher.MatchString(text) {
+		text = content
+	}
+	match := matcher.FindStringIndex(text)
+	if match == nil {
+		return ""
+	}
+
+	words := strings.Fields(text)
+	// The words before the match, not counting the one the match starts in
+	first := len(strings.Fields(text[:match[0]]))
+	if match[0] > 0 && !strings.ContainsAny(text[match[0]-1:match[0]], " \t\n\r") {
+		first--
+	}
+	start := max(first-snippetTokens/4, 0)
+	end := min(start+snippetTokens, len(words))
+	start = max(end-snippetTokens, 0)
+
+	snippet := matcher.ReplaceAllString(strings.Join(words[start:end], " "), SnippetStart+"${0}"+SnippetEnd)
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(words) {
+		snippet += "…"
+	}
+	return snippet
+}
@@ -0,0 +1,333 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationFileName matches migration files such as 0001_core_schema.up.sql
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// migrationRequires matches the line of an up file naming the database feature the
+// migration needs, such as -- requires: fts5
+var migrationRequires = regexp.MustCompile(`(?m)^--\s*requires:\s*(\w+)\s*$`)
+
+// FeatureFTS5 is the SQLite full-text search extension
+const FeatureFTS5 = "fts5"
+
+// Migration is a versioned schema change with the SQL to apply and revert it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+	// Requires names the database feature the migration needs, empty when it needs none
+	Requires string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Migration
+	// AppliedAt is nil while the migration is pending
+	AppliedAt *time.Time
+}
+
+// loadMigrations reads the migrations in files ordered by version
+func loadMigrations(files fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		data, err := fs.ReadFile(files, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(data)
+			if requires := migrationRequires.FindStringSubmatch(migration.Up); requires != nil {
+				migration.Requires = requires[1]
+			}
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrate applies every pending migration. It runs at startup, adopting databases
+// created before migrations were tracked.
+func (db *DB) Migrate() error {
+	_, err := db.MigrateUp(0)
+	return err
+}
+
+// MigrateUp applies up to steps pending migrations in order, all of them when steps
+// is 0, and returns how many were applied
+func (db *DB) MigrateUp(steps int) (int, error) {
+	if err := db.adoptLegacySchema(); err != nil {
+		return 0, err
+	}
+	migrations := db.migrations()
+	fts5, err := db.fts5Enabled()
+	if err != nil {
+		return 0, err
+	}
+	if !fts5 {
+		log.Printf("SQLite was built without FTS5, searching without a full-text index. Build with -tags sqlite_fts5 to enable it")
+		migrations = migrations.Without(FeatureFTS5)
+	}
+	return migrations.Up(steps)
+}
+
+// fts5Enabled reports whether SQLite was compiled with the FTS5 extension, which the
+// sqlite_fts5 build tag enables
+func (db *DB) fts5Enabled() (bool, error) {
+	var enabled bool
+	if err := db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&enabled); err != nil {
+		return false, fmt.Errorf("failed to check for FTS5: %w", err)
+	}
+	return enabled, nil
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first, and returns
+// how many were reverted
+func (db *DB) MigrateDown(steps int) (int, error) {
+	return db.migrations().Down(steps)
+}
+
+// MigrationStatus lists every migration with the time it was applied
+func (db *DB) MigrationStatus() ([]MigrationStatus, error) {
+	return db.migrations().Status()
+}
+
+// migrations returns the embedded SQLite migrations of the database
+func (db *DB) migrations() *Migrations {
+	// The directory is embedded, so it always exists
+	files, _ := fs.Sub(migrationFiles, "migrations")
+	return NewMigrations(db.DB, files)
+}
+
+// Migrations applies a set of versioned SQL migrations to a database, recording the
+// applied ones in the schema_migrations table
+type Migrations struct {
+	db    *sql.DB
+	files fs.FS
+	// unavailable holds the features the database lacks
+	unavailable map[string]bool
+}
+
+// NewMigrations creates a migration set from files named like 0001_core_schema.up.sql
+// and 0001_core_schema.down.sql at the root of files
+func NewMigrations(db *sql.DB, files fs.FS) *Migrations {
+	return &Migrations{db: db, files: files}
+}
+
+// Without returns a copy of the migration set for a database lacking features. Up skips
+// the pending migrations requiring them, so they are applied once the features are
+// available.
+func (m *Migrations) Without(features ...string) *Migrations {
+	unavailable := make(map[string]bool, len(m.unavailable)+len(features))
+	for feature := range m.unavailable {
+		unavailable[feature] = true
+	}
+	for _, feature := range features {
+		unavailable[feature] = true
+	}
+	return &Migrations{db: m.db, files: m.files, unavailable: unavailable}
+}
+
+// Up applies up to steps pending migrations in order, all of them when steps is 0, and
+// returns how many were applied. Each migration runs in its own transaction.
+func (m *Migrations) Up(steps int) (int, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, status := range statuses {
+		if m.unavailable[status.Requires] {
+			if status.AppliedAt != nil {
+				return 0, fmt.Errorf("migration %d_%s was applied with %s, which the database lacks", status.Version, status.Name, status.Requires)
+			}
+			log.Printf("Skipped migration %d_%s, it requires %s", status.Version, status.Name, status.Requires)
+			continue
+		}
+		if status.AppliedAt != nil {
+			continue
+		}
+		if steps > 0 && applied == steps {
+			break
+		}
+		if err := m.apply(status.Migration); err != nil {
+			return applied, err
+		}
+		applied++
+	}
+
+	if applied > 0 {
+		log.Printf("Applied %d migrations", applied)
+	}
+	return applied, nil
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns how many
+// were reverted
+func (m *Migrations) Down(steps int) (int, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+
+	reverted := 0
+	for i := len(statuses) - 1; i >= 0 && reverted < steps; i-- {
+		if statuses[i].AppliedAt == nil {
+			continue
+		}
+		if err := m.revert(statuses[i].Migration); err != nil {
+			return reverted, err
+		}
+		reverted++
+	}
+	return reverted, nil
+}
+
+// Status lists every migration with the time it was applied
+func (m *Migrations) Status() ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(m.files)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.createTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	appliedAt := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+		appliedAt[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating applied migrations: %w", err)
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, migration := range migrations {
+		statuses[i] = MigrationStatus{Migration: migration}
+		if at, ok := appliedAt[migration.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
+
+// createTable creates the table tracking applied migrations
+func (m *Migrations) createTable() error {
+	_, err := m.db.Exec(`
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version INTEGER PRIMARY KEY,
+            name TEXT NOT NULL,
+            applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        )
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// apply runs a migration and records it in one transaction
+func (m *Migrations) apply(migration Migration) error {
+	return m.inTransaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(migration.Up); err != nil {
+			return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, migration.Version, migration.Name); err != nil {
+			return fmt.Errorf("failed to record migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+		return nil
+	})
+}
+
+// revert reverts a migration and forgets it in one transaction
+func (m *Migrations) revert(migration Migration) error {
+	if migration.Down == "" {
+		return fmt.Errorf("migration %d_%s cannot be reverted, it has no down file", migration.Version, migration.Name)
+	}
+	return m.inTransaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(migration.Down); err != nil {
+			return fmt.Errorf("failed to revert migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		if _, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, migration.Version); err != nil {
+			return fmt.Errorf("failed to forget migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		log.Printf("Reverted migration %d_%s", migration.Version, migration.Name)
+		return nil
+	})
+}
+
+// inTransaction runs fn in a transaction, committing when it succeeds
+func (m *Migrations) inTransaction(fn func(tx *sql.Tx) error) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
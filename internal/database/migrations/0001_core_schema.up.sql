@@ -0,0 +1,70 @@
+-- Subreddits, posts and comments as of the first versioned schema. Every statement
+-- tolerates existing objects so legacy databases adopted at startup are carried forward.
+CREATE TABLE IF NOT EXISTS subreddits (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT UNIQUE NOT NULL,
+    num_subscribers INTEGER DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    fullname TEXT,
+    title TEXT,
+    public_description TEXT,
+    subreddit_created_at TIMESTAMP,
+    nsfw BOOLEAN DEFAULT 0,
+    active_users INTEGER DEFAULT 0,
+    icon_url TEXT,
+    updated_at TIMESTAMP,
+    -- What the subreddit was subscribed with, which its schedule refreshes. Both comment
+    -- columns are NULL for subscriptions that do not ingest comments.
+    listing TEXT,
+    time_window TEXT,
+    post_limit INTEGER,
+    max_posts INTEGER,
+    comment_depth INTEGER,
+    comment_limit INTEGER
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_subreddits_name_nocase ON subreddits(name COLLATE NOCASE);
+
+CREATE TABLE IF NOT EXISTS posts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id TEXT UNIQUE NOT NULL,
+    subreddit_name TEXT NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT,
+    discussion_url TEXT,
+    comment_count INTEGER,
+    upvotes INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    fullname TEXT,
+    author TEXT,
+    url TEXT,
+    domain TEXT,
+    is_self BOOLEAN DEFAULT 0,
+    thumbnail TEXT,
+    flair TEXT,
+    stickied BOOLEAN DEFAULT 0,
+    pinned BOOLEAN DEFAULT 0,
+    nsfw BOOLEAN DEFAULT 0,
+    spoiler BOOLEAN DEFAULT 0,
+    upvote_ratio REAL,
+    crosspost_parents TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_posts_subreddit_name_nocase ON posts(subreddit_name COLLATE NOCASE, created_at);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER,
+    parent_comment_id INTEGER,
+    content TEXT NOT NULL,
+    comment_id TEXT UNIQUE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    author TEXT,
+    upvotes INTEGER DEFAULT 0,
+    depth INTEGER DEFAULT 0,
+    posted_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    FOREIGN KEY (post_id) REFERENCES posts(id),
+    FOREIGN KEY (parent_comment_id) REFERENCES comments(id)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS post_sources;
+DROP TABLE IF EXISTS saved_searches;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    query TEXT NOT NULL,
+    subreddit TEXT NOT NULL DEFAULT '' COLLATE NOCASE,
+    sort TEXT NOT NULL,
+    time_window TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (query, subreddit)
+);
+
+-- Records which subscriptions ingested a post, so a post can belong to several
+CREATE TABLE IF NOT EXISTS post_sources (
+    post_id TEXT NOT NULL,
+    source_type TEXT NOT NULL,
+    source_name TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (source_type, source_name, post_id)
+);
@@ -0,0 +1,2 @@
+DELETE FROM post_sources WHERE source_type = 'user';
+DROP TABLE IF EXISTS followed_users;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS schedules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    name TEXT NOT NULL COLLATE NOCASE,
+    listing TEXT NOT NULL DEFAULT '',
+    time_window TEXT NOT NULL DEFAULT '',
+    -- NULL falls back to the default interval of the listing type
+    interval_seconds INTEGER,
+    paused BOOLEAN NOT NULL DEFAULT 0,
+    next_run_at TIMESTAMP NOT NULL,
+    last_run_at TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (kind, name, listing)
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_posts_subreddit_updated_at;
+DROP INDEX IF EXISTS idx_posts_subreddit_comment_count;
+DROP INDEX IF EXISTS idx_posts_subreddit_upvotes;
@@ -0,0 +1,14 @@
+-- Listings compare creation times as stored text, so store them in UTC in the format
+-- the driver writes whole-second UTC times in. Times without a zone were written in UTC
+-- already, and Reddit reports post times to the second.
+UPDATE posts SET created_at = strftime('%Y-%m-%d %H:%M:%S+00:00', created_at)
+WHERE strftime('%Y-%m-%d %H:%M:%S+00:00', created_at) IS NOT NULL;
+
+-- Listings sort by these columns and never see NULLs in them
+UPDATE posts SET upvotes = 0 WHERE upvotes IS NULL;
+UPDATE posts SET comment_count = 0 WHERE comment_count IS NULL;
+UPDATE posts SET updated_at = created_at WHERE updated_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_posts_subreddit_upvotes ON posts(subreddit_name COLLATE NOCASE, upvotes);
+CREATE INDEX IF NOT EXISTS idx_posts_subreddit_comment_count ON posts(subreddit_name COLLATE NOCASE, comment_count);
+CREATE INDEX IF NOT EXISTS idx_posts_subreddit_updated_at ON posts(subreddit_name COLLATE NOCASE, updated_at);
@@ -1,23 +1,81 @@
 package database
 
-import "log"
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor was not issued by a listing
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Paginate selects a page of a keyset-paginated listing
+type Paginate struct {
+	Limit int
+	// Cursor is the Next cursor of the previous page, empty for the first page
+	Cursor string
+}
 
 // PaginatedResult represents a generic paginated result
 type PaginatedResult[T any] struct {
-	Items    []T
-	NextPage int
+	Items []T
+	// Next is the cursor of the following page, empty on the last page
+	Next string
+}
+
+// Cursor is the position of the last row of a page: the value of the column the listing
+// is sorted by, if it is not sorted by id alone, and the id that breaks ties. Listings
+// that can be sorted several ways also record the order the page was sorted in. Clients
+// only ever see it encoded.
+type Cursor struct {
+	Key   string `json:"k,omitempty"`
+	Id    int64  `json:"i"`
+	Order string `json:"o,omitempty"`
+}
+
+// Encode returns the opaque form of the cursor handed to clients
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c) // A struct of strings and an int always marshals
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor returned by Encode
+func DecodeCursor(encoded string) (Cursor, error) {
+	var c Cursor
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return c, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return c, nil
+}
+
+// PageOf trims rows fetched with a limit of pagination.Limit+1 to a page, setting Next to
+// the cursor of its last row when the extra row shows another page follows
+func PageOf[T any](rows []T, cursors []Cursor, pagination Paginate) PaginatedResult[T] {
+	if len(rows) <= pagination.Limit {
+		return PaginatedResult[T]{Items: rows}
+	}
+	return PaginatedResult[T]{
+		Items: rows[:pagination.Limit],
+		Next:  cursors[pagination.Limit-1].Encode(),
+	}
 }
 
 // PaginationFetcher is a function type that fetches paginated data
-type PaginationFetcher[T any] func(page, limit int) (PaginatedResult[T], error)
+type PaginationFetcher[T any] func(pagination Paginate) (PaginatedResult[T], error)
 
-// FetchAll retrieves all items using pagination
-func FetchAll[T any](fetcher PaginationFetcher[T], initialPage, pageSize int) ([]T, error) {
+// FetchAll retrieves all items by following the cursors of pages of pageSize items
+func FetchAll[T any](fetcher PaginationFetcher[T], pageSize int) ([]T, error) {
 	var allItems []T
-	currentPage := initialPage
+	pagination := Paginate{Limit: pageSize}
 
 	for {
-		result, err := fetcher(currentPage, pageSize)
+		result, err := fetcher(pagination)
 		if err != nil {
 			log.Printf("Failed to fetch items: %v", err)
 			return nil, err
@@ -25,11 +83,11 @@ func FetchAll[T any](fetcher PaginationFetcher[T], initialPage, pageSize int) ([
 
 		allItems = append(allItems, result.Items...)
 
-		if result.NextPage == currentPage {
+		if result.Next == "" {
 			break
 		}
 
-		currentPage = result.NextPage
+		pagination.Cursor = result.Next
 	}
 
 	return allItems, nil
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidPostQuery is returned for post queries that cannot be compiled
+var ErrInvalidPostQuery = errors.New("invalid post query")
+
+// PostSort is the order a listing of posts is sorted in
+type PostSort string
+
+const (
+	// SortCreated sorts posts by when they were posted
+	SortCreated PostSort = "created"
+	// SortUpdated sorts posts by when they were last ingested
+	SortUpdated PostSort = "updated"
+	// SortUpvotes sorts posts by their score
+	SortUpvotes PostSort = "upvotes"
+	// SortComments sorts posts by their comment count
+	SortComments PostSort = "comments"
+)
+
+// PostSorts are the sorts a listing of posts accepts
+var PostSorts = []PostSort{SortCreated, SortUpdated, SortUpvotes, SortComments}
+
+// postSortColumns are the columns of posts p each sort orders by. Migrations backfill
+// them, so none of them are NULL and each has an index led by the subreddit name.
+var postSortColumns = map[PostSort]string{
+	SortCreated:  "p.created_at",
+	SortUpdated:  "p.updated_at",
+	SortUpvotes:  "p.upvotes",
+	SortComments: "p.comment_count",
+}
+
+// PostQuery sorts and filters a listing of posts. The zero value lists every post
+// newest first.
+type PostQuery struct {
+	// Sort defaults to SortCreated
+	Sort      PostSort
+	Ascending bool
+
+	// MinScore and MinComments are inclusive lower bounds, nil when unset
+	MinScore    *int
+	MinComments *int
+	// After and Before bound when posts were made, After inclusive and Before
+	// exclusive. Zero times are unset.
+	After  time.Time
+	Before time.Time
+	// Flair matches the flair text exactly, ignoring case
+	Flair string
+	// IsSelf keeps only self posts when true and only link posts when false
+	IsSelf *bool
+	Nsfw   *bool
+}
+
+// Validate returns an error wrapping ErrInvalidPostQuery if the query cannot be compiled
+func (q PostQuery) Validate() error {
+	if _, ok := postSortColumns[q.sortOrDefault()]; !ok {
+		return fmt.Errorf("%w: unknown sort %q", ErrInvalidPostQuery, q.Sort)
+	}
+	if q.MinComments != nil && *q.MinComments < 0 {
+		return fmt.Errorf("%w: minimum comments cannot be negative", ErrInvalidPostQuery)
+	}
+	if !q.After.IsZero() && !q.Before.IsZero() && !q.After.Before(q.Before) {
+		return fmt.Errorf("%w: the date range ends before it starts", ErrInvalidPostQuery)
+	}
+	return nil
+}
+
+func (q PostQuery) sortOrDefault() PostSort {
+	if q.Sort == "" {
+		return SortCreated
+	}
+	return q.Sort
+}
+
+// SortColumn returns the column of posts p the query sorts by
+func (q PostQuery) SortColumn() string {
+	return postSortColumns[q.sortOrDefault()]
+}
+
+// Direction returns the direction of the query's ORDER BY and the operator that keeps
+// the rows after a cursor in that direction
+func (q PostQuery) Direction() (direction, after string) {
+	if q.Ascending {
+		return "ASC", ">"
+	}
+	return "DESC", "<"
+}
+
+// Order names the sort and direction of the query. Cursors record the order they were
+// issued for, so that they are not used to page through another one.
+func (q PostQuery) Order() string {
+	direction, _ := q.Direction()
+	return fmt.Sprintf("%s.%s", q.sortOrDefault(), direction)
+}
+
+// SortsByCount reports whether the query sorts by a count rather than a time
+func (q PostQuery) SortsByCount() bool {
+	sort := q.sortOrDefault()
+	return sort == SortUpvotes || sort == SortComments
+}
+
+// CursorKey returns the sort key of a cursor to bind into a keyset condition, wrapping
+// ErrInvalidCursor if the cursor was issued for another order. Counts are returned as
+// int64 and times as the text they were scanned from.
+func (q PostQuery) CursorKey(cursor Cursor) (any, error) {
+	if cursor.Order != q.Order() {
+		return nil, fmt.Errorf("%w: issued for another sort order", ErrInvalidCursor)
+	}
+	if !q.SortsByCount() {
+		return cursor.Key, nil
+	}
+	key, err := strconv.ParseInt(cursor.Key, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return key, nil
+}
+
+// KeysetCondition returns the condition keeping the rows after the cursor of pagination,
+// with its arguments appended to args, or an empty condition on the first page.
+// keyPlaceholder formats the placeholder of the cursor's sort key from its number, so
+// that backends can cast it.
+func (q PostQuery) KeysetCondition(pagination Paginate, args []any, keyPlaceholder string) (string, []any, error) {
+	if pagination.Cursor == "" {
+		return "", args, nil
+	}
+	cursor, err := DecodeCursor(pagination.Cursor)
+	if err != nil {
+		return "", args, err
+	}
+	key, err := q.CursorKey(cursor)
+	if err != nil {
+		return "", args, err
+	}
+	_, after := q.Direction()
+	args = append(args, key, cursor.Id)
+	return fmt.Sprintf(` AND (%s, p.id) %s (`+keyPlaceholder+`, $%d)`, q.SortColumn(), after, len(args)-1, len(args)), args, nil
+}
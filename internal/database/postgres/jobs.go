@@ -0,0 +1,141 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/samratjha96/hecate/internal/database"
+)
+
+// maxListedJobs is the number of most recent jobs GetRecentJobs returns
+const maxListedJobs = 100
+
+const jobColumns = `id, kind, status, request, completed, total, COALESCE(result, ''), error, created_at, started_at, finished_at`
+
+// scanJob scans a row selected with jobColumns
+func scanJob(row database.RowScanner) (database.JobDao, error) {
+	var j database.JobDao
+	var startedAt, finishedAt sql.NullTime
+	if err := row.Scan(&j.Id, &j.Kind, &j.Status, &j.Request, &j.Completed, &j.Total, &j.Result, &j.Error,
+		&j.CreatedAt, &startedAt, &finishedAt); err != nil {
+		return j, err
+	}
+	if startedAt.Valid {
+		j.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		j.FinishedAt = &finishedAt.Time
+	}
+	return j, nil
+}
+
+// CreateJob stores a new job with the given status and JSON request, and returns its id
+func (db *DB) CreateJob(kind, status, request string) (int64, error) {
+	var id int64
+	query := `
+        INSERT INTO jobs (kind, status, request)
+        VALUES ($1, $2, $3)
+        RETURNING id
+    `
+	if err := db.QueryRow(query, kind, status, request).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to create %s job: %w", kind, err)
+	}
+	return id, nil
+}
+
+// GetJob retrieves a job by id
+func (db *DB) GetJob(id int64) (database.JobDao, error) {
+	j, err := scanJob(db.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return j, fmt.Errorf("job %d: %w", id, database.ErrNotFound)
+	}
+	if err != nil {
+		return j, fmt.Errorf("failed to get job %d: %w", id, err)
+	}
+	return j, nil
+}
+
+// GetRecentJobs retrieves the most recent jobs, newest first
+func (db *DB) GetRecentJobs() ([]database.JobDao, error) {
+	rows, err := db.Query(`SELECT `+jobColumns+` FROM jobs ORDER BY id DESC LIMIT $1`, maxListedJobs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []database.JobDao
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job row: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating job rows: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// StartJob marks a job as running
+func (db *DB) StartJob(id int64, status string, startedAt time.Time) error {
+	_, err := db.Exec(`UPDATE jobs SET status = $1, started_at = $2 WHERE id = $3`, status, startedAt.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to start job %d: %w", id, err)
+	}
+	return nil
+}
+
+// UpdateJobProgress records how many of a job's items are done
+func (db *DB) UpdateJobProgress(id int64, completed, total int) error {
+	_, err := db.Exec(`UPDATE jobs SET completed = $1, total = $2 WHERE id = $3`, completed, total, id)
+	if err != nil {
+		return fmt.Errorf("failed to update progress of job %d: %w", id, err)
+	}
+	return nil
+}
+
+// FinishJob records the final status, JSON result and error of a job
+func (db *DB) FinishJob(id int64, status, result, jobError string, finishedAt time.Time) error {
+	var resultValue sql.NullString
+	if result != "" {
+		resultValue = sql.NullString{String: result, Valid: true}
+	}
+	_, err := db.Exec(`
+        UPDATE jobs
+        SET status = $1, result = $2, error = $3, finished_at = $4
+        WHERE id = $5
+    `, status, resultValue, jobError, finishedAt.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to finish job %d: %w", id, err)
+	}
+	return nil
+}
+
+// FailUnfinishedJobs marks every job that is still in one of the given statuses as
+// failed, returning how many there were
+func (db *DB) FailUnfinishedJobs(failedStatus, jobError string, unfinished ...string) (int64, error) {
+	if len(unfinished) == 0 {
+		return 0, nil
+	}
+	query := `UPDATE jobs SET status = $1, error = $2, finished_at = $3 WHERE status IN (`
+	args := []any{failedStatus, jobError, time.Now().UTC()}
+	for i, status := range unfinished {
+		if i > 0 {
+			query += ", "
+		}
+		args = append(args, status)
+		query += fmt.Sprintf("$%d", len(args))
+	}
+	query += ")"
+
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail unfinished jobs: %w", err)
+	}
+	return result.RowsAffected()
+}
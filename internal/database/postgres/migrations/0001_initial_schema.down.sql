@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS jobs;
+DROP TABLE IF EXISTS schedules;
+DROP TABLE IF EXISTS followed_users;
+DROP TABLE IF EXISTS post_sources;
+DROP TABLE IF EXISTS saved_searches;
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS posts;
+DROP TABLE IF EXISTS subreddits;
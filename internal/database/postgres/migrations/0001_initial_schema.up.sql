@@ -0,0 +1,138 @@
+-- The schema of every table as of the SQLite migrations 0001 to 0006. Names that SQLite
+-- compares with COLLATE NOCASE are unique and looked up by LOWER(name) instead.
+CREATE TABLE IF NOT EXISTS subreddits (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    num_subscribers INTEGER DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    fullname TEXT,
+    title TEXT,
+    public_description TEXT,
+    subreddit_created_at TIMESTAMPTZ,
+    nsfw BOOLEAN DEFAULT FALSE,
+    active_users INTEGER DEFAULT 0,
+    icon_url TEXT,
+    updated_at TIMESTAMPTZ,
+    -- What the subreddit was subscribed with, which its schedule refreshes. Both comment
+    -- columns are NULL for subscriptions that do not ingest comments.
+    listing TEXT,
+    time_window TEXT,
+    post_limit INTEGER,
+    max_posts INTEGER,
+    comment_depth INTEGER,
+    comment_limit INTEGER
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_subreddits_name_lower ON subreddits (LOWER(name));
+
+CREATE TABLE IF NOT EXISTS posts (
+    id BIGSERIAL PRIMARY KEY,
+    post_id TEXT UNIQUE NOT NULL,
+    subreddit_name TEXT NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT,
+    discussion_url TEXT,
+    comment_count INTEGER,
+    upvotes INTEGER,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    fullname TEXT,
+    author TEXT,
+    url TEXT,
+    domain TEXT,
+    is_self BOOLEAN DEFAULT FALSE,
+    thumbnail TEXT,
+    flair TEXT,
+    stickied BOOLEAN DEFAULT FALSE,
+    pinned BOOLEAN DEFAULT FALSE,
+    nsfw BOOLEAN DEFAULT FALSE,
+    spoiler BOOLEAN DEFAULT FALSE,
+    upvote_ratio DOUBLE PRECISION,
+    crosspost_parents TEXT,
+    -- Title matches weigh more than body matches when ranking search results
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', title), 'A') || setweight(to_tsvector('english', COALESCE(content, '')), 'B')
+    ) STORED
+);
+
+CREATE INDEX IF NOT EXISTS idx_posts_subreddit_name_lower ON posts (LOWER(subreddit_name), created_at);
+CREATE INDEX IF NOT EXISTS idx_posts_search_vector ON posts USING GIN (search_vector);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id BIGSERIAL PRIMARY KEY,
+    post_id BIGINT REFERENCES posts (id),
+    parent_comment_id BIGINT REFERENCES comments (id),
+    content TEXT NOT NULL,
+    comment_id TEXT UNIQUE NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    author TEXT,
+    upvotes INTEGER DEFAULT 0,
+    depth INTEGER DEFAULT 0,
+    posted_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments (post_id);
+
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id BIGSERIAL PRIMARY KEY,
+    query TEXT NOT NULL,
+    subreddit TEXT NOT NULL DEFAULT '',
+    sort TEXT NOT NULL,
+    time_window TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_saved_searches_query_subreddit ON saved_searches (query, LOWER(subreddit));
+
+-- Records which subscriptions ingested a post, so a post can belong to several
+CREATE TABLE IF NOT EXISTS post_sources (
+    post_id TEXT NOT NULL,
+    source_type TEXT NOT NULL,
+    source_name TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (source_type, source_name, post_id)
+);
+
+CREATE TABLE IF NOT EXISTS followed_users (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    listing TEXT NOT NULL,
+    time_window TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_followed_users_name_lower ON followed_users (LOWER(name));
+
+CREATE TABLE IF NOT EXISTS schedules (
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    name TEXT NOT NULL,
+    listing TEXT NOT NULL DEFAULT '',
+    time_window TEXT NOT NULL DEFAULT '',
+    interval_seconds BIGINT,
+    paused BOOLEAN NOT NULL DEFAULT FALSE,
+    next_run_at TIMESTAMPTZ NOT NULL,
+    last_run_at TIMESTAMPTZ,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_schedules_kind_name_listing ON schedules (kind, LOWER(name), listing);
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    status TEXT NOT NULL,
+    request TEXT NOT NULL DEFAULT '{}',
+    completed INTEGER NOT NULL DEFAULT 0,
+    total INTEGER NOT NULL DEFAULT 0,
+    result TEXT,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
@@ -0,0 +1,8 @@
+-- Listings sort by these columns and never see NULLs in them
+UPDATE posts SET upvotes = 0 WHERE upvotes IS NULL;
+UPDATE posts SET comment_count = 0 WHERE comment_count IS NULL;
+UPDATE posts SET updated_at = created_at WHERE updated_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_posts_subreddit_upvotes ON posts (LOWER(subreddit_name), upvotes);
+CREATE INDEX IF NOT EXISTS idx_posts_subreddit_comment_count ON posts (LOWER(subreddit_name), comment_count);
+CREATE INDEX IF NOT EXISTS idx_posts_subreddit_updated_at ON posts (LOWER(subreddit_name), updated_at);
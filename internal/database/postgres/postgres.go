@@ -0,0 +1,131 @@
+// Package postgres implements database.Store on PostgreSQL
+package postgres
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+
+	_ "github.com/lib/pq"
+	"github.com/samratjha96/hecate/internal/database"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+type DB struct {
+	*sql.DB
+}
+
+var _ database.Store = (*DB)(nil)
+
+// NewDB connects to the PostgreSQL database at DATABASE_URL
+func NewDB() (*DB, error) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		return nil, fmt.Errorf("DATABASE_URL environment variable is not set")
+	}
+	return Open(databaseURL)
+}
+
+// Open connects to a PostgreSQL database given a postgres:// URL or key=value connection string
+func Open(databaseURL string) (*DB, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	log.Println("Successfully connected to PostgreSQL database")
+	return &DB{db}, nil
+}
+
+// OpenSchema connects to a PostgreSQL database with every table in the given schema,
+// creating the schema when it does not exist
+func OpenSchema(databaseURL, schema string) (*DB, error) {
+	db, err := Open(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(`CREATE SCHEMA IF NOT EXISTS ` + quoteIdentifier(schema))
+	db.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema %s: %w", schema, err)
+	}
+	return Open(withSearchPath(databaseURL, schema))
+}
+
+// DropSchema drops a schema created by OpenSchema and everything in it
+func (db *DB) DropSchema(schema string) error {
+	if _, err := db.Exec(`DROP SCHEMA IF EXISTS ` + quoteIdentifier(schema) + ` CASCADE`); err != nil {
+		return fmt.Errorf("failed to drop schema %s: %w", schema, err)
+	}
+	return nil
+}
+
+// withSearchPath sets the search_path run-time parameter of a connection string
+func withSearchPath(databaseURL, schema string) string {
+	if strings.HasPrefix(databaseURL, "postgres://") || strings.HasPrefix(databaseURL, "postgresql://") {
+		u, err := url.Parse(databaseURL)
+		if err == nil {
+			query := u.Query()
+			query.Set("search_path", schema)
+			u.RawQuery = query.Encode()
+			return u.String()
+		}
+	}
+	return databaseURL + " search_path=" + schema
+}
+
+// quoteIdentifier quotes a PostgreSQL identifier
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Migrate applies every pending migration
+func (db *DB) Migrate() error {
+	_, err := db.MigrateUp(0)
+	return err
+}
+
+// MigrateUp applies up to steps pending migrations in order, all of them when steps
+// is 0, and returns how many were applied
+func (db *DB) MigrateUp(steps int) (int, error) {
+	return db.migrations().Up(steps)
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first, and returns
+// how many were reverted
+func (db *DB) MigrateDown(steps int) (int, error) {
+	return db.migrations().Down(steps)
+}
+
+// MigrationStatus lists every migration with the time it was applied
+func (db *DB) MigrationStatus() ([]database.MigrationStatus, error) {
+	return db.migrations().Status()
+}
+
+// migrations returns the embedded PostgreSQL migrations of the database
+func (db *DB) migrations() *database.Migrations {
+	// The directory is embedded, so it always exists
+	files, _ := fs.Sub(migrationFiles, "migrations")
+	return database.NewMigrations(db.DB, files)
+}
+
+// Close closes the database connection
+func (db *DB) Close() error {
+	if err := db.DB.Close(); err != nil {
+		return fmt.Errorf("failed to close database connection: %w", err)
+	}
+	log.Println("Database connection closed successfully")
+	return nil
+}
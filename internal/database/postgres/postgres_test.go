@@ -0,0 +1,34 @@
+package postgres_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/samratjha96/hecate/internal/database/conformance"
+	"github.com/samratjha96/hecate/internal/database/postgres"
+)
+
+// TestPostgresConformance runs the storage checks in a scratch schema of the database at
+// DATABASE_URL, dropped afterwards
+func TestPostgresConformance(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+
+	schema := fmt.Sprintf("hecate_conformance_%d", time.Now().UnixNano())
+	db, err := postgres.OpenSchema(databaseURL, schema)
+	if err != nil {
+		t.Fatalf("OpenSchema: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.DropSchema(schema); err != nil {
+			t.Errorf("DropSchema: %v", err)
+		}
+		db.Close()
+	})
+
+	conformance.Run(t, db)
+}
@@ -0,0 +1,325 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/samratjha96/hecate/internal/database"
+	"github.com/samratjha96/hecate/internal/reddit"
+)
+
+// GetAllSubreddits retrieves all subreddits from the database
+func (db *DB) GetAllSubreddits() ([]database.SubredditDao, error) {
+	return database.FetchAll(db.GetSubreddits, database.DefaultLimit)
+}
+
+// GetSubreddits retrieves a page of subreddits in the order they were added
+func (db *DB) GetSubreddits(pagination database.Paginate) (database.PaginatedResult[database.SubredditDao], error) {
+	var after int64
+	if pagination.Cursor != "" {
+		cursor, err := database.DecodeCursor(pagination.Cursor)
+		if err != nil {
+			return database.PaginatedResult[database.SubredditDao]{}, err
+		}
+		after = cursor.Id
+	}
+
+	query := `
+        SELECT name, num_subscribers, COALESCE(title, ''), COALESCE(public_description, ''),
+               subreddit_created_at, COALESCE(nsfw, FALSE), COALESCE(active_users, 0), COALESCE(icon_url, ''),
+               ` + database.SubredditSubscriptionColumns + `, id
+        FROM subreddits
+        WHERE id > $1
+        ORDER BY id
+        LIMIT $2
+    `
+
+	rows, err := db.Query(query, after, pagination.Limit+1)
+	if err != nil {
+		return database.PaginatedResult[database.SubredditDao]{}, fmt.Errorf("failed to query subreddits: %w", err)
+	}
+	defer rows.Close()
+
+	var subreddits []database.SubredditDao
+	var cursors []database.Cursor
+	for rows.Next() {
+		var s database.SubredditDao
+		var cursor database.Cursor
+		var createdAt sql.NullTime
+		fields := []any{&s.Name, &s.NumberOfSubscribers, &s.Title, &s.PublicDescription,
+			&createdAt, &s.Nsfw, &s.ActiveUsers, &s.IconUrl}
+		fields = append(fields, database.SubredditSubscriptionFields(&s.Subscription)...)
+		if err := rows.Scan(append(fields, &cursor.Id)...); err != nil {
+			return database.PaginatedResult[database.SubredditDao]{}, fmt.Errorf("failed to scan subreddit row: %w", err)
+		}
+		s.CreatedAt = createdAt.Time
+		subreddits = append(subreddits, s)
+		cursors = append(cursors, cursor)
+	}
+
+	if err := rows.Err(); err != nil {
+		return database.PaginatedResult[database.SubredditDao]{}, fmt.Errorf("error iterating subreddit rows: %w", err)
+	}
+
+	return database.PageOf(subreddits, cursors, pagination), nil
+}
+
+// upsertSubredditQuery inserts or updates a subreddit, matching its name case-insensitively
+const upsertSubredditQuery = `
+        INSERT INTO subreddits (name, num_subscribers, fullname, title, public_description, subreddit_created_at,
+            nsfw, active_users, icon_url, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, CURRENT_TIMESTAMP)
+        ON CONFLICT ((LOWER(name)))
+        DO UPDATE SET
+            name = EXCLUDED.name,
+            num_subscribers = EXCLUDED.num_subscribers,
+            fullname = EXCLUDED.fullname,
+            title = EXCLUDED.title,
+            public_description = EXCLUDED.public_description,
+            subreddit_created_at = EXCLUDED.subreddit_created_at,
+            nsfw = EXCLUDED.nsfw,
+            active_users = EXCLUDED.active_users,
+            icon_url = EXCLUDED.icon_url,
+            updated_at = CURRENT_TIMESTAMP
+    `
+
+// upsertSubredditArgs returns the arguments of upsertSubredditQuery
+func upsertSubredditArgs(name string, about reddit.SubredditAbout) []any {
+	return []any{name, about.NumberOfSubscribers, about.Fullname, about.Title, about.PublicDescription,
+		about.CreatedAt, about.Nsfw, about.ActiveUsers, about.IconUrl}
+}
+
+// GetSubredditName looks up the canonical name of a stored subreddit, ignoring case
+func (db *DB) GetSubredditName(name string) (string, error) {
+	var canonical string
+	err := db.QueryRow(`SELECT name FROM subreddits WHERE LOWER(name) = LOWER($1)`, name).Scan(&canonical)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("subreddit %s: %w", name, database.ErrNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get subreddit %s: %w", name, err)
+	}
+	return canonical, nil
+}
+
+// UpdateSubscriberCount updates the subscriber count of an existing subreddit
+func (db *DB) UpdateSubscriberCount(name string, numberOfSubscribers int) error {
+	query := `
+        UPDATE subreddits
+        SET num_subscribers = $1, updated_at = CURRENT_TIMESTAMP
+        WHERE LOWER(name) = LOWER($2)
+    `
+	if _, err := db.Exec(query, numberOfSubscribers, name); err != nil {
+		return fmt.Errorf("failed to update subscribers of subreddit %s: %w", name, err)
+	}
+	return nil
+}
+
+// SetSubredditSubscription records the options a subreddit was subscribed with
+func (db *DB) SetSubredditSubscription(name string, subscription database.SubredditSubscriptionDao) error {
+	query := `
+        UPDATE subreddits
+        SET listing = $1, time_window = $2, post_limit = $3, max_posts = $4, comment_depth = $5, comment_limit = $6
+        WHERE LOWER(name) = LOWER($7)
+    `
+	args := append(database.SubredditSubscriptionArgs(subscription), name)
+	if _, err := db.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to set subscription of subreddit %s: %w", name, err)
+	}
+	return nil
+}
+
+// GetSubredditSubscription retrieves the options a subreddit was subscribed with
+func (db *DB) GetSubredditSubscription(name string) (database.SubredditSubscriptionDao, error) {
+	var subscription database.SubredditSubscriptionDao
+	query := `SELECT ` + database.SubredditSubscriptionColumns + ` FROM subreddits WHERE LOWER(name) = LOWER($1)`
+	err := db.QueryRow(query, name).Scan(database.SubredditSubscriptionFields(&subscription)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return subscription, fmt.Errorf("subreddit %s: %w", name, database.ErrNotFound)
+	}
+	if err != nil {
+		return subscription, fmt.Errorf("failed to get subscription of subreddit %s: %w", name, err)
+	}
+	return subscription, nil
+}
+
+// UpsertPost inserts or updates a post in the database, reporting whether the post was new
+func (db *DB) UpsertPost(post reddit.RedditPost, subredditName string) (bool, error) {
+	args, err := database.UpsertPostArgs(post, subredditName)
+	if err != nil {
+		return false, err
+	}
+
+	var inserted bool
+	if err := db.QueryRow(upsertPostQuery, args...).Scan(&inserted); err != nil {
+		return false, fmt.Errorf("failed to upsert post: %w", err)
+	}
+	log.Printf("Upserted post: %s for subreddit: %s", post.Title, subredditName)
+	return inserted, nil
+}
+
+// UpsertSubredditWithPosts upserts a subreddit and all of its posts in one transaction
+// using prepared statements, so a failure leaves nothing written. Only posts are counted.
+func (db *DB) UpsertSubredditWithPosts(name string, about reddit.SubredditAbout, posts []reddit.RedditPost) (database.UpsertCounts, error) {
+	var counts database.UpsertCounts
+
+	tx, err := db.Begin()
+	if err != nil {
+		return counts, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(upsertSubredditQuery, upsertSubredditArgs(name, about)...); err != nil {
+		return counts, fmt.Errorf("failed to upsert subreddit: %w", err)
+	}
+	counts, err = upsertPosts(tx, posts, name, "", "")
+	if err != nil {
+		return database.UpsertCounts{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return database.UpsertCounts{}, fmt.Errorf("failed to commit subreddit %s: %w", name, err)
+	}
+	log.Printf("Upserted subreddit %s with %d new and %d updated posts", name, counts.Inserted, counts.Updated)
+	return counts, nil
+}
+
+// UpsertPosts upserts posts in one transaction using prepared statements, so a failure
+// leaves nothing written. Posts are stored under subredditName, or their own subreddit
+// when it is empty, and linked to the subscription identified by sourceType and
+// sourceName when it is set.
+func (db *DB) UpsertPosts(posts []reddit.RedditPost, subredditName, sourceType, sourceName string) (database.UpsertCounts, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return database.UpsertCounts{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	counts, err := upsertPosts(tx, posts, subredditName, sourceType, sourceName)
+	if err != nil {
+		return database.UpsertCounts{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return database.UpsertCounts{}, fmt.Errorf("failed to commit posts: %w", err)
+	}
+	log.Printf("Upserted %d new and %d updated posts", counts.Inserted, counts.Updated)
+	return counts, nil
+}
+
+// upsertPosts writes posts and their sources in tx, counting the new and updated posts
+func upsertPosts(tx *sql.Tx, posts []reddit.RedditPost, subredditName, sourceType, sourceName string) (database.UpsertCounts, error) {
+	var counts database.UpsertCounts
+
+	upsertStmt, err := tx.Prepare(upsertPostQuery)
+	if err != nil {
+		return counts, fmt.Errorf("failed to prepare post upsert: %w", err)
+	}
+	defer upsertStmt.Close()
+
+	var sourceStmt *sql.Stmt
+	if sourceType != "" {
+		if sourceStmt, err = tx.Prepare(addPostSourceQuery); err != nil {
+			return counts, fmt.Errorf("failed to prepare post source: %w", err)
+		}
+		defer sourceStmt.Close()
+	}
+
+	for _, post := range posts {
+		name := subredditName
+		if name == "" {
+			name = post.Subreddit
+		}
+		args, err := database.UpsertPostArgs(post, name)
+		if err != nil {
+			return database.UpsertCounts{}, err
+		}
+		var inserted bool
+		if err := upsertStmt.QueryRow(args...).Scan(&inserted); err != nil {
+			return database.UpsertCounts{}, fmt.Errorf("failed to upsert post %s: %w", post.PostId, err)
+		}
+		if sourceStmt != nil {
+			if _, err := sourceStmt.Exec(post.PostId, sourceType, sourceName); err != nil {
+				return database.UpsertCounts{}, fmt.Errorf("failed to add %s source for post %s: %w", sourceType, post.PostId, err)
+			}
+		}
+
+		if inserted {
+			counts.Inserted++
+		} else {
+			counts.Updated++
+		}
+	}
+	return counts, nil
+}
+
+// upsertPostQuery inserts or updates a post and all of its metadata, returning whether
+// the row is new. xmax is only zero on rows the statement inserted.
+const upsertPostQuery = `
+        INSERT INTO posts (subreddit_name, post_id, title, content, discussion_url, comment_count, upvotes, created_at,
+            fullname, author, url, domain, is_self, thumbnail, flair, stickied, pinned, nsfw, spoiler, upvote_ratio, crosspost_parents)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+        ON CONFLICT (post_id) DO UPDATE SET
+            title = EXCLUDED.title,
+            content = EXCLUDED.content,
+            discussion_url = EXCLUDED.discussion_url,
+            comment_count = EXCLUDED.comment_count,
+            upvotes = EXCLUDED.upvotes,
+            created_at = EXCLUDED.created_at,
+            fullname = EXCLUDED.fullname,
+            author = EXCLUDED.author,
+            url = EXCLUDED.url,
+            domain = EXCLUDED.domain,
+            is_self = EXCLUDED.is_self,
+            thumbnail = EXCLUDED.thumbnail,
+            flair = EXCLUDED.flair,
+            stickied = EXCLUDED.stickied,
+            pinned = EXCLUDED.pinned,
+            nsfw = EXCLUDED.nsfw,
+            spoiler = EXCLUDED.spoiler,
+            upvote_ratio = EXCLUDED.upvote_ratio,
+            crosspost_parents = EXCLUDED.crosspost_parents,
+            updated_at = CURRENT_TIMESTAMP
+        RETURNING xmax = 0
+    `
+
+// GetSubredditPosts retrieves a page of the posts of a subreddit, sorted and filtered by
+// query. It returns an error wrapping database.ErrInvalidPostQuery when the query is invalid.
+func (db *DB) GetSubredditPosts(subredditName string, query database.PostQuery, pagination database.Paginate) (database.PaginatedResult[database.SubredditPostDao], error) {
+	if err := query.Validate(); err != nil {
+		return database.PaginatedResult[database.SubredditPostDao]{}, err
+	}
+	condition, args := database.PostQueryCondition(`LOWER(p.subreddit_name) = LOWER($1)`, []any{subredditName}, query)
+	page, err := db.getPostsPage(`posts p`, condition, args, query, pagination)
+	if err != nil {
+		return page, fmt.Errorf("failed to fetch posts for subreddit %s: %w", subredditName, err)
+	}
+	return page, nil
+}
+
+// getPostsPage retrieves a page of the posts selected from the posts p in the order of
+// query. The condition's placeholders are numbered from 1, the page's follow them.
+func (db *DB) getPostsPage(from, condition string, args []any, postQuery database.PostQuery, pagination database.Paginate) (database.PaginatedResult[database.SubredditPostDao], error) {
+	column := postQuery.SortColumn()
+	direction, _ := postQuery.Direction()
+	// Times are scanned into the cursor as RFC 3339 with nanoseconds, and cast back
+	keyType := `TIMESTAMPTZ`
+	if postQuery.SortsByCount() {
+		keyType = `BIGINT`
+	}
+	query := `SELECT ` + database.PostColumns + `, ` + column + `, p.id FROM ` + from + ` WHERE ` + condition
+	keyset, args, err := postQuery.KeysetCondition(pagination, args, `$%d::`+keyType)
+	if err != nil {
+		return database.PaginatedResult[database.SubredditPostDao]{}, err
+	}
+	args = append(args, pagination.Limit+1)
+	query += keyset + fmt.Sprintf(` ORDER BY %s %s, p.id %s LIMIT $%d`, column, direction, direction, len(args))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return database.PaginatedResult[database.SubredditPostDao]{}, fmt.Errorf("failed to query posts: %w", err)
+	}
+	defer rows.Close()
+	return database.ScanPostPage(rows, postQuery, pagination)
+}
@@ -0,0 +1,204 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/samratjha96/hecate/internal/database"
+)
+
+const scheduleColumns = `id, kind, name, listing, time_window, COALESCE(interval_seconds, 0), paused, next_run_at, last_run_at, last_error`
+
+// scanSchedule scans a row selected with scheduleColumns
+func scanSchedule(row database.RowScanner) (database.ScheduleDao, error) {
+	var s database.ScheduleDao
+	var intervalSeconds int64
+	var lastRunAt sql.NullTime
+	if err := row.Scan(&s.Id, &s.Kind, &s.Name, &s.Listing, &s.TimeWindow, &intervalSeconds, &s.Paused, &s.NextRunAt, &lastRunAt, &s.LastError); err != nil {
+		return s, err
+	}
+	s.Interval = time.Duration(intervalSeconds) * time.Second
+	if lastRunAt.Valid {
+		s.LastRunAt = &lastRunAt.Time
+	}
+	return s, nil
+}
+
+// EnsureSchedule creates a schedule for a subscription unless one already exists for
+// the listing, and returns its id
+func (db *DB) EnsureSchedule(kind, name, listing, timeWindow string, nextRunAt time.Time) (int64, error) {
+	var id int64
+	query := `
+        INSERT INTO schedules (kind, name, listing, time_window, next_run_at)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (kind, (LOWER(name)), listing) DO UPDATE SET kind = EXCLUDED.kind
+        RETURNING id
+    `
+	if err := db.QueryRow(query, kind, name, listing, timeWindow, nextRunAt.UTC()).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to ensure %s schedule for %s: %w", kind, name, err)
+	}
+	return id, nil
+}
+
+// UpsertSchedule creates or updates the schedule of a subscription listing. A zero
+// interval falls back to the default interval of the listing type.
+func (db *DB) UpsertSchedule(kind, name, listing, timeWindow string, interval time.Duration, nextRunAt time.Time) (int64, error) {
+	var intervalSeconds sql.NullInt64
+	if interval > 0 {
+		intervalSeconds = sql.NullInt64{Int64: int64(interval / time.Second), Valid: true}
+	}
+
+	var id int64
+	query := `
+        INSERT INTO schedules (kind, name, listing, time_window, interval_seconds, next_run_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (kind, (LOWER(name)), listing) DO UPDATE SET
+            time_window = EXCLUDED.time_window,
+            interval_seconds = EXCLUDED.interval_seconds,
+            next_run_at = LEAST(schedules.next_run_at, EXCLUDED.next_run_at),
+            updated_at = CURRENT_TIMESTAMP
+        RETURNING id
+    `
+	if err := db.QueryRow(query, kind, name, listing, timeWindow, intervalSeconds, nextRunAt.UTC()).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to upsert %s schedule for %s: %w", kind, name, err)
+	}
+	log.Printf("Upserted %s schedule %d for %s", kind, id, name)
+	return id, nil
+}
+
+// MoveSchedule points the schedule of a subscription listing at another listing and time
+// window, keeping its interval and state. When the subscription already has a schedule for
+// listing, that one takes the time window and the schedule of fromListing is removed.
+func (db *DB) MoveSchedule(kind, name, fromListing, listing, timeWindow string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+        UPDATE schedules SET time_window = $1, updated_at = CURRENT_TIMESTAMP
+        WHERE kind = $2 AND LOWER(name) = LOWER($3) AND listing = $4
+    `, timeWindow, kind, name, listing)
+	if err != nil {
+		return fmt.Errorf("failed to update %s schedule for %s: %w", kind, name, err)
+	}
+	existing, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update %s schedule for %s: %w", kind, name, err)
+	}
+
+	if fromListing != listing {
+		if existing > 0 {
+			_, err = tx.Exec(`DELETE FROM schedules WHERE kind = $1 AND LOWER(name) = LOWER($2) AND listing = $3`, kind, name, fromListing)
+		} else {
+			_, err = tx.Exec(`
+                UPDATE schedules SET listing = $1, time_window = $2, updated_at = CURRENT_TIMESTAMP
+                WHERE kind = $3 AND LOWER(name) = LOWER($4) AND listing = $5
+            `, listing, timeWindow, kind, name, fromListing)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to move %s schedule for %s: %w", kind, name, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetAllSchedules retrieves all schedules ordered by their next run
+func (db *DB) GetAllSchedules() ([]database.ScheduleDao, error) {
+	return db.querySchedules(`
+        SELECT ` + scheduleColumns + `
+        FROM schedules
+        ORDER BY next_run_at, id
+    `)
+}
+
+// GetDueSchedules retrieves the schedules that are not paused and due at the given time
+func (db *DB) GetDueSchedules(now time.Time) ([]database.ScheduleDao, error) {
+	return db.querySchedules(`
+        SELECT `+scheduleColumns+`
+        FROM schedules
+        WHERE NOT paused AND next_run_at <= $1
+        ORDER BY next_run_at, id
+    `, now.UTC())
+}
+
+// GetSchedule retrieves a schedule by id
+func (db *DB) GetSchedule(id int64) (database.ScheduleDao, error) {
+	row := db.QueryRow(`SELECT `+scheduleColumns+` FROM schedules WHERE id = $1`, id)
+	s, err := scanSchedule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, fmt.Errorf("schedule %d: %w", id, database.ErrNotFound)
+	}
+	if err != nil {
+		return s, fmt.Errorf("failed to get schedule %d: %w", id, err)
+	}
+	return s, nil
+}
+
+// querySchedules runs a query selecting scheduleColumns
+func (db *DB) querySchedules(query string, args ...any) ([]database.ScheduleDao, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []database.ScheduleDao
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan schedule row: %w", err)
+		}
+		schedules = append(schedules, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating schedule rows: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// SetSchedulePaused pauses or resumes a schedule
+func (db *DB) SetSchedulePaused(id int64, paused bool) error {
+	return db.updateSchedule(id, `UPDATE schedules SET paused = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, paused)
+}
+
+// SetScheduleNextRun moves the next run of a schedule
+func (db *DB) SetScheduleNextRun(id int64, nextRunAt time.Time) error {
+	return db.updateSchedule(id, `UPDATE schedules SET next_run_at = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, nextRunAt.UTC())
+}
+
+// RecordScheduleRun stores the outcome of a run and when the schedule runs next
+func (db *DB) RecordScheduleRun(id int64, ranAt, nextRunAt time.Time, lastError string) error {
+	return db.updateSchedule(id, `
+        UPDATE schedules
+        SET last_run_at = $1, next_run_at = $2, last_error = $3, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $4
+    `, ranAt.UTC(), nextRunAt.UTC(), lastError)
+}
+
+// updateSchedule runs an update of a single schedule whose id is the last placeholder,
+// returning ErrNotFound when it does not exist
+func (db *DB) updateSchedule(id int64, query string, args ...any) error {
+	result, err := db.Exec(query, append(args, id)...)
+	if err != nil {
+		return fmt.Errorf("failed to update schedule %d: %w", id, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update schedule %d: %w", id, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("schedule %d: %w", id, database.ErrNotFound)
+	}
+	return nil
+}
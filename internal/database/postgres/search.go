@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"fmt"
+
+	"github.com/samratjha96/hecate/internal/database"
+	"github.com/samratjha96/hecate/internal/searchquery"
+)
+
+const searchLimit = 100
+
+// headlineOptions make ts_headline mark matches the way SQLite's snippet() does
+var headlineOptions = fmt.Sprintf(`StartSel="%s", StopSel="%s", MaxWords=24, MinWords=12`,
+	database.SnippetStart, database.SnippetEnd)
+
+// searchScanner scans the snippet and score selected after database.PostColumns
+type searchScanner struct {
+	row    database.RowScanner
+	result *database.SearchResultDao
+}
+
+func (s searchScanner) Scan(dest ...any) error {
+	return s.row.Scan(append(dest, &s.result.Snippet, &s.result.Score)...)
+}
+
+// SearchPosts runs a search query written in the searchquery language, returning a
+// *searchquery.SyntaxError when it is malformed. Posts matching the query's text are
+// ranked with ts_rank_cd over the weighted search_vector, and queries that only filter
+// return the newest posts first. An empty subreddit searches across all subreddits.
+func (db *DB) SearchPosts(query, subreddit string) ([]database.SearchResultDao, error) {
+	parsed, err := searchquery.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rank the posts matching the query's text, the other matches come last
+	ranked := `'' AS snippet, 0::REAL AS score`
+	from := `posts p`
+	order := `p.created_at DESC`
+	var args []any
+	if match := parsed.Match(searchquery.PostgreSQL); match != "" {
+		ranked = `
+			CASE WHEN p.search_vector @@ q THEN
+				ts_headline('english', p.title || ' ' || COALESCE(p.content, ''), q, $2)
+			ELSE '' END AS snippet,
+			ts_rank_cd(p.search_vector, q) AS score`
+		from = `posts p CROSS JOIN to_tsquery('english', $1) AS q`
+		order = `p.search_vector @@ q DESC, score DESC, p.created_at DESC`
+		args = append(args, match, headlineOptions)
+	}
+
+	compiled := parsed.Compile(searchquery.PostgreSQL, len(args)+1)
+	args = append(args, compiled.Args...)
+	sqlQuery := `
+		SELECT ` + database.PostColumns + `, ` + ranked + `
+		FROM ` + from + `
+		WHERE ` + compiled.Where
+	if subreddit != "" {
+		args = append(args, subreddit)
+		sqlQuery += fmt.Sprintf(` AND LOWER(p.subreddit_name) = LOWER($%d)`, len(args))
+	}
+	sqlQuery += fmt.Sprintf(` ORDER BY %s LIMIT %d`, order, searchLimit)
+
+	rows, err := db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search posts: %w", err)
+	}
+	defer rows.Close()
+
+	var results []database.SearchResultDao
+	for rows.Next() {
+		var result database.SearchResultDao
+		post, err := database.ScanPost(searchScanner{row: rows, result: &result})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan search result row: %w", err)
+		}
+		result.SubredditPostDao = post
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating search result rows: %w", err)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/samratjha96/hecate/internal/database"
+)
+
+// UpsertFollowedUser inserts or updates a followed Reddit user, matching the name
+// case-insensitively, and returns its id
+func (db *DB) UpsertFollowedUser(name, listing, timeWindow string) (int64, error) {
+	var id int64
+	query := `
+        INSERT INTO followed_users (name, listing, time_window)
+        VALUES ($1, $2, $3)
+        ON CONFLICT ((LOWER(name))) DO UPDATE SET
+            name = EXCLUDED.name,
+            listing = EXCLUDED.listing,
+            time_window = EXCLUDED.time_window,
+            updated_at = CURRENT_TIMESTAMP
+        RETURNING id
+    `
+	if err := db.QueryRow(query, name, listing, timeWindow).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to upsert followed user %s: %w", name, err)
+	}
+	log.Printf("Upserted followed user %d: %s", id, name)
+	return id, nil
+}
+
+// GetAllFollowedUsers retrieves all followed Reddit users
+func (db *DB) GetAllFollowedUsers() ([]database.FollowedUserDao, error) {
+	rows, err := db.Query(`
+        SELECT id, name, listing, time_window, created_at
+        FROM followed_users
+        ORDER BY LOWER(name)
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query followed users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []database.FollowedUserDao
+	for rows.Next() {
+		var u database.FollowedUserDao
+		if err := rows.Scan(&u.Id, &u.Name, &u.Listing, &u.TimeWindow, &u.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan followed user row: %w", err)
+		}
+		users = append(users, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating followed user rows: %w", err)
+	}
+
+	return users, nil
+}
+
+// GetFollowedUsers retrieves a page of followed Reddit users in the order they were followed
+func (db *DB) GetFollowedUsers(pagination database.Paginate) (database.PaginatedResult[database.FollowedUserDao], error) {
+	var after int64
+	if pagination.Cursor != "" {
+		cursor, err := database.DecodeCursor(pagination.Cursor)
+		if err != nil {
+			return database.PaginatedResult[database.FollowedUserDao]{}, err
+		}
+		after = cursor.Id
+	}
+
+	rows, err := db.Query(`
+        SELECT id, name, listing, time_window, created_at
+        FROM followed_users
+        WHERE id > $1
+        ORDER BY id
+        LIMIT $2
+    `, after, pagination.Limit+1)
+	if err != nil {
+		return database.PaginatedResult[database.FollowedUserDao]{}, fmt.Errorf("failed to query followed users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []database.FollowedUserDao
+	var cursors []database.Cursor
+	for rows.Next() {
+		var u database.FollowedUserDao
+		if err := rows.Scan(&u.Id, &u.Name, &u.Listing, &u.TimeWindow, &u.CreatedAt); err != nil {
+			return database.PaginatedResult[database.FollowedUserDao]{}, fmt.Errorf("failed to scan followed user row: %w", err)
+		}
+		users = append(users, u)
+		cursors = append(cursors, database.Cursor{Id: u.Id})
+	}
+
+	if err := rows.Err(); err != nil {
+		return database.PaginatedResult[database.FollowedUserDao]{}, fmt.Errorf("error iterating followed user rows: %w", err)
+	}
+
+	return database.PageOf(users, cursors, pagination), nil
+}
+
+// GetFollowedUser retrieves a followed Reddit user by name, ignoring case
+func (db *DB) GetFollowedUser(name string) (database.FollowedUserDao, error) {
+	var u database.FollowedUserDao
+	err := db.QueryRow(`
+        SELECT id, name, listing, time_window, created_at
+        FROM followed_users
+        WHERE LOWER(name) = LOWER($1)
+    `, name).Scan(&u.Id, &u.Name, &u.Listing, &u.TimeWindow, &u.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return u, fmt.Errorf("followed user %s: %w", name, database.ErrNotFound)
+	}
+	if err != nil {
+		return u, fmt.Errorf("failed to get followed user %s: %w", name, err)
+	}
+	return u, nil
+}
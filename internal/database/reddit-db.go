@@ -1,6 +1,9 @@
 package database
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -8,113 +11,397 @@ import (
 	"github.com/samratjha96/hecate/internal/reddit"
 )
 
-const (
-	DefaultLimit = 10
-	DefaultPage  = 1
-)
-
-type Paginate struct {
-	Limit int
-	Page  int
-}
+// DefaultLimit is the page size FetchAll reads listings in
+const DefaultLimit = 10
 
 type SubredditDao struct {
 	Name                string
 	NumberOfSubscribers int
+	Title               string
+	PublicDescription   string
+	CreatedAt           time.Time
+	Nsfw                bool
+	ActiveUsers         int
+	IconUrl             string
+	// Subscription is what the subreddit was subscribed with, empty for subreddits
+	// subscribed before it was recorded
+	Subscription SubredditSubscriptionDao
+}
+
+// SubredditSubscriptionDao holds the options a subreddit was subscribed with, which its
+// schedule refreshes it with
+type SubredditSubscriptionDao struct {
+	Listing    string
+	TimeWindow string
+	Limit      int
+	MaxPosts   int
+	// Comments reports whether comment trees are ingested, CommentDepth and CommentLimit
+	// being zero for the defaults
+	Comments     bool
+	CommentDepth int
+	CommentLimit int
+}
+
+// SubredditSubscriptionColumns are the columns of a subreddit's subscription scanned into
+// SubredditSubscriptionFields, in SQL that SQLite and PostgreSQL share
+const SubredditSubscriptionColumns = `COALESCE(listing, ''), COALESCE(time_window, ''), COALESCE(post_limit, 0),
+               COALESCE(max_posts, 0), comment_depth IS NOT NULL, COALESCE(comment_depth, 0), COALESCE(comment_limit, 0)`
+
+// SubredditSubscriptionFields returns the scan destinations of SubredditSubscriptionColumns
+func SubredditSubscriptionFields(s *SubredditSubscriptionDao) []any {
+	return []any{&s.Listing, &s.TimeWindow, &s.Limit, &s.MaxPosts, &s.Comments, &s.CommentDepth, &s.CommentLimit}
+}
+
+// SubredditSubscriptionArgs returns the listing, time_window, post_limit, max_posts,
+// comment_depth and comment_limit values stored for a subscription
+func SubredditSubscriptionArgs(s SubredditSubscriptionDao) []any {
+	var commentDepth, commentLimit sql.NullInt64
+	if s.Comments {
+		commentDepth = sql.NullInt64{Int64: int64(s.CommentDepth), Valid: true}
+		commentLimit = sql.NullInt64{Int64: int64(s.CommentLimit), Valid: true}
+	}
+	return []any{s.Listing, s.TimeWindow, s.Limit, s.MaxPosts, commentDepth, commentLimit}
 }
 
 type SubredditPostDao struct {
-	Title         string
-	Content       string
-	DiscussionURL string
-	CommentCount  int
-	Upvotes       int
-	SubredditName string
+	PostId           string
+	Fullname         string
+	Title            string
+	Content          string
+	Author           string
+	DiscussionURL    string
+	Url              string
+	Domain           string
+	IsSelf           bool
+	Thumbnail        string
+	Flair            string
+	Stickied         bool
+	Pinned           bool
+	Nsfw             bool
+	Spoiler          bool
+	CommentCount     int
+	Upvotes          int
+	UpvoteRatio      float64
+	SubredditName    string
+	CrosspostParents []reddit.CrosspostParent
 }
 
-// GetAllSubreddits retrieves all subreddits from the database
-func (db *DB) GetAllSubreddits() ([]SubredditDao, error) {
-	fetcher := func(page, limit int) (PaginatedResult[SubredditDao], error) {
-		subreddits, nextPage, err := db.getSubredditsWithPagination(Paginate{
-			Page:  page,
-			Limit: limit,
-		})
-		if err != nil {
-			return PaginatedResult[SubredditDao]{}, fmt.Errorf("failed to fetch subreddits: %w", err)
+// PostColumns are the columns scanned by ScanPost, qualified with the posts alias p. They
+// are written in SQL that SQLite and PostgreSQL share.
+const PostColumns = `p.post_id, COALESCE(p.fullname, ''), p.title, COALESCE(p.content, ''), COALESCE(p.author, ''),
+        COALESCE(p.discussion_url, ''), COALESCE(p.url, ''), COALESCE(p.domain, ''), COALESCE(p.is_self, FALSE),
+        COALESCE(p.thumbnail, ''), COALESCE(p.flair, ''), COALESCE(p.stickied, FALSE), COALESCE(p.pinned, FALSE),
+        COALESCE(p.nsfw, FALSE), COALESCE(p.spoiler, FALSE), COALESCE(p.comment_count, 0), COALESCE(p.upvotes, 0),
+        COALESCE(p.upvote_ratio, 0), p.subreddit_name, COALESCE(p.crosspost_parents, '')`
+
+// RowScanner is implemented by *sql.Row and *sql.Rows
+type RowScanner interface {
+	Scan(dest ...any) error
+}
+
+// ScanPost scans a row selected with PostColumns
+func ScanPost(row RowScanner) (SubredditPostDao, error) {
+	var p SubredditPostDao
+	var crosspostParents string
+	err := row.Scan(&p.PostId, &p.Fullname, &p.Title, &p.Content, &p.Author, &p.DiscussionURL, &p.Url, &p.Domain,
+		&p.IsSelf, &p.Thumbnail, &p.Flair, &p.Stickied, &p.Pinned, &p.Nsfw, &p.Spoiler, &p.CommentCount,
+		&p.Upvotes, &p.UpvoteRatio, &p.SubredditName, &crosspostParents)
+	if err != nil {
+		return p, err
+	}
+	if crosspostParents != "" {
+		if err := json.Unmarshal([]byte(crosspostParents), &p.CrosspostParents); err != nil {
+			return p, fmt.Errorf("failed to unmarshal crosspost parents: %w", err)
 		}
-		return PaginatedResult[SubredditDao]{
-			Items:    subreddits,
-			NextPage: nextPage,
-		}, nil
 	}
+	return p, nil
+}
 
-	return FetchAll(fetcher, DefaultPage, DefaultLimit)
+// GetAllSubreddits retrieves all subreddits from the database
+func (db *DB) GetAllSubreddits() ([]SubredditDao, error) {
+	return FetchAll(db.GetSubreddits, DefaultLimit)
 }
 
-// getSubredditsWithPagination retrieves a paginated list of subreddits
-func (db *DB) getSubredditsWithPagination(pagination Paginate) ([]SubredditDao, int, error) {
-	offset := (pagination.Page - 1) * pagination.Limit
-	nextPage := pagination.Page
+// GetSubreddits retrieves a page of subreddits in the order they were added
+func (db *DB) GetSubreddits(pagination Paginate) (PaginatedResult[SubredditDao], error) {
+	var after int64
+	if pagination.Cursor != "" {
+		cursor, err := DecodeCursor(pagination.Cursor)
+		if err != nil {
+			return PaginatedResult[SubredditDao]{}, err
+		}
+		after = cursor.Id
+	}
 
 	query := `
-        SELECT id, name, num_subscribers, created_at
+        SELECT name, num_subscribers, COALESCE(title, ''), COALESCE(public_description, ''),
+               subreddit_created_at, COALESCE(nsfw, 0), COALESCE(active_users, 0), COALESCE(icon_url, ''),
+               ` + SubredditSubscriptionColumns + `, id
         FROM subreddits
+        WHERE id > $1
         ORDER BY id
-        LIMIT $1
-        OFFSET $2
+        LIMIT $2
     `
 
-	rows, err := db.Query(query, pagination.Limit, offset)
+	rows, err := db.Query(query, after, pagination.Limit+1)
 	if err != nil {
-		return nil, nextPage, fmt.Errorf("failed to query subreddits: %w", err)
+		return PaginatedResult[SubredditDao]{}, fmt.Errorf("failed to query subreddits: %w", err)
 	}
 	defer rows.Close()
 
 	var subreddits []SubredditDao
+	var cursors []Cursor
 	for rows.Next() {
 		var s SubredditDao
-		var id int64
-		var createdAt time.Time
-		if err := rows.Scan(&id, &s.Name, &s.NumberOfSubscribers, &createdAt); err != nil {
-			return nil, nextPage, fmt.Errorf("failed to scan subreddit row: %w", err)
+		var cursor Cursor
+		var createdAt sql.NullTime
+		fields := []any{&s.Name, &s.NumberOfSubscribers, &s.Title, &s.PublicDescription,
+			&createdAt, &s.Nsfw, &s.ActiveUsers, &s.IconUrl}
+		fields = append(fields, SubredditSubscriptionFields(&s.Subscription)...)
+		if err := rows.Scan(append(fields, &cursor.Id)...); err != nil {
+			return PaginatedResult[SubredditDao]{}, fmt.Errorf("failed to scan subreddit row: %w", err)
 		}
+		s.CreatedAt = createdAt.Time
 		subreddits = append(subreddits, s)
+		cursors = append(cursors, cursor)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, nextPage, fmt.Errorf("error iterating subreddit rows: %w", err)
-	}
-
-	if len(subreddits) > 0 {
-		nextPage = pagination.Page + 1
+		return PaginatedResult[SubredditDao]{}, fmt.Errorf("error iterating subreddit rows: %w", err)
 	}
 
-	return subreddits, nextPage, nil
+	return PageOf(subreddits, cursors, pagination), nil
 }
 
-// UpsertSubreddit inserts or updates a subreddit in the database
-func (db *DB) UpsertSubreddit(name string, numberOfSubscribers int) (int, error) {
+// UpsertSubreddit inserts or updates a subreddit and its about.json metadata in the database.
+// Names match case-insensitively, an existing row takes the casing of name.
+func (db *DB) UpsertSubreddit(name string, about reddit.SubredditAbout) (int, error) {
 	var id int
-	query := `
-        INSERT INTO subreddits (name, num_subscribers)
-        VALUES ($1, $2)
-        ON CONFLICT (name) 
-        DO UPDATE SET num_subscribers = EXCLUDED.num_subscribers
-        RETURNING id
-    `
-	err := db.QueryRow(query, name, numberOfSubscribers).Scan(&id)
+	err := db.QueryRow(upsertSubredditQuery, upsertSubredditArgs(name, about)...).Scan(&id)
 	if err != nil {
 		return 0, fmt.Errorf("failed to upsert subreddit: %w", err)
 	}
-	log.Printf("Upserted subreddit: %s with %d subscribers", name, numberOfSubscribers)
+	log.Printf("Upserted subreddit: %s with %d subscribers", name, about.NumberOfSubscribers)
 	return id, nil
 }
 
-// UpsertPost inserts or updates a post in the database
-func (db *DB) UpsertPost(post reddit.RedditPost, subredditName string) error {
+// upsertSubredditQuery inserts or updates a subreddit, returning its id
+const upsertSubredditQuery = `
+        INSERT INTO subreddits (name, num_subscribers, fullname, title, public_description, subreddit_created_at,
+            nsfw, active_users, icon_url, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, CURRENT_TIMESTAMP)
+        ON CONFLICT (name COLLATE NOCASE)
+        DO UPDATE SET
+            name = EXCLUDED.name,
+            num_subscribers = EXCLUDED.num_subscribers,
+            fullname = EXCLUDED.fullname,
+            title = EXCLUDED.title,
+            public_description = EXCLUDED.public_description,
+            subreddit_created_at = EXCLUDED.subreddit_created_at,
+            nsfw = EXCLUDED.nsfw,
+            active_users = EXCLUDED.active_users,
+            icon_url = EXCLUDED.icon_url,
+            updated_at = CURRENT_TIMESTAMP
+        RETURNING id
+    `
+
+// upsertSubredditArgs returns the arguments of upsertSubredditQuery
+func upsertSubredditArgs(name string, about reddit.SubredditAbout) []any {
+	return []any{name, about.NumberOfSubscribers, about.Fullname, about.Title, about.PublicDescription,
+		about.CreatedAt, about.Nsfw, about.ActiveUsers, about.IconUrl}
+}
+
+// GetSubredditName looks up the canonical name of a stored subreddit, ignoring case
+func (db *DB) GetSubredditName(name string) (string, error) {
+	var canonical string
+	err := db.QueryRow(`SELECT name FROM subreddits WHERE name = $1 COLLATE NOCASE`, name).Scan(&canonical)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("subreddit %s: %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get subreddit %s: %w", name, err)
+	}
+	return canonical, nil
+}
+
+// UpdateSubscriberCount updates the subscriber count of an existing subreddit
+func (db *DB) UpdateSubscriberCount(name string, numberOfSubscribers int) error {
+	query := `
+        UPDATE subreddits
+        SET num_subscribers = $1, updated_at = CURRENT_TIMESTAMP
+        WHERE name = $2 COLLATE NOCASE
+    `
+	if _, err := db.Exec(query, numberOfSubscribers, name); err != nil {
+		return fmt.Errorf("failed to update subscribers of subreddit %s: %w", name, err)
+	}
+	return nil
+}
+
+// SetSubredditSubscription records the options a subreddit was subscribed with
+func (db *DB) SetSubredditSubscription(name string, subscription SubredditSubscriptionDao) error {
 	query := `
-        INSERT INTO posts (subreddit_name, post_id, title, content, discussion_url, comment_count, upvotes, created_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        UPDATE subreddits
+        SET listing = $1, time_window = $2, post_limit = $3, max_posts = $4, comment_depth = $5, comment_limit = $6
+        WHERE name = $7 COLLATE NOCASE
+    `
+	args := append(SubredditSubscriptionArgs(subscription), name)
+	if _, err := db.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to set subscription of subreddit %s: %w", name, err)
+	}
+	return nil
+}
+
+// GetSubredditSubscription retrieves the options a subreddit was subscribed with
+func (db *DB) GetSubredditSubscription(name string) (SubredditSubscriptionDao, error) {
+	var subscription SubredditSubscriptionDao
+	query := `SELECT ` + SubredditSubscriptionColumns + ` FROM subreddits WHERE name = $1 COLLATE NOCASE`
+	err := db.QueryRow(query, name).Scan(SubredditSubscriptionFields(&subscription)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return subscription, fmt.Errorf("subreddit %s: %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return subscription, fmt.Errorf("failed to get subscription of subreddit %s: %w", name, err)
+	}
+	return subscription, nil
+}
+
+// UpsertPost inserts or updates a post in the database, reporting whether the post was new
+func (db *DB) UpsertPost(post reddit.RedditPost, subredditName string) (bool, error) {
+	var exists bool
+	if err := db.QueryRow(postExistsQuery, post.PostId).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to look up post %s: %w", post.PostId, err)
+	}
+
+	args, err := UpsertPostArgs(post, subredditName)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := db.Exec(upsertPostQuery, args...); err != nil {
+		return false, fmt.Errorf("failed to upsert post: %w", err)
+	}
+	log.Printf("Upserted post: %s for subreddit: %s", post.Title, subredditName)
+	return !exists, nil
+}
+
+// UpsertCounts counts the posts a batch write inserted and updated
+type UpsertCounts struct {
+	Inserted int
+	Updated  int
+}
+
+// UpsertSubredditWithPosts upserts a subreddit and all of its posts in one transaction
+// using prepared statements, so a failure leaves nothing written. Only posts are counted.
+func (db *DB) UpsertSubredditWithPosts(name string, about reddit.SubredditAbout, posts []reddit.RedditPost) (UpsertCounts, error) {
+	var counts UpsertCounts
+
+	tx, err := db.Begin()
+	if err != nil {
+		return counts, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(upsertSubredditQuery, upsertSubredditArgs(name, about)...); err != nil {
+		return counts, fmt.Errorf("failed to upsert subreddit: %w", err)
+	}
+	counts, err = upsertPosts(tx, posts, name, "", "")
+	if err != nil {
+		return UpsertCounts{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return UpsertCounts{}, fmt.Errorf("failed to commit subreddit %s: %w", name, err)
+	}
+	log.Printf("Upserted subreddit %s with %d new and %d updated posts", name, counts.Inserted, counts.Updated)
+	return counts, nil
+}
+
+// UpsertPosts upserts posts in one transaction using prepared statements, so a failure
+// leaves nothing written. Posts are stored under subredditName, or their own subreddit
+// when it is empty, and linked to the subscription identified by sourceType and
+// sourceName when it is set.
+func (db *DB) UpsertPosts(posts []reddit.RedditPost, subredditName, sourceType, sourceName string) (UpsertCounts, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return UpsertCounts{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	counts, err := upsertPosts(tx, posts, subredditName, sourceType, sourceName)
+	if err != nil {
+		return UpsertCounts{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return UpsertCounts{}, fmt.Errorf("failed to commit posts: %w", err)
+	}
+	log.Printf("Upserted %d new and %d updated posts", counts.Inserted, counts.Updated)
+	return counts, nil
+}
+
+// upsertPosts writes posts and their sources in tx, counting the new and updated posts
+func upsertPosts(tx *sql.Tx, posts []reddit.RedditPost, subredditName, sourceType, sourceName string) (UpsertCounts, error) {
+	var counts UpsertCounts
+
+	existsStmt, err := tx.Prepare(postExistsQuery)
+	if err != nil {
+		return counts, fmt.Errorf("failed to prepare post lookup: %w", err)
+	}
+	defer existsStmt.Close()
+
+	upsertStmt, err := tx.Prepare(upsertPostQuery)
+	if err != nil {
+		return counts, fmt.Errorf("failed to prepare post upsert: %w", err)
+	}
+	defer upsertStmt.Close()
+
+	var sourceStmt *sql.Stmt
+	if sourceType != "" {
+		if sourceStmt, err = tx.Prepare(addPostSourceQuery); err != nil {
+			return counts, fmt.Errorf("failed to prepare post source: %w", err)
+		}
+		defer sourceStmt.Close()
+	}
+
+	for _, post := range posts {
+		var exists bool
+		if err := existsStmt.QueryRow(post.PostId).Scan(&exists); err != nil {
+			return UpsertCounts{}, fmt.Errorf("failed to look up post %s: %w", post.PostId, err)
+		}
+
+		name := subredditName
+		if name == "" {
+			name = post.Subreddit
+		}
+		args, err := UpsertPostArgs(post, name)
+		if err != nil {
+			return UpsertCounts{}, err
+		}
+		if _, err := upsertStmt.Exec(args...); err != nil {
+			return UpsertCounts{}, fmt.Errorf("failed to upsert post %s: %w", post.PostId, err)
+		}
+		if sourceStmt != nil {
+			if _, err := sourceStmt.Exec(post.PostId, sourceType, sourceName); err != nil {
+				return UpsertCounts{}, fmt.Errorf("failed to add %s source for post %s: %w", sourceType, post.PostId, err)
+			}
+		}
+
+		if exists {
+			counts.Updated++
+		} else {
+			counts.Inserted++
+		}
+	}
+	return counts, nil
+}
+
+// postExistsQuery reports whether a post is already stored
+const postExistsQuery = `SELECT EXISTS (SELECT 1 FROM posts WHERE post_id = $1)`
+
+// upsertPostQuery inserts or updates a post and all of its metadata
+const upsertPostQuery = `
+        INSERT INTO posts (subreddit_name, post_id, title, content, discussion_url, comment_count, upvotes, created_at,
+            fullname, author, url, domain, is_self, thumbnail, flair, stickied, pinned, nsfw, spoiler, upvote_ratio, crosspost_parents)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
         ON CONFLICT (post_id) DO UPDATE SET
             title = EXCLUDED.title,
             content = EXCLUDED.content,
@@ -122,105 +409,145 @@ func (db *DB) UpsertPost(post reddit.RedditPost, subredditName string) error {
             comment_count = EXCLUDED.comment_count,
             upvotes = EXCLUDED.upvotes,
             created_at = EXCLUDED.created_at,
+            fullname = EXCLUDED.fullname,
+            author = EXCLUDED.author,
+            url = EXCLUDED.url,
+            domain = EXCLUDED.domain,
+            is_self = EXCLUDED.is_self,
+            thumbnail = EXCLUDED.thumbnail,
+            flair = EXCLUDED.flair,
+            stickied = EXCLUDED.stickied,
+            pinned = EXCLUDED.pinned,
+            nsfw = EXCLUDED.nsfw,
+            spoiler = EXCLUDED.spoiler,
+            upvote_ratio = EXCLUDED.upvote_ratio,
+            crosspost_parents = EXCLUDED.crosspost_parents,
             updated_at = CURRENT_TIMESTAMP
     `
 
-	_, err := db.Exec(query, subredditName, post.PostId, post.Title, post.Content, post.DiscussionUrl, post.CommentCount, post.Upvotes, post.TimePosted)
+// UpsertPostArgs returns the arguments of a backend's upsert of a post, in the order of
+// the columns subreddit_name, post_id, title, content, discussion_url, comment_count,
+// upvotes, created_at, fullname, author, url, domain, is_self, thumbnail, flair, stickied,
+// pinned, nsfw, spoiler, upvote_ratio and crosspost_parents. Creation times are stored
+// in UTC so that listings can compare them as stored.
+func UpsertPostArgs(post reddit.RedditPost, subredditName string) ([]any, error) {
+	crosspostParents, err := marshalCrosspostParents(post.CrosspostParents)
 	if err != nil {
-		return fmt.Errorf("failed to upsert post: %w", err)
+		return nil, err
 	}
-	log.Printf("Upserted post: %s for subreddit: %s", post.Title, subredditName)
-	return nil
+	return []any{subredditName, post.PostId, post.Title, post.Content, post.DiscussionUrl, post.CommentCount, post.Upvotes, post.TimePosted.UTC(),
+		post.Fullname, post.Author, post.Url, post.Domain, post.IsSelf, post.Thumbnail, post.Flair, post.Stickied, post.Pinned,
+		post.Nsfw, post.Spoiler, post.UpvoteRatio, crosspostParents}, nil
 }
 
-// GetSubredditPosts retrieves all posts for a given subreddit
-func (db *DB) GetSubredditPosts(subredditName string) ([]SubredditPostDao, error) {
-	fetcher := func(page, limit int) (PaginatedResult[SubredditPostDao], error) {
-		posts, nextPage, err := db.getSubredditPostsWithPagination(subredditName, Paginate{
-			Page:  page,
-			Limit: limit,
-		})
-		if err != nil {
-			return PaginatedResult[SubredditPostDao]{}, fmt.Errorf("failed to fetch posts for subreddit %s: %w", subredditName, err)
-		}
-		return PaginatedResult[SubredditPostDao]{
-			Items:    posts,
-			NextPage: nextPage,
-		}, nil
+// marshalCrosspostParents encodes crosspost parents for the crosspost_parents column, NULL when there are none
+func marshalCrosspostParents(parents []reddit.CrosspostParent) (sql.NullString, error) {
+	if len(parents) == 0 {
+		return sql.NullString{}, nil
 	}
-
-	return FetchAll(fetcher, DefaultPage, DefaultLimit)
+	data, err := json.Marshal(parents)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to marshal crosspost parents: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
 }
 
-// SearchPosts searches for posts across all subreddits
-func (db *DB) SearchPosts(query string) ([]SubredditPostDao, error) {
-	sqlQuery := `
-		SELECT p.title, p.content, p.discussion_url, p.comment_count, p.upvotes, p.subreddit_name
-		FROM posts p
-		WHERE p.title ILIKE $1 OR p.content ILIKE $1
-		ORDER BY p.created_at DESC
-		LIMIT 100
-	`
-	searchPattern := "%" + query + "%"
-
-	rows, err := db.Query(sqlQuery, searchPattern)
+// GetSubredditPosts retrieves a page of the posts of a subreddit, sorted and filtered by
+// query. It returns an error wrapping ErrInvalidPostQuery when the query is invalid.
+func (db *DB) GetSubredditPosts(subredditName string, query PostQuery, pagination Paginate) (PaginatedResult[SubredditPostDao], error) {
+	if err := query.Validate(); err != nil {
+		return PaginatedResult[SubredditPostDao]{}, err
+	}
+	condition, args := PostQueryCondition(`p.subreddit_name = $1 COLLATE NOCASE`, []any{subredditName}, query)
+	page, err := db.getPostsPage(`posts p`, condition, args, query, pagination)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search posts: %w", err)
+		return page, fmt.Errorf("failed to fetch posts for subreddit %s: %w", subredditName, err)
 	}
-	defer rows.Close()
+	return page, nil
+}
 
-	var posts []SubredditPostDao
-	for rows.Next() {
-		var p SubredditPostDao
-		if err := rows.Scan(&p.Title, &p.Content, &p.DiscussionURL, &p.CommentCount, &p.Upvotes, &p.SubredditName); err != nil {
-			return nil, fmt.Errorf("failed to scan post row: %w", err)
-		}
-		posts = append(posts, p)
+// PostQueryCondition adds the filters of a post query to a condition whose placeholders
+// are numbered up to len(args). Creation times are bound in UTC, the zone they are
+// stored in, so that they compare as stored.
+func PostQueryCondition(condition string, args []any, query PostQuery) (string, []any) {
+	filter := func(clause string, arg any) {
+		args = append(args, arg)
+		condition += fmt.Sprintf(` AND `+clause, len(args))
 	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating post rows: %w", err)
+	if query.MinScore != nil {
+		filter(`p.upvotes >= $%d`, *query.MinScore)
+	}
+	if query.MinComments != nil {
+		filter(`p.comment_count >= $%d`, *query.MinComments)
+	}
+	if !query.After.IsZero() {
+		filter(`p.created_at >= $%d`, query.After.UTC())
+	}
+	if !query.Before.IsZero() {
+		filter(`p.created_at < $%d`, query.Before.UTC())
+	}
+	if query.Flair != "" {
+		filter(`LOWER(p.flair) = LOWER($%d)`, query.Flair)
 	}
+	if query.IsSelf != nil {
+		filter(`COALESCE(p.is_self, FALSE) = $%d`, *query.IsSelf)
+	}
+	if query.Nsfw != nil {
+		filter(`COALESCE(p.nsfw, FALSE) = $%d`, *query.Nsfw)
+	}
+	return condition, args
+}
 
-	return posts, nil
+// keyScanner scans the sort key and id selected after the columns of a listing
+type keyScanner struct {
+	row    RowScanner
+	cursor *Cursor
 }
 
-// getSubredditPostsWithPagination retrieves a paginated list of posts for a given subreddit
-func (db *DB) getSubredditPostsWithPagination(subredditName string, pagination Paginate) ([]SubredditPostDao, int, error) {
-	offset := (pagination.Page - 1) * pagination.Limit
-	nextPage := pagination.Page
+func (s keyScanner) Scan(dest ...any) error {
+	return s.row.Scan(append(dest, &s.cursor.Key, &s.cursor.Id)...)
+}
 
-	query := `
-        SELECT title, content, discussion_url, comment_count, upvotes
-        FROM posts
-        WHERE subreddit_name = $1
-        ORDER BY created_at DESC
-        LIMIT $2
-        OFFSET $3
-    `
+// getPostsPage retrieves a page of the posts selected from the posts p in the order of
+// query. The condition's placeholders are numbered from 1, the page's follow them. Sort
+// keys are compared as stored, so the cursor matches the order SQLite sorts them in.
+func (db *DB) getPostsPage(from, condition string, args []any, postQuery PostQuery, pagination Paginate) (PaginatedResult[SubredditPostDao], error) {
+	column := postQuery.SortColumn()
+	direction, _ := postQuery.Direction()
+	query := `SELECT ` + PostColumns + `, CAST(` + column + ` AS TEXT), p.id FROM ` + from + ` WHERE ` + condition
+	keyset, args, err := postQuery.KeysetCondition(pagination, args, `$%d`)
+	if err != nil {
+		return PaginatedResult[SubredditPostDao]{}, err
+	}
+	args = append(args, pagination.Limit+1)
+	query += keyset + fmt.Sprintf(` ORDER BY %s %s, p.id %s LIMIT $%d`, column, direction, direction, len(args))
 
-	rows, err := db.Query(query, subredditName, pagination.Limit, offset)
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		return nil, nextPage, fmt.Errorf("failed to query posts for subreddit %s: %w", subredditName, err)
+		return PaginatedResult[SubredditPostDao]{}, fmt.Errorf("failed to query posts: %w", err)
 	}
 	defer rows.Close()
+	return ScanPostPage(rows, postQuery, pagination)
+}
 
+// ScanPostPage scans rows selected with PostColumns followed by the sort key and id of
+// postQuery, fetched with a limit of pagination.Limit+1, into a page
+func ScanPostPage(rows *sql.Rows, postQuery PostQuery, pagination Paginate) (PaginatedResult[SubredditPostDao], error) {
 	var posts []SubredditPostDao
+	var cursors []Cursor
 	for rows.Next() {
-		var p SubredditPostDao
-		if err := rows.Scan(&p.Title, &p.Content, &p.DiscussionURL, &p.CommentCount, &p.Upvotes); err != nil {
-			return nil, nextPage, fmt.Errorf("failed to scan post row: %w", err)
+		cursor := Cursor{Order: postQuery.Order()}
+		p, err := ScanPost(keyScanner{row: rows, cursor: &cursor})
+		if err != nil {
+			return PaginatedResult[SubredditPostDao]{}, fmt.Errorf("failed to scan post row: %w", err)
 		}
 		posts = append(posts, p)
+		cursors = append(cursors, cursor)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, nextPage, fmt.Errorf("error iterating post rows: %w", err)
-	}
-
-	if len(posts) > 0 {
-		nextPage = pagination.Page + 1
+		return PaginatedResult[SubredditPostDao]{}, fmt.Errorf("error iterating post rows: %w", err)
 	}
 
-	return posts, nextPage, nil
+	return PageOf(posts, cursors, pagination), nil
 }
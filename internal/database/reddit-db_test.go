@@ -0,0 +1,78 @@
+package database_test
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/samratjha96/hecate/internal/database"
+	"github.com/samratjha96/hecate/internal/reddit"
+)
+
+// benchmarkBatchSize is the number of posts stored per benchmark iteration, about a
+// page of a busy subreddit with its comments left out
+const benchmarkBatchSize = 100
+
+// newBenchmarkDB creates a migrated file-backed store, so commits cost what they do in production
+func newBenchmarkDB(b *testing.B) *database.DB {
+	b.Helper()
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(os.Stderr) })
+	b.Setenv("DB_DIRECTORY", b.TempDir())
+	db, err := database.NewDB()
+	if err != nil {
+		b.Fatalf("NewDB: %v", err)
+	}
+	b.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		b.Fatalf("Migrate: %v", err)
+	}
+	return db
+}
+
+// benchmarkPosts returns a batch of posts, new for every iteration
+func benchmarkPosts(iteration int) []reddit.RedditPost {
+	posts := make([]reddit.RedditPost, benchmarkBatchSize)
+	for i := range posts {
+		id := fmt.Sprintf("bench%d_%d", iteration, i)
+		posts[i] = reddit.RedditPost{
+			PostId:     id,
+			Fullname:   "t3_" + id,
+			Title:      "benchmark post " + id,
+			Content:    "a self post with some text to store",
+			Author:     "bencher",
+			Subreddit:  "benchmark",
+			Upvotes:    i,
+			TimePosted: time.Unix(1700000000+int64(i), 0),
+		}
+	}
+	return posts
+}
+
+func BenchmarkUpsertPosts(b *testing.B) {
+	db := newBenchmarkDB(b)
+	b.ResetTimer()
+	for n := range b.N {
+		for _, post := range benchmarkPosts(n) {
+			if _, err := db.UpsertPost(post, "benchmark"); err != nil {
+				b.Fatalf("UpsertPost: %v", err)
+			}
+			if err := db.AddPostSource(post.PostId, database.SourceTypeSearch, "benchmark"); err != nil {
+				b.Fatalf("AddPostSource: %v", err)
+			}
+		}
+	}
+}
+
+func BenchmarkUpsertPostsBatch(b *testing.B) {
+	db := newBenchmarkDB(b)
+	b.ResetTimer()
+	for n := range b.N {
+		if _, err := db.UpsertPosts(benchmarkPosts(n), "benchmark", database.SourceTypeSearch, "benchmark"); err != nil {
+			b.Fatalf("UpsertPosts: %v", err)
+		}
+	}
+}
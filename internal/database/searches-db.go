@@ -0,0 +1,132 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Source types record which kind of subscription ingested a post
+const (
+	SourceTypeSearch = "search"
+	SourceTypeUser   = "user"
+)
+
+type SavedSearchDao struct {
+	Id         int64
+	Query      string
+	Subreddit  string
+	Sort       string
+	TimeWindow string
+	CreatedAt  time.Time
+}
+
+// UpsertSavedSearch inserts or updates a saved search query, identified by its query
+// and the subreddit it is restricted to, and returns its id
+func (db *DB) UpsertSavedSearch(query, subreddit, sort, timeWindow string) (int64, error) {
+	var id int64
+	sqlQuery := `
+        INSERT INTO saved_searches (query, subreddit, sort, time_window)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (query, subreddit) DO UPDATE SET
+            sort = EXCLUDED.sort,
+            time_window = EXCLUDED.time_window,
+            updated_at = CURRENT_TIMESTAMP
+        RETURNING id
+    `
+	if err := db.QueryRow(sqlQuery, query, subreddit, sort, timeWindow).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to upsert saved search: %w", err)
+	}
+	log.Printf("Upserted saved search %d: %q", id, query)
+	return id, nil
+}
+
+// GetAllSavedSearches retrieves all saved search queries
+func (db *DB) GetAllSavedSearches() ([]SavedSearchDao, error) {
+	return FetchAll(db.GetSavedSearches, DefaultLimit)
+}
+
+// GetSavedSearches retrieves a page of saved search queries in the order they were saved
+func (db *DB) GetSavedSearches(pagination Paginate) (PaginatedResult[SavedSearchDao], error) {
+	var after int64
+	if pagination.Cursor != "" {
+		cursor, err := DecodeCursor(pagination.Cursor)
+		if err != nil {
+			return PaginatedResult[SavedSearchDao]{}, err
+		}
+		after = cursor.Id
+	}
+
+	rows, err := db.Query(`
+        SELECT id, query, subreddit, sort, time_window, created_at
+        FROM saved_searches
+        WHERE id > $1
+        ORDER BY id
+        LIMIT $2
+    `, after, pagination.Limit+1)
+	if err != nil {
+		return PaginatedResult[SavedSearchDao]{}, fmt.Errorf("failed to query saved searches: %w", err)
+	}
+	defer rows.Close()
+
+	var searches []SavedSearchDao
+	var cursors []Cursor
+	for rows.Next() {
+		var s SavedSearchDao
+		if err := rows.Scan(&s.Id, &s.Query, &s.Subreddit, &s.Sort, &s.TimeWindow, &s.CreatedAt); err != nil {
+			return PaginatedResult[SavedSearchDao]{}, fmt.Errorf("failed to scan saved search row: %w", err)
+		}
+		searches = append(searches, s)
+		cursors = append(cursors, Cursor{Id: s.Id})
+	}
+
+	if err := rows.Err(); err != nil {
+		return PaginatedResult[SavedSearchDao]{}, fmt.Errorf("error iterating saved search rows: %w", err)
+	}
+
+	return PageOf(searches, cursors, pagination), nil
+}
+
+// GetSavedSearch retrieves a saved search query by id
+func (db *DB) GetSavedSearch(id int64) (SavedSearchDao, error) {
+	var s SavedSearchDao
+	err := db.QueryRow(`
+        SELECT id, query, subreddit, sort, time_window, created_at
+        FROM saved_searches
+        WHERE id = $1
+    `, id).Scan(&s.Id, &s.Query, &s.Subreddit, &s.Sort, &s.TimeWindow, &s.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, fmt.Errorf("saved search %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return s, fmt.Errorf("failed to get saved search %d: %w", id, err)
+	}
+	return s, nil
+}
+
+// AddPostSource records that a post was ingested through a subscription
+func (db *DB) AddPostSource(postId, sourceType, sourceName string) error {
+	if _, err := db.Exec(addPostSourceQuery, postId, sourceType, sourceName); err != nil {
+		return fmt.Errorf("failed to add %s source for post %s: %w", sourceType, postId, err)
+	}
+	return nil
+}
+
+// addPostSourceQuery links a post to a subscription unless it already is
+const addPostSourceQuery = `
+        INSERT INTO post_sources (post_id, source_type, source_name)
+        VALUES ($1, $2, $3)
+        ON CONFLICT DO NOTHING
+    `
+
+// GetSourcePosts retrieves a page of the posts ingested through a subscription, newest first
+func (db *DB) GetSourcePosts(sourceType, sourceName string, pagination Paginate) (PaginatedResult[SubredditPostDao], error) {
+	page, err := db.getPostsPage(`posts p JOIN post_sources s ON s.post_id = p.post_id`,
+		`s.source_type = $1 AND s.source_name = $2`, []any{sourceType, sourceName}, PostQuery{}, pagination)
+	if err != nil {
+		return page, fmt.Errorf("failed to fetch posts for %s %s: %w", sourceType, sourceName, err)
+	}
+	return page, nil
+}
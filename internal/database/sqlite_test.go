@@ -0,0 +1,18 @@
+package database_test
+
+import (
+	"testing"
+
+	"github.com/samratjha96/hecate/internal/database"
+	"github.com/samratjha96/hecate/internal/database/conformance"
+)
+
+func TestSQLiteConformance(t *testing.T) {
+	db, err := database.NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB: %v", err)
+	}
+	defer db.Close()
+
+	conformance.Run(t, db)
+}
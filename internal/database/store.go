@@ -0,0 +1,112 @@
+package database
+
+import (
+	"time"
+
+	"github.com/samratjha96/hecate/internal/reddit"
+)
+
+// Store is the storage the service layer runs on. DB implements it on SQLite and the
+// postgres package on PostgreSQL.
+// Paginated listings return ErrInvalidCursor for cursors they did not issue.
+type Store interface {
+	SubredditStore
+	PostStore
+	CommentStore
+	JobStore
+	SavedSearchStore
+	FollowedUserStore
+	ScheduleStore
+
+	// Migrate applies every pending schema migration
+	Migrate() error
+	MigrateUp(steps int) (int, error)
+	MigrateDown(steps int) (int, error)
+	MigrationStatus() ([]MigrationStatus, error)
+	Close() error
+}
+
+// SubredditStore stores subreddits. Names match case-insensitively.
+type SubredditStore interface {
+	GetAllSubreddits() ([]SubredditDao, error)
+	// GetSubreddits returns a page of subreddits in the order they were added
+	GetSubreddits(pagination Paginate) (PaginatedResult[SubredditDao], error)
+	// GetSubredditName returns the canonical name of a stored subreddit or ErrNotFound
+	GetSubredditName(name string) (string, error)
+	UpdateSubscriberCount(name string, numberOfSubscribers int) error
+	// SetSubredditSubscription records the options a subreddit was subscribed with
+	SetSubredditSubscription(name string, subscription SubredditSubscriptionDao) error
+	// GetSubredditSubscription returns the options a stored subreddit was subscribed with or ErrNotFound
+	GetSubredditSubscription(name string) (SubredditSubscriptionDao, error)
+	// UpsertSubredditWithPosts writes a subreddit and its posts atomically
+	UpsertSubredditWithPosts(name string, about reddit.SubredditAbout, posts []reddit.RedditPost) (UpsertCounts, error)
+}
+
+// PostStore stores posts and the subscriptions that ingested them
+type PostStore interface {
+	// UpsertPost stores a post, reporting whether it was new
+	UpsertPost(post reddit.RedditPost, subredditName string) (bool, error)
+	// UpsertPosts stores posts atomically under subredditName, or their own subreddit when
+	// it is empty, and links them to the subscription sourceType and sourceName when set
+	UpsertPosts(posts []reddit.RedditPost, subredditName, sourceType, sourceName string) (UpsertCounts, error)
+	// GetSubredditPosts returns a page of the posts of a subreddit sorted and filtered by
+	// query, returning ErrInvalidPostQuery for queries that fail validation
+	GetSubredditPosts(subredditName string, query PostQuery, pagination Paginate) (PaginatedResult[SubredditPostDao], error)
+	// SearchPosts runs a searchquery query, an empty subreddit searching all of them
+	SearchPosts(query, subreddit string) ([]SearchResultDao, error)
+	AddPostSource(postId, sourceType, sourceName string) error
+	// GetSourcePosts returns a page of the posts a subscription ingested, newest first
+	GetSourcePosts(sourceType, sourceName string, pagination Paginate) (PaginatedResult[SubredditPostDao], error)
+}
+
+// CommentStore stores the comment trees of posts
+type CommentStore interface {
+	// UpsertComments stores the comment tree of a stored post, returning ErrNotFound
+	// when the post is not stored
+	UpsertComments(postId string, comments []reddit.Comment) (int, error)
+	GetPostComments(postId string) ([]CommentDao, error)
+}
+
+// JobStore stores background jobs
+type JobStore interface {
+	CreateJob(kind, status, request string) (int64, error)
+	GetJob(id int64) (JobDao, error)
+	GetRecentJobs() ([]JobDao, error)
+	StartJob(id int64, status string, startedAt time.Time) error
+	UpdateJobProgress(id int64, completed, total int) error
+	FinishJob(id int64, status, result, jobError string, finishedAt time.Time) error
+	FailUnfinishedJobs(failedStatus, jobError string, unfinished ...string) (int64, error)
+}
+
+// SavedSearchStore stores saved Reddit search queries
+type SavedSearchStore interface {
+	UpsertSavedSearch(query, subreddit, sort, timeWindow string) (int64, error)
+	GetAllSavedSearches() ([]SavedSearchDao, error)
+	// GetSavedSearches returns a page of saved searches in the order they were saved
+	GetSavedSearches(pagination Paginate) (PaginatedResult[SavedSearchDao], error)
+	GetSavedSearch(id int64) (SavedSearchDao, error)
+}
+
+// FollowedUserStore stores followed Reddit users. Names match case-insensitively.
+type FollowedUserStore interface {
+	UpsertFollowedUser(name, listing, timeWindow string) (int64, error)
+	GetAllFollowedUsers() ([]FollowedUserDao, error)
+	// GetFollowedUsers returns a page of followed users in the order they were followed
+	GetFollowedUsers(pagination Paginate) (PaginatedResult[FollowedUserDao], error)
+	GetFollowedUser(name string) (FollowedUserDao, error)
+}
+
+// ScheduleStore stores the ingestion schedules of subscriptions
+type ScheduleStore interface {
+	EnsureSchedule(kind, name, listing, timeWindow string, nextRunAt time.Time) (int64, error)
+	UpsertSchedule(kind, name, listing, timeWindow string, interval time.Duration, nextRunAt time.Time) (int64, error)
+	// MoveSchedule moves the schedule of a subscription from fromListing to listing
+	MoveSchedule(kind, name, fromListing, listing, timeWindow string) error
+	GetAllSchedules() ([]ScheduleDao, error)
+	GetDueSchedules(now time.Time) ([]ScheduleDao, error)
+	GetSchedule(id int64) (ScheduleDao, error)
+	SetSchedulePaused(id int64, paused bool) error
+	RecordScheduleRun(id int64, ranAt, nextRunAt time.Time, lastError string) error
+}
+
+var _ Store = (*DB)(nil)
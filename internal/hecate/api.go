@@ -2,96 +2,324 @@ package hecate
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"html"
 	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/samratjha96/hecate/internal/database"
 	"github.com/samratjha96/hecate/internal/reddit"
 )
 
+// ErrInvalidRequest is returned when a subscription request fails validation
+var ErrInvalidRequest = errors.New("invalid request")
+
 const (
-	userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:123.0) Gecko/20100101 Firefox/123.0"
+	userAgent     = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:123.0) Gecko/20100101 Firefox/123.0"
+	redditTimeout = 30 * time.Second
 )
 
-// IngestAllSubreddit ingests posts from all subreddits in the database
-func IngestAllSubreddit(ctx context.Context, db *database.DB, sortBy string) error {
-	subreddits, err := db.GetAllSubreddits()
+// NewRedditClient creates the Reddit client shared by all ingestion. It authenticates
+// with OAuth2 when REDDIT_CLIENT_ID is set and falls back to anonymous access otherwise.
+// REDDIT_FIXTURES_MODE set to record or replay saves or serves responses from REDDIT_FIXTURES_DIR.
+func NewRedditClient() *reddit.Client {
+	agent := os.Getenv("REDDIT_USER_AGENT")
+	if agent == "" {
+		agent = userAgent
+	}
+
+	opts := []reddit.Option{reddit.WithCredentials(reddit.CredentialsFromEnv())}
+	fixturesDir := os.Getenv("REDDIT_FIXTURES_DIR")
+	switch mode := os.Getenv("REDDIT_FIXTURES_MODE"); mode {
+	case "":
+	case "record":
+		log.Printf("Recording Reddit responses to %s", fixturesDir)
+		opts = append(opts, reddit.WithRequestDoer(reddit.NewRecorder(fixturesDir, &http.Client{Timeout: redditTimeout})))
+	case "replay":
+		log.Printf("Replaying Reddit responses from %s", fixturesDir)
+		opts = append(opts, reddit.WithRequestDoer(reddit.NewReplayer(fixturesDir)))
+	default:
+		log.Printf("Ignoring unknown REDDIT_FIXTURES_MODE: %s", mode)
+	}
+
+	client := reddit.NewClient(agent, opts...)
+	if client.Authenticated() {
+		log.Println("Using authenticated Reddit API access")
+	} else {
+		log.Println("Reddit credentials not configured, using anonymous Reddit API access")
+	}
+	return client
+}
+
+// IngestSubreddit ingests posts from a single subreddit and records what it was subscribed
+// with, which its schedule refreshes it with
+func IngestSubreddit(ctx context.Context, db database.Store, client *reddit.Client, subreddit RedditSubscription) (reddit.Subreddit, error) {
+	response, err := ingestSubreddit(ctx, db, client, subreddit)
 	if err != nil {
-		return fmt.Errorf("failed to fetch subreddits: %w", err)
-	}
-
-	log.Printf("Starting ingestion for %d subreddits", len(subreddits))
-	for _, subreddit := range subreddits {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			log.Printf("Ingesting subreddit: %s", subreddit.Name)
-			if _, err := IngestSubreddit(ctx, db, RedditSubscription{Name: subreddit.Name, SortBy: sortBy}); err != nil {
-				log.Printf("Error ingesting subreddit %s: %v", subreddit.Name, err)
-				// Continue with the next subreddit instead of returning the error
-				continue
-			}
-		}
+		return response, err
+	}
+	if err := recordSubredditSubscription(db, response.Name, subreddit); err != nil {
+		return response, err
+	}
+	return response, nil
+}
+
+// recordSubredditSubscription stores the options a subreddit was subscribed with. Its
+// schedule is moved to the new listing, so resubscribing replaces what it refreshes.
+func recordSubredditSubscription(db database.Store, name string, subreddit RedditSubscription) error {
+	previous, err := db.GetSubredditSubscription(name)
+	if err != nil {
+		return fmt.Errorf("failed to fetch subreddit subscription: %w", err)
+	}
+	subscription := subredditSubscriptionDao(subreddit)
+	if err := db.SetSubredditSubscription(name, subscription); err != nil {
+		return fmt.Errorf("failed to store subreddit subscription: %w", err)
+	}
+
+	previousListing, _ := subredditScheduleListing(previous)
+	listing, timeWindow := subredditScheduleListing(subscription)
+	if err := db.MoveSchedule(KindSubreddit, name, previousListing, listing, timeWindow); err != nil {
+		return fmt.Errorf("failed to update subreddit schedule: %w", err)
 	}
-	log.Printf("Completed ingestion for all subreddits")
 	return nil
 }
 
-// IngestSubreddit ingests posts from a single subreddit
-func IngestSubreddit(ctx context.Context, db *database.DB, subreddit RedditSubscription) (reddit.Subreddit, error) {
-	log.Printf("Fetching data for subreddit: %s (Sort: %s)", subreddit.Name, subreddit.SortBy)
+// subredditSubscriptionDao converts a subscription to the options stored with its subreddit
+func subredditSubscriptionDao(s RedditSubscription) database.SubredditSubscriptionDao {
+	opts := s.listingOptions()
+	dao := database.SubredditSubscriptionDao{
+		Listing:    string(opts.Listing),
+		TimeWindow: string(opts.TimeWindow),
+		Limit:      opts.Limit,
+		MaxPosts:   opts.MaxPosts,
+	}
+	if s.Comments != nil {
+		dao.Comments = true
+		dao.CommentDepth = s.Comments.Depth
+		dao.CommentLimit = s.Comments.Limit
+	}
+	return dao
+}
+
+// subredditSubscription converts the options stored with a subreddit back to its subscription
+func subredditSubscription(name string, dao database.SubredditSubscriptionDao) RedditSubscription {
+	subscription := RedditSubscription{
+		Name:       name,
+		Listing:    reddit.Listing(dao.Listing),
+		TimeWindow: reddit.TimeWindow(dao.TimeWindow),
+		Limit:      dao.Limit,
+		MaxPosts:   dao.MaxPosts,
+	}
+	if dao.Comments {
+		subscription.Comments = &CommentSettings{Depth: dao.CommentDepth, Limit: dao.CommentLimit}
+	}
+	return subscription
+}
+
+// ingestSubreddit fetches and stores the posts, and comments when enabled, of a subreddit
+func ingestSubreddit(ctx context.Context, db database.Store, client *reddit.Client, subreddit RedditSubscription) (reddit.Subreddit, error) {
+	name, err := reddit.NormalizeSubredditName(subreddit.Name)
+	if err != nil {
+		return reddit.Subreddit{}, err
+	}
 
-	client := reddit.NewClient(userAgent)
+	opts := subreddit.listingOptions()
+	log.Printf("Fetching data for subreddit: %s (Listing: %s, Time window: %s)", name, opts.Listing, opts.TimeWindow)
 
-	response, err := client.DescribeSubreddit(ctx, subreddit.Name, subreddit.SortBy)
+	// about.json validates the subreddit and resolves its canonical name before anything is stored
+	about, err := client.AboutSubreddit(ctx, name)
+	if err != nil {
+		return reddit.Subreddit{}, fmt.Errorf("failed to fetch subreddit metadata: %w", err)
+	}
+	if about.Name != "" {
+		name = about.Name
+	}
+
+	response, err := client.DescribeSubreddit(ctx, name, opts)
 	if err != nil {
 		return response, fmt.Errorf("failed to fetch subreddit posts: %w", err)
 	}
+	response.NumberOfSubscribers = about.NumberOfSubscribers
 
-	log.Printf("Successfully fetched %d posts for subreddit: %s", len(response.Posts), subreddit.Name)
+	log.Printf("Successfully fetched %d posts for subreddit: %s", len(response.Posts), name)
 
-	if err := upsertSubredditAndPosts(ctx, db, response, subreddit.Name, subreddit.SortBy); err != nil {
+	if err := upsertSubredditAndPosts(ctx, db, about, response, name); err != nil {
 		return response, fmt.Errorf("failed to upsert subreddit and posts: %w", err)
 	}
 
+	if subreddit.Comments != nil {
+		ingestComments(ctx, db, client, response.Posts, subreddit.Comments.commentOptions())
+	}
+
 	return response, nil
 }
 
-// upsertSubredditAndPosts handles database operations for subreddit and its posts
-func upsertSubredditAndPosts(ctx context.Context, db *database.DB, response reddit.Subreddit, subredditName, sortBy string) error {
-	if _, err := db.UpsertSubreddit(response.Name, response.NumberOfSubscribers); err != nil {
-		return fmt.Errorf("failed to upsert subreddit: %w", err)
+// ingestComments fetches and stores the comment tree of every post, logging failures per post
+func ingestComments(ctx context.Context, db database.Store, client *reddit.Client, posts reddit.RedditPosts, opts reddit.CommentOptions) {
+	log.Printf("Ingesting comments for %d posts", len(posts))
+	for _, post := range posts {
+		if ctx.Err() != nil {
+			return
+		}
+
+		comments, err := client.DescribeComments(ctx, post.PostId, opts)
+		if err != nil {
+			log.Printf("Error fetching comments for post %s: %v", post.PostId, err)
+			continue
+		}
+		if _, err := db.UpsertComments(post.PostId, comments); err != nil {
+			log.Printf("Error upserting comments for post %s: %v", post.PostId, err)
+			continue
+		}
 	}
+}
 
-	log.Printf("Upserting %d %s posts for r/%s", len(response.Posts), sortBy, subredditName)
+// upsertSubredditAndPosts writes a subreddit and its posts in a single transaction
+func upsertSubredditAndPosts(ctx context.Context, db database.Store, about reddit.SubredditAbout, response reddit.Subreddit, subredditName string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 
-	for _, post := range response.Posts {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			if err := db.UpsertPost(post, subredditName); err != nil {
-				log.Printf("Error upserting post %s: %v", post.PostId, err)
-				// Continue with the next post instead of returning the error
-				continue
-			}
-		}
+	log.Printf("Upserting %d posts for r/%s", len(response.Posts), subredditName)
+	if _, err := db.UpsertSubredditWithPosts(subredditName, about, response.Posts); err != nil {
+		return err
 	}
-	log.Printf("Successfully upserted all posts for r/%s", subredditName)
 	return nil
 }
 
-// GetAllSubreddits retrieves all subreddits from the database
-func GetAllSubreddits(db *database.DB) ([]SubredditFrontendResponse, error) {
-	fetchedSubredditDaos, err := db.GetAllSubreddits()
+// upsertPosts stores fetched posts in one batch. Posts are stored under subredditName,
+// or their own subreddit when it is empty, and linked to the subscription identified by
+// sourceType and sourceName when it is set. A failed batch writes nothing, so every
+// post is counted as failed.
+func upsertPosts(ctx context.Context, db database.Store, posts reddit.RedditPosts, subredditName, sourceType, sourceName string) (IngestCounts, error) {
+	counts := IngestCounts{Fetched: len(posts)}
+	if ctx.Err() != nil {
+		return counts, ctx.Err()
+	}
+	log.Printf("Upserting %d posts for %s", len(posts), postsOwner(subredditName, sourceType, sourceName))
+
+	upserted, err := db.UpsertPosts(posts, subredditName, sourceType, sourceName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch subreddits: %w", err)
+		log.Printf("Error upserting posts for %s: %v", postsOwner(subredditName, sourceType, sourceName), err)
+		counts.Failed = len(posts)
+		return counts, fmt.Errorf("failed to upsert posts: %w", err)
+	}
+	counts.Inserted, counts.Updated = upserted.Inserted, upserted.Updated
+	log.Printf("Upserted posts for %s: %d inserted, %d updated", postsOwner(subredditName, sourceType, sourceName), counts.Inserted, counts.Updated)
+	return counts, nil
+}
+
+// postsOwner describes the subscription posts are stored for in log messages
+func postsOwner(subredditName, sourceType, sourceName string) string {
+	if sourceType != "" {
+		return sourceType + " " + sourceName
+	}
+	return "r/" + subredditName
+}
+
+// subscriptionKinds is the order GET /api/subreddits lists subscriptions in, each kind in
+// the order it was added
+var subscriptionKinds = []string{KindSubreddit, KindSearch, KindUser}
+
+// GetSubreddits retrieves a page of subscriptions: subreddits, then saved searches, then
+// followed users. Its cursor records the kind of the last subscription and the cursor of
+// that kind's listing.
+func GetSubreddits(db database.Store, pagination database.Paginate) (SubredditsResponse, error) {
+	kind := 0
+	var after database.Cursor
+	if pagination.Cursor != "" {
+		cursor, err := database.DecodeCursor(pagination.Cursor)
+		if err != nil {
+			return SubredditsResponse{}, err
+		}
+		kind = slices.Index(subscriptionKinds, cursor.Key)
+		if kind < 0 {
+			return SubredditsResponse{}, fmt.Errorf("%w: unknown subscription kind %q", database.ErrInvalidCursor, cursor.Key)
+		}
+		after = database.Cursor{Id: cursor.Id}
 	}
 
-	responses := convertToSubredditResponses(fetchedSubredditDaos)
-	log.Printf("Retrieved %d subreddits", len(responses))
-	return responses, nil
+	response := SubredditsResponse{Subreddits: []SubredditFrontendResponse{}}
+	for ; kind < len(subscriptionKinds); kind++ {
+		remaining := pagination.Limit - len(response.Subreddits)
+		kindPagination := database.Paginate{Limit: max(remaining, 1)}
+		if after.Id > 0 {
+			kindPagination.Cursor = after.Encode()
+		}
+		after = database.Cursor{}
+
+		page, err := getSubscriptions(db, subscriptionKinds[kind], kindPagination)
+		if err != nil {
+			return SubredditsResponse{}, err
+		}
+		if remaining == 0 {
+			// The page is full, so the following one starts at the next kind that has subscriptions
+			if len(page.Items) > 0 {
+				response.Next = database.Cursor{Key: subscriptionKinds[kind]}.Encode()
+				break
+			}
+			continue
+		}
+
+		response.Subreddits = append(response.Subreddits, page.Items...)
+		if page.Next != "" {
+			next, err := database.DecodeCursor(page.Next)
+			if err != nil {
+				return SubredditsResponse{}, err
+			}
+			response.Next = database.Cursor{Key: subscriptionKinds[kind], Id: next.Id}.Encode()
+			break
+		}
+	}
+	log.Printf("Retrieved %d subscriptions", len(response.Subreddits))
+	return response, nil
+}
+
+// getSubscriptions retrieves a page of the subscriptions of one kind
+func getSubscriptions(db database.Store, kind string, pagination database.Paginate) (database.PaginatedResult[SubredditFrontendResponse], error) {
+	var result database.PaginatedResult[SubredditFrontendResponse]
+	switch kind {
+	case KindSubreddit:
+		page, err := db.GetSubreddits(pagination)
+		if err != nil {
+			return result, fmt.Errorf("failed to fetch subreddits: %w", err)
+		}
+		result.Items, result.Next = convertToSubredditResponses(page.Items), page.Next
+	case KindSearch:
+		page, err := db.GetSavedSearches(pagination)
+		if err != nil {
+			return result, fmt.Errorf("failed to fetch saved searches: %w", err)
+		}
+		for _, search := range page.Items {
+			result.Items = append(result.Items, SubredditFrontendResponse{
+				Kind:      KindSearch,
+				Name:      search.Query,
+				SearchId:  search.Id,
+				Subreddit: search.Subreddit,
+				CreatedAt: search.CreatedAt,
+			})
+		}
+		result.Next = page.Next
+	case KindUser:
+		page, err := db.GetFollowedUsers(pagination)
+		if err != nil {
+			return result, fmt.Errorf("failed to fetch followed users: %w", err)
+		}
+		for _, user := range page.Items {
+			result.Items = append(result.Items, SubredditFrontendResponse{
+				Kind:      KindUser,
+				Name:      user.Name,
+				CreatedAt: user.CreatedAt,
+			})
+		}
+		result.Next = page.Next
+	}
+	return result, nil
 }
 
 // convertToSubredditResponses converts database objects to frontend response objects
@@ -99,36 +327,135 @@ func convertToSubredditResponses(daos []database.SubredditDao) []SubredditFronte
 	responses := make([]SubredditFrontendResponse, len(daos))
 	for i, dao := range daos {
 		responses[i] = SubredditFrontendResponse{
+			Kind:                KindSubreddit,
 			Name:                dao.Name,
 			NumberOfSubscribers: dao.NumberOfSubscribers,
+			Title:               dao.Title,
+			PublicDescription:   dao.PublicDescription,
+			CreatedAt:           dao.CreatedAt,
+			Nsfw:                dao.Nsfw,
+			ActiveUsers:         dao.ActiveUsers,
+			IconUrl:             dao.IconUrl,
 		}
 	}
 	return responses
 }
 
-// GetAllPostsForSubreddit retrieves all posts for a specific subreddit
-func GetAllPostsForSubreddit(db *database.DB, subredditName string) ([]SubredditPostFrontendResponse, error) {
-	fetchedPosts, err := db.GetSubredditPosts(subredditName)
+// GetPostsForSubreddit retrieves a page of the posts of a specific subreddit, sorted and filtered by query
+func GetPostsForSubreddit(db database.Store, subredditName string, query database.PostQuery, pagination database.Paginate) (PostsResponse, error) {
+	page, err := db.GetSubredditPosts(subredditName, query, pagination)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get posts for subreddit %s: %w", subredditName, err)
+		return PostsResponse{}, fmt.Errorf("failed to get posts for subreddit %s: %w", subredditName, err)
 	}
 
-	responses := convertToPostResponses(fetchedPosts)
-	log.Printf("Retrieved %d posts for subreddit: %s", len(responses), subredditName)
-	return responses, nil
+	log.Printf("Retrieved %d posts for subreddit: %s", len(page.Items), subredditName)
+	return convertToPostsResponse(page), nil
+}
+
+// convertToPostsResponse converts a page of database objects to a frontend response object
+func convertToPostsResponse(page database.PaginatedResult[database.SubredditPostDao]) PostsResponse {
+	return PostsResponse{
+		Posts: convertToPostResponses(page.Items),
+		Next:  page.Next,
+	}
 }
 
 // convertToPostResponses converts database objects to frontend response objects
 func convertToPostResponses(daos []database.SubredditPostDao) []SubredditPostFrontendResponse {
 	responses := make([]SubredditPostFrontendResponse, len(daos))
 	for i, dao := range daos {
-		responses[i] = SubredditPostFrontendResponse{
-			Title:         dao.Title,
-			Content:       dao.Content,
-			DiscussionURL: dao.DiscussionURL,
-			CommentCount:  dao.CommentCount,
-			Upvotes:       dao.Upvotes,
-		}
+		responses[i] = convertToPostResponse(dao)
 	}
 	return responses
 }
+
+// convertToPostResponse converts a database object to a frontend response object
+func convertToPostResponse(dao database.SubredditPostDao) SubredditPostFrontendResponse {
+	return SubredditPostFrontendResponse{
+		PostId:           dao.PostId,
+		Fullname:         dao.Fullname,
+		Title:            dao.Title,
+		Content:          dao.Content,
+		Author:           dao.Author,
+		DiscussionURL:    dao.DiscussionURL,
+		Url:              dao.Url,
+		Domain:           dao.Domain,
+		IsSelf:           dao.IsSelf,
+		Thumbnail:        dao.Thumbnail,
+		Flair:            dao.Flair,
+		Stickied:         dao.Stickied,
+		Pinned:           dao.Pinned,
+		Nsfw:             dao.Nsfw,
+		Spoiler:          dao.Spoiler,
+		CommentCount:     dao.CommentCount,
+		Upvotes:          dao.Upvotes,
+		UpvoteRatio:      dao.UpvoteRatio,
+		SubredditName:    dao.SubredditName,
+		CrosspostParents: dao.CrosspostParents,
+	}
+}
+
+// SearchPosts runs a full-text search over posts, across all subreddits when subreddit is empty
+func SearchPosts(db database.Store, query, subreddit string) (SearchPostsResponse, error) {
+	results, err := db.SearchPosts(query, subreddit)
+	if err != nil {
+		return SearchPostsResponse{}, fmt.Errorf("failed to search posts: %w", err)
+	}
+
+	posts := make([]SearchPostFrontendResponse, len(results))
+	for i, result := range results {
+		posts[i] = SearchPostFrontendResponse{
+			SubredditPostFrontendResponse: convertToPostResponse(result.SubredditPostDao),
+			Snippet:                       highlightSnippet(result.Snippet),
+			Score:                         result.Score,
+		}
+	}
+	return SearchPostsResponse{Posts: posts}, nil
+}
+
+// snippetHighlighter marks up the matches of an escaped snippet
+var snippetHighlighter = strings.NewReplacer(database.SnippetStart, "<mark>", database.SnippetEnd, "</mark>")
+
+// highlightSnippet escapes post text in a snippet so it can be rendered as HTML, wrapping matches in <mark> tags
+func highlightSnippet(snippet string) string {
+	return snippetHighlighter.Replace(html.EscapeString(snippet))
+}
+
+// GetPostComments retrieves the comment tree of a post, nesting replies under their parents
+func GetPostComments(db database.Store, postId string) (PostCommentsResponse, error) {
+	daos, err := db.GetPostComments(postId)
+	if err != nil {
+		return PostCommentsResponse{}, fmt.Errorf("failed to get comments for post %s: %w", postId, err)
+	}
+
+	log.Printf("Retrieved %d comments for post: %s", len(daos), postId)
+	return PostCommentsResponse{
+		PostId:   postId,
+		Comments: buildCommentTree(daos),
+	}, nil
+}
+
+// buildCommentTree nests comments under their parents, keeping the database order among siblings
+func buildCommentTree(daos []database.CommentDao) []CommentFrontendResponse {
+	children := make(map[string][]database.CommentDao)
+	for _, dao := range daos {
+		children[dao.ParentCommentId] = append(children[dao.ParentCommentId], dao)
+	}
+
+	var build func(parentId string) []CommentFrontendResponse
+	build = func(parentId string) []CommentFrontendResponse {
+		responses := make([]CommentFrontendResponse, 0, len(children[parentId]))
+		for _, dao := range children[parentId] {
+			responses = append(responses, CommentFrontendResponse{
+				Id:        dao.CommentId,
+				Author:    dao.Author,
+				Content:   dao.Content,
+				Upvotes:   dao.Upvotes,
+				CreatedAt: dao.TimePosted,
+				Replies:   build(dao.CommentId),
+			})
+		}
+		return responses
+	}
+	return build("")
+}
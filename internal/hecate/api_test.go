@@ -0,0 +1,91 @@
+package hecate
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/samratjha96/hecate/internal/database"
+	"github.com/samratjha96/hecate/internal/reddit"
+)
+
+func TestGetSubredditsPagesEverySubscription(t *testing.T) {
+	tests := []struct {
+		name  string
+		users []string
+		limit int
+		pages [][]string
+	}{
+		{
+			name:  "pages end with each kind",
+			users: []string{"spez"},
+			limit: 2,
+			pages: [][]string{{"golang", "rust"}, {"search:kyoto", "search:osaka"}, {"user:spez"}},
+		},
+		{
+			name:  "pages span kinds",
+			users: []string{"spez"},
+			limit: 3,
+			pages: [][]string{{"golang", "rust", "search:kyoto"}, {"search:osaka", "user:spez"}},
+		},
+		{
+			name:  "full page before empty kinds is the last",
+			limit: 4,
+			pages: [][]string{{"golang", "rust", "search:kyoto", "search:osaka"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestStore(t)
+			for _, name := range []string{"golang", "rust"} {
+				if _, err := db.UpsertSubredditWithPosts(name, reddit.SubredditAbout{}, nil); err != nil {
+					t.Fatalf("UpsertSubredditWithPosts: %v", err)
+				}
+			}
+			for _, query := range []string{"kyoto", "osaka"} {
+				if _, err := db.UpsertSavedSearch(query, "", "relevance", "all"); err != nil {
+					t.Fatalf("UpsertSavedSearch: %v", err)
+				}
+			}
+			for _, name := range tt.users {
+				if _, err := db.UpsertFollowedUser(name, "new", ""); err != nil {
+					t.Fatalf("UpsertFollowedUser: %v", err)
+				}
+			}
+
+			var pages [][]string
+			pagination := database.Paginate{Limit: tt.limit}
+			for {
+				response, err := GetSubreddits(db, pagination)
+				if err != nil {
+					t.Fatalf("GetSubreddits: %v", err)
+				}
+				var page []string
+				for _, subscription := range response.Subreddits {
+					name := subscription.Name
+					if subscription.Kind != KindSubreddit {
+						name = fmt.Sprintf("%s:%s", subscription.Kind, subscription.Name)
+					}
+					page = append(page, name)
+				}
+				pages = append(pages, page)
+				if response.Next == "" || len(pages) > len(tt.pages) {
+					break
+				}
+				pagination.Cursor = response.Next
+			}
+			if !slices.EqualFunc(pages, tt.pages, slices.Equal) {
+				t.Errorf("got pages %v, want %v", pages, tt.pages)
+			}
+		})
+	}
+}
+
+func TestGetSubredditsRejectsCursorOfUnknownKind(t *testing.T) {
+	cursor := database.Cursor{Key: "channel", Id: 1}.Encode()
+	_, err := GetSubreddits(newTestStore(t), database.Paginate{Limit: 10, Cursor: cursor})
+	if !errors.Is(err, database.ErrInvalidCursor) {
+		t.Errorf("got %v, want ErrInvalidCursor", err)
+	}
+}
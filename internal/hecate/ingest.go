@@ -0,0 +1,281 @@
+package hecate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/samratjha96/hecate/internal/database"
+	"github.com/samratjha96/hecate/internal/reddit"
+)
+
+const (
+	// DefaultIngestConcurrency is the number of ingestion workers used when none is configured
+	DefaultIngestConcurrency = 4
+	// MaxIngestConcurrency caps the number of ingestion workers
+	MaxIngestConcurrency = 16
+	// multiredditBatchSize is the number of subreddits combined into one listing request
+	multiredditBatchSize = 20
+)
+
+// IngestAllOptions configures a run over every subscription
+type IngestAllOptions struct {
+	Listing    reddit.Listing
+	TimeWindow reddit.TimeWindow
+	// Comments enables ingesting the comment trees of the fetched posts
+	Comments *CommentSettings
+	// Concurrency is the number of workers, falling back to INGEST_CONCURRENCY or DefaultIngestConcurrency
+	Concurrency int
+	// Progress is called with the number of subscriptions done whenever a task finishes
+	Progress func(completed, total int)
+}
+
+// ingestTask ingests one unit of work: a batch of subreddits, a saved search or a followed user
+type ingestTask func(ctx context.Context) []IngestResult
+
+// IngestAllSubreddit ingests posts from every subscription in the database using a pool of
+// workers that share the client, and with it the rate limiter. Subreddits are fetched in
+// batches of combined r/a+b+c listings to cut the number of requests. The report holds one
+// result per subscription; an error is returned when the subscriptions could not be listed,
+// the run was cancelled, or every subscription failed.
+func IngestAllSubreddit(ctx context.Context, db database.Store, client *reddit.Client, opts IngestAllOptions) (IngestReport, error) {
+	tasks, total, err := ingestTasks(db, client, opts)
+	if err != nil {
+		return IngestReport{}, err
+	}
+
+	progress := opts.Progress
+	if progress == nil {
+		progress = func(completed, total int) {}
+	}
+	progress(0, total)
+
+	concurrency := resolveIngestConcurrency(opts.Concurrency)
+	log.Printf("Starting ingestion of %d tasks with %d workers", len(tasks), concurrency)
+	report := newIngestReport(runIngestTasks(ctx, tasks, concurrency, func(completed int) {
+		progress(completed, total)
+	}))
+	log.Printf("Completed ingestion: %d of %d subscriptions failed, %d posts inserted, %d updated, %d failed",
+		report.FailedSubscriptions, len(report.Results), report.Totals.Inserted, report.Totals.Updated, report.Totals.Failed)
+
+	if ctx.Err() != nil {
+		return report, ctx.Err()
+	}
+	if report.FailedSubscriptions > 0 && report.FailedSubscriptions == len(report.Results) {
+		return report, fmt.Errorf("all %d subscriptions failed: %w", len(report.Results), report.firstErr)
+	}
+	return report, nil
+}
+
+// ingestTasks lists the work of a run: subreddit batches, then saved searches, then followed
+// users, along with the number of subscriptions they cover
+func ingestTasks(db database.Store, client *reddit.Client, opts IngestAllOptions) ([]ingestTask, int, error) {
+	subreddits, err := db.GetAllSubreddits()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch subreddits: %w", err)
+	}
+	savedSearches, err := db.GetAllSavedSearches()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch saved searches: %w", err)
+	}
+	followedUsers, err := db.GetAllFollowedUsers()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch followed users: %w", err)
+	}
+
+	var tasks []ingestTask
+	listing := reddit.ListingOptions{Listing: opts.Listing, TimeWindow: opts.TimeWindow}
+	for start := 0; start < len(subreddits); start += multiredditBatchSize {
+		batch := subreddits[start:min(start+multiredditBatchSize, len(subreddits))]
+		names := make([]string, len(batch))
+		for i, subreddit := range batch {
+			names[i] = subreddit.Name
+		}
+		tasks = append(tasks, func(ctx context.Context) []IngestResult {
+			return ingestSubredditBatch(ctx, db, client, names, listing, opts.Comments)
+		})
+	}
+
+	for _, search := range savedSearches {
+		tasks = append(tasks, func(ctx context.Context) []IngestResult {
+			result := IngestResult{Kind: KindSearch, Name: strconv.FormatInt(search.Id, 10)}
+			if ctx.Err() != nil {
+				return []IngestResult{result.failed(ctx.Err())}
+			}
+			log.Printf("Ingesting saved search %d: %q", search.Id, search.Query)
+			_, counts, err := ingestSearch(ctx, db, client, savedSearchSubscription(search))
+			result.IngestCounts = counts
+			if err != nil {
+				log.Printf("Error ingesting saved search %d: %v", search.Id, err)
+				return []IngestResult{result.failed(err)}
+			}
+			return []IngestResult{result}
+		})
+	}
+
+	for _, user := range followedUsers {
+		tasks = append(tasks, func(ctx context.Context) []IngestResult {
+			result := IngestResult{Kind: KindUser, Name: user.Name}
+			if ctx.Err() != nil {
+				return []IngestResult{result.failed(ctx.Err())}
+			}
+			log.Printf("Ingesting followed user: %s", user.Name)
+			_, counts, err := ingestUser(ctx, db, client, followedUserSubscription(user))
+			result.IngestCounts = counts
+			if err != nil {
+				log.Printf("Error ingesting followed user %s: %v", user.Name, err)
+				return []IngestResult{result.failed(err)}
+			}
+			return []IngestResult{result}
+		})
+	}
+	return tasks, len(subreddits) + len(savedSearches) + len(followedUsers), nil
+}
+
+// runIngestTasks runs the tasks on a pool of workers and returns their results in task
+// order, reporting the number of results collected so far after every task
+func runIngestTasks(ctx context.Context, tasks []ingestTask, concurrency int, progress func(completed int)) []IngestResult {
+	results := make([][]IngestResult, len(tasks))
+	indexes := make(chan int)
+
+	var mu sync.Mutex
+	completed := 0
+	var wg sync.WaitGroup
+	for range min(concurrency, len(tasks)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				taskResults := tasks[i](ctx)
+
+				mu.Lock()
+				results[i] = taskResults
+				completed += len(taskResults)
+				progress(completed)
+				mu.Unlock()
+			}
+		}()
+	}
+	// Tasks check the context themselves, so cancelled runs still report every subscription
+	for i := range tasks {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var flattened []IngestResult
+	for _, taskResults := range results {
+		flattened = append(flattened, taskResults...)
+	}
+	return flattened
+}
+
+// ingestSubredditBatch ingests the posts of several subreddits from one combined listing,
+// keeping as many posts per subreddit as a single subreddit ingest would. Busy subreddits
+// can crowd the others out of a combined listing, so when it runs out of budget, subreddits
+// it holds less than a page for are fetched on their own. When the combined listing fails,
+// every subreddit is fetched on its own, so one bad subreddit fails alone.
+func ingestSubredditBatch(ctx context.Context, db database.Store, client *reddit.Client, names []string, opts reddit.ListingOptions, comments *CommentSettings) []IngestResult {
+	results := make([]IngestResult, len(names))
+	for i, name := range names {
+		results[i] = IngestResult{Kind: KindSubreddit, Name: name}
+	}
+	failAll := func(err error) []IngestResult {
+		for i := range results {
+			results[i] = results[i].failed(err)
+		}
+		return results
+	}
+	if ctx.Err() != nil {
+		return failAll(ctx.Err())
+	}
+
+	batchOpts := opts
+	batchOpts.Limit = reddit.MaxPageSize
+	batchOpts.MaxPosts = reddit.DefaultPageSize * len(names)
+
+	// separate is true for subreddits the combined listing cannot stand in for
+	separate := make([]bool, len(names))
+	log.Printf("Ingesting subreddits: %s", strings.Join(names, "+"))
+	responses, err := client.DescribeSubreddits(ctx, names, batchOpts)
+	if err != nil {
+		if ctx.Err() != nil {
+			return failAll(ctx.Err())
+		}
+		log.Printf("Error ingesting subreddits %s, fetching them separately: %v", strings.Join(names, "+"), err)
+		responses = make([]reddit.Subreddit, len(names))
+		for i, name := range names {
+			responses[i] = reddit.Subreddit{Name: name}
+			separate[i] = true
+		}
+	} else {
+		// A listing that ended before the budget ran out holds every post of every subreddit
+		fetched := 0
+		for _, response := range responses {
+			fetched += len(response.Posts)
+		}
+		if fetched >= batchOpts.MaxPosts {
+			for i, response := range responses {
+				separate[i] = len(response.Posts) < reddit.DefaultPageSize
+			}
+		}
+	}
+
+	for i, response := range responses {
+		if ctx.Err() != nil {
+			results[i] = results[i].failed(ctx.Err())
+			continue
+		}
+		if separate[i] {
+			log.Printf("Fetching subreddit %s separately", response.Name)
+			single, err := client.DescribeSubreddit(ctx, response.Name, opts)
+			if err != nil {
+				log.Printf("Error ingesting subreddit %s: %v", response.Name, err)
+				results[i] = results[i].failed(fmt.Errorf("failed to fetch subreddit posts: %w", err))
+				continue
+			}
+			response = single
+		}
+		if len(response.Posts) > reddit.DefaultPageSize {
+			response.Posts = response.Posts[:reddit.DefaultPageSize]
+		}
+
+		log.Printf("Successfully fetched %d posts for subreddit: %s", len(response.Posts), response.Name)
+		if response.NumberOfSubscribers > 0 {
+			if err := db.UpdateSubscriberCount(response.Name, response.NumberOfSubscribers); err != nil {
+				log.Printf("Error updating subscribers of subreddit %s: %v", response.Name, err)
+			}
+		}
+		counts, err := upsertPosts(ctx, db, response.Posts, response.Name, "", "")
+		results[i].IngestCounts = counts
+		if err != nil {
+			results[i] = results[i].failed(err)
+			continue
+		}
+		if comments != nil {
+			ingestComments(ctx, db, client, response.Posts, comments.commentOptions())
+		}
+	}
+	return results
+}
+
+// resolveIngestConcurrency picks the number of workers from the request, then
+// INGEST_CONCURRENCY, then the default, capped at MaxIngestConcurrency
+func resolveIngestConcurrency(requested int) int {
+	concurrency := requested
+	if concurrency <= 0 {
+		concurrency = DefaultIngestConcurrency
+		if value := os.Getenv("INGEST_CONCURRENCY"); value != "" {
+			if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+				concurrency = parsed
+			} else {
+				log.Printf("Ignoring invalid INGEST_CONCURRENCY: %s", value)
+			}
+		}
+	}
+	return min(concurrency, MaxIngestConcurrency)
+}
@@ -0,0 +1,229 @@
+package hecate
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/samratjha96/hecate/internal/database"
+	"github.com/samratjha96/hecate/internal/reddit"
+)
+
+// fixtureDir holds Reddit responses recorded with REDDIT_FIXTURES_MODE=record
+const fixtureDir = "testdata/fixtures"
+
+// newTestStore creates a migrated SQLite store in a temporary directory
+func newTestStore(t *testing.T) *database.DB {
+	t.Helper()
+	t.Setenv("DB_DIRECTORY", t.TempDir())
+	db, err := database.NewDB()
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	return db
+}
+
+// newReplayClient creates a client serving the recorded fixtures
+func newReplayClient() *reddit.Client {
+	return reddit.NewClient("hecate-test", reddit.WithRequestDoer(reddit.NewReplayer(fixtureDir)))
+}
+
+func TestIngestSubreddit(t *testing.T) {
+	db := newTestStore(t)
+	subscription := RedditSubscription{
+		Name:       "r/golang",
+		Listing:    reddit.ListingTop,
+		TimeWindow: reddit.TimeWindowWeek,
+		Limit:      2,
+		MaxPosts:   4,
+		Comments:   &CommentSettings{Depth: 3},
+	}
+
+	response, err := IngestSubreddit(context.Background(), db, newReplayClient(), subscription)
+	if err != nil {
+		t.Fatalf("IngestSubreddit: %v", err)
+	}
+	if len(response.Posts) != 4 {
+		t.Errorf("fetched %d posts, want 4", len(response.Posts))
+	}
+
+	subreddits, err := db.GetAllSubreddits()
+	if err != nil {
+		t.Fatalf("GetAllSubreddits: %v", err)
+	}
+	if len(subreddits) != 1 {
+		t.Fatalf("stored %d subreddits, want 1", len(subreddits))
+	}
+	if stored := subreddits[0]; stored.Name != "golang" || stored.NumberOfSubscribers != 262145 || stored.Title != "The Go Programming Language" {
+		t.Errorf("stored subreddit %+v, want r/golang as described by its about.json", stored)
+	}
+
+	posts, err := db.GetSubredditPosts("golang", database.PostQuery{}, database.Paginate{Limit: 10})
+	if err != nil {
+		t.Fatalf("GetSubredditPosts: %v", err)
+	}
+	// Posts are listed newest first
+	var ids []string
+	for _, post := range posts.Items {
+		ids = append(ids, post.PostId)
+	}
+	if want := "1gx1d4e,1gx1c3d,1gx0b2c,1gx0a1b"; strings.Join(ids, ",") != want {
+		t.Errorf("stored posts %v, want %s", ids, want)
+	}
+
+	// Every post's thread is stored, with the comments behind "more" stubs expanded
+	for _, id := range ids {
+		comments, err := db.GetPostComments(id)
+		if err != nil {
+			t.Fatalf("GetPostComments(%s): %v", id, err)
+		}
+		if len(comments) != 6 {
+			t.Errorf("stored %d comments for post %s, want 6", len(comments), id)
+		}
+	}
+}
+
+func TestIngestSubredditIsIdempotent(t *testing.T) {
+	db := newTestStore(t)
+	client := newReplayClient()
+	subscription := RedditSubscription{Name: "golang", Listing: reddit.ListingTop, TimeWindow: reddit.TimeWindowWeek, Limit: 2, MaxPosts: 4}
+
+	for range 2 {
+		if _, err := IngestSubreddit(context.Background(), db, client, subscription); err != nil {
+			t.Fatalf("IngestSubreddit: %v", err)
+		}
+	}
+	posts, err := db.GetSubredditPosts("golang", database.PostQuery{}, database.Paginate{Limit: 10})
+	if err != nil {
+		t.Fatalf("GetSubredditPosts: %v", err)
+	}
+	if len(posts.Items) != 4 {
+		t.Errorf("stored %d posts after ingesting twice, want 4", len(posts.Items))
+	}
+}
+
+// newListingServer serves a listing of count posts of each subreddit, in name order, for
+// every listing path in listings, and counts the requests per path
+func newListingServer(t *testing.T, listings map[string]map[string]int) (*httptest.Server, func(path string) int) {
+	var mu sync.Mutex
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+
+		counts, ok := listings[r.URL.Path]
+		if !ok {
+			http.Error(w, `{"message": "Not Found", "error": 404}`, http.StatusNotFound)
+			return
+		}
+		var children []string
+		for _, subreddit := range slices.Sorted(maps.Keys(counts)) {
+			for i := range counts[subreddit] {
+				children = append(children, fmt.Sprintf(`{"data": {"id": "%s%d", "title": "post %d", "subreddit": %q, "created": %d}}`,
+					subreddit, i, i, subreddit, 1700000000+i))
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data": {"after": null, "children": [%s]}}`, strings.Join(children, ","))
+	}))
+	t.Cleanup(server.Close)
+	return server, func(path string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests[path]
+	}
+}
+
+func TestIngestSubredditBatchFetchesCrowdedOutSubreddits(t *testing.T) {
+	tests := []struct {
+		name     string
+		listings map[string]map[string]int
+		// fetched is the number of posts reported for busy and quiet
+		fetched  [2]int
+		failed   bool
+		requests int
+	}{
+		{
+			name: "crowded out subreddit is fetched on its own",
+			listings: map[string]map[string]int{
+				"/r/busy+quiet/top.json": {"busy": 2 * reddit.DefaultPageSize},
+				"/r/quiet/top.json":      {"quiet": 3},
+			},
+			fetched:  [2]int{reddit.DefaultPageSize, 3},
+			requests: 2,
+		},
+		{
+			name: "listing ending early holds every post",
+			listings: map[string]map[string]int{
+				"/r/busy+quiet/top.json": {"busy": 5},
+			},
+			fetched:  [2]int{5, 0},
+			requests: 1,
+		},
+		{
+			name: "partly crowded out subreddit is fetched on its own",
+			listings: map[string]map[string]int{
+				"/r/busy+quiet/top.json": {"busy": 2*reddit.DefaultPageSize - 3, "quiet": 3},
+				"/r/quiet/top.json":      {"quiet": 10},
+			},
+			fetched:  [2]int{reddit.DefaultPageSize, 10},
+			requests: 2,
+		},
+		{
+			name: "failed combined listing fetches every subreddit on its own",
+			listings: map[string]map[string]int{
+				"/r/busy/top.json": {"busy": 5},
+			},
+			fetched:  [2]int{5, 0},
+			failed:   true,
+			requests: 3,
+		},
+		{
+			name: "failed fetch of a crowded out subreddit is reported",
+			listings: map[string]map[string]int{
+				"/r/busy+quiet/top.json": {"busy": 2 * reddit.DefaultPageSize},
+			},
+			fetched:  [2]int{reddit.DefaultPageSize, 0},
+			failed:   true,
+			requests: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newListingServer(t, tt.listings)
+			client := reddit.NewClient("hecate-test", reddit.WithBaseURL(server.URL))
+
+			results := ingestSubredditBatch(context.Background(), newTestStore(t), client,
+				[]string{"busy", "quiet"}, reddit.ListingOptions{Listing: reddit.ListingTop}, nil)
+
+			if len(results) != 2 {
+				t.Fatalf("got %d results, want 2", len(results))
+			}
+			for i, result := range results {
+				if result.Fetched != tt.fetched[i] || result.Inserted != tt.fetched[i] {
+					t.Errorf("%s fetched %d and inserted %d posts, want %d", result.Name, result.Fetched, result.Inserted, tt.fetched[i])
+				}
+			}
+			if results[0].Error != "" {
+				t.Errorf("busy failed: %s", results[0].Error)
+			}
+			if failed := results[1].Error != ""; failed != tt.failed {
+				t.Errorf("quiet failed is %t (%s), want %t", failed, results[1].Error, tt.failed)
+			}
+			if got := requests("/r/busy+quiet/top.json") + requests("/r/busy/top.json") + requests("/r/quiet/top.json"); got != tt.requests {
+				t.Errorf("got %d listing requests, want %d", got, tt.requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package hecate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/samratjha96/hecate/internal/database"
+	"github.com/samratjha96/hecate/internal/reddit"
+)
+
+// ErrJobFinished is returned when cancelling a job that is no longer queued or running
+var ErrJobFinished = errors.New("job already finished")
+
+// JobKindIngestAll is the kind of jobs ingesting every subscription
+const JobKindIngestAll = "ingest-all"
+
+// Job statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// JobRunner runs ingestion jobs in the background, one at a time in the order they
+// were enqueued. Job state is stored in the database so it survives the request.
+type JobRunner struct {
+	db     database.Store
+	client *reddit.Client
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	cancels map[int64]context.CancelFunc
+	// last is closed once the most recently enqueued job is done, each job waits for the one before it
+	last chan struct{}
+}
+
+// NewJobRunner creates a job runner. Jobs left queued or running by a previous
+// process are marked as failed, as nothing will finish them.
+func NewJobRunner(db database.Store, client *reddit.Client) (*JobRunner, error) {
+	interrupted, err := db.FailUnfinishedJobs(JobFailed, "interrupted by a server restart", JobQueued, JobRunning)
+	if err != nil {
+		return nil, err
+	}
+	if interrupted > 0 {
+		log.Printf("Marked %d interrupted jobs as failed", interrupted)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	last := make(chan struct{})
+	close(last)
+	return &JobRunner{
+		db:      db,
+		client:  client,
+		ctx:     ctx,
+		cancel:  cancel,
+		cancels: map[int64]context.CancelFunc{},
+		last:    last,
+	}, nil
+}
+
+// EnqueueIngestAll stores an ingest-all job and starts it once earlier jobs are done
+func (r *JobRunner) EnqueueIngestAll(request IngestAllFrontendRequest) (JobFrontendResponse, error) {
+	requestJson, err := json.Marshal(request)
+	if err != nil {
+		return JobFrontendResponse{}, fmt.Errorf("failed to marshal job request: %w", err)
+	}
+
+	id, err := r.db.CreateJob(JobKindIngestAll, JobQueued, string(requestJson))
+	if err != nil {
+		return JobFrontendResponse{}, err
+	}
+	log.Printf("Enqueued %s job %d", JobKindIngestAll, id)
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	done := make(chan struct{})
+	r.mu.Lock()
+	r.cancels[id] = cancel
+	previous := r.last
+	r.last = done
+	r.mu.Unlock()
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer close(done)
+		defer r.forget(id)
+
+		select {
+		case <-previous:
+		case <-ctx.Done():
+			r.finish(id, nil, ctx.Err())
+			// Jobs enqueued later still wait for the job ahead of this one
+			<-previous
+			return
+		}
+		r.runIngestAll(ctx, id, request.Options())
+	}()
+
+	return r.Job(id)
+}
+
+// runIngestAll ingests every subscription while recording progress
+func (r *JobRunner) runIngestAll(ctx context.Context, id int64, opts IngestAllOptions) {
+	if err := r.db.StartJob(id, JobRunning, time.Now()); err != nil {
+		log.Printf("Error starting job %d: %v", id, err)
+	}
+	log.Printf("Running %s job %d", JobKindIngestAll, id)
+
+	opts.Progress = func(completed, total int) {
+		if err := r.db.UpdateJobProgress(id, completed, total); err != nil {
+			log.Printf("Error updating progress of job %d: %v", id, err)
+		}
+	}
+	report, err := IngestAllSubreddit(ctx, r.db, r.client, opts)
+	r.finish(id, &report, err)
+}
+
+// finish records the outcome of a job
+func (r *JobRunner) finish(id int64, report *IngestReport, err error) {
+	status, jobError := JobSucceeded, ""
+	switch {
+	case errors.Is(err, context.Canceled):
+		status, jobError = JobCancelled, "cancelled"
+		if r.ctx.Err() != nil {
+			jobError = "cancelled by server shutdown"
+		}
+	case err != nil:
+		status, jobError = JobFailed, err.Error()
+	}
+
+	result := ""
+	if report != nil {
+		data, marshalErr := json.Marshal(report)
+		if marshalErr != nil {
+			log.Printf("Error marshalling result of job %d: %v", id, marshalErr)
+		} else {
+			result = string(data)
+		}
+	}
+
+	if err := r.db.FinishJob(id, status, result, jobError, time.Now()); err != nil {
+		log.Printf("Error finishing job %d: %v", id, err)
+	}
+	log.Printf("Job %d %s", id, status)
+}
+
+// forget drops the cancel function of a job that is done
+func (r *JobRunner) forget(id int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cancel, ok := r.cancels[id]; ok {
+		cancel()
+		delete(r.cancels, id)
+	}
+}
+
+// Cancel cancels a queued or running job through its context. The job reports
+// itself as cancelled once it has stopped.
+func (r *JobRunner) Cancel(id int64) (JobFrontendResponse, error) {
+	r.mu.Lock()
+	cancel, ok := r.cancels[id]
+	r.mu.Unlock()
+
+	if !ok {
+		job, err := r.Job(id)
+		if err != nil {
+			return job, err
+		}
+		return job, fmt.Errorf("%w: job %d is %s", ErrJobFinished, id, job.Status)
+	}
+
+	log.Printf("Cancelling job %d", id)
+	cancel()
+	return r.Job(id)
+}
+
+// Stop cancels every job and waits for them to record their state or for ctx to expire
+func (r *JobRunner) Stop(ctx context.Context) error {
+	log.Println("Stopping jobs...")
+	r.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Println("Jobs stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("jobs did not stop in time: %w", ctx.Err())
+	}
+}
+
+// Job retrieves a job by id
+func (r *JobRunner) Job(id int64) (JobFrontendResponse, error) {
+	dao, err := r.db.GetJob(id)
+	if err != nil {
+		return JobFrontendResponse{}, err
+	}
+	return convertToJobResponse(dao), nil
+}
+
+// Jobs retrieves the most recent jobs, newest first
+func (r *JobRunner) Jobs() ([]JobFrontendResponse, error) {
+	daos, err := r.db.GetRecentJobs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch jobs: %w", err)
+	}
+
+	responses := make([]JobFrontendResponse, len(daos))
+	for i, dao := range daos {
+		responses[i] = convertToJobResponse(dao)
+	}
+	return responses, nil
+}
+
+// convertToJobResponse converts a job to its frontend representation
+func convertToJobResponse(dao database.JobDao) JobFrontendResponse {
+	response := JobFrontendResponse{
+		Id:     dao.Id,
+		Kind:   dao.Kind,
+		Status: dao.Status,
+		Progress: JobProgress{
+			Completed: dao.Completed,
+			Total:     dao.Total,
+		},
+		Request:    json.RawMessage(dao.Request),
+		Error:      dao.Error,
+		CreatedAt:  dao.CreatedAt,
+		StartedAt:  dao.StartedAt,
+		FinishedAt: dao.FinishedAt,
+	}
+	if dao.Result != "" {
+		response.Result = json.RawMessage(dao.Result)
+	}
+	return response
+}
@@ -0,0 +1,515 @@
+package hecate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samratjha96/hecate/internal/database"
+	"github.com/samratjha96/hecate/internal/reddit"
+)
+
+// ErrScheduleRunning is returned when a schedule is triggered while it is already running
+var ErrScheduleRunning = errors.New("schedule is already running")
+
+const (
+	// scheduleIntervalSearch keys the default interval of saved searches in ScheduleIntervals
+	scheduleIntervalSearch = "search"
+	// schedulerTick is how often the scheduler looks for due schedules
+	schedulerTick = 30 * time.Second
+	// scheduleJitter is the fraction of an interval a run is moved by at random
+	scheduleJitter = 0.1
+	// scheduleSpread is the window new schedules are spread over so they do not all run at once
+	scheduleSpread = 5 * time.Minute
+	// minScheduleInterval is the shortest interval a schedule may be configured with
+	minScheduleInterval = time.Minute
+)
+
+// ScheduleIntervals are the default refresh intervals keyed by listing type, plus
+// "search" for saved searches
+type ScheduleIntervals map[string]time.Duration
+
+// DefaultScheduleIntervals refresh fast-moving listings more often than top and
+// controversial ones
+var DefaultScheduleIntervals = ScheduleIntervals{
+	string(reddit.ListingHot):           time.Hour,
+	string(reddit.ListingNew):           30 * time.Minute,
+	string(reddit.ListingRising):        30 * time.Minute,
+	string(reddit.ListingTop):           6 * time.Hour,
+	string(reddit.ListingControversial): 12 * time.Hour,
+	scheduleIntervalSearch:              6 * time.Hour,
+}
+
+// ScheduleIntervalsFromEnv overrides the default intervals with SCHEDULE_INTERVALS,
+// a comma-separated list such as "hot=30m,top=12h,search=2h"
+func ScheduleIntervalsFromEnv() ScheduleIntervals {
+	intervals := ScheduleIntervals{}
+	for key, interval := range DefaultScheduleIntervals {
+		intervals[key] = interval
+	}
+
+	for _, entry := range strings.Split(os.Getenv("SCHEDULE_INTERVALS"), ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(entry, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		interval, err := time.ParseDuration(strings.TrimSpace(value))
+		if _, known := intervals[key]; !known || err != nil || interval < minScheduleInterval {
+			log.Printf("Ignoring invalid SCHEDULE_INTERVALS entry: %s", entry)
+			continue
+		}
+		intervals[key] = interval
+	}
+	return intervals
+}
+
+// Scheduler refreshes every subscription on its own interval. Schedules are stored in
+// the database, so runs missed while the server was down are caught up after a restart.
+type Scheduler struct {
+	db          database.Store
+	client      *reddit.Client
+	intervals   ScheduleIntervals
+	concurrency int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wake   chan struct{}
+	slots  chan struct{}
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	running map[int64]bool
+}
+
+// NewScheduler creates a scheduler running at most concurrency schedules at once
+func NewScheduler(db database.Store, client *reddit.Client, intervals ScheduleIntervals, concurrency int) *Scheduler {
+	concurrency = resolveIngestConcurrency(concurrency)
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		db:          db,
+		client:      client,
+		intervals:   intervals,
+		concurrency: concurrency,
+		ctx:         ctx,
+		cancel:      cancel,
+		wake:        make(chan struct{}, 1),
+		slots:       make(chan struct{}, concurrency),
+		running:     map[int64]bool{},
+	}
+}
+
+// SchedulerEnabled reports whether the scheduler should run, which it does unless
+// SCHEDULER_ENABLED is set to false
+func SchedulerEnabled() bool {
+	enabled, err := strconv.ParseBool(os.Getenv("SCHEDULER_ENABLED"))
+	return err != nil || enabled
+}
+
+// Start runs the scheduler loop in the background until Stop is called
+func (s *Scheduler) Start() {
+	log.Printf("Starting scheduler with %d workers", s.concurrency)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.loop()
+	}()
+}
+
+// Stop cancels running schedules and waits for them to finish or for ctx to expire
+func (s *Scheduler) Stop(ctx context.Context) error {
+	log.Println("Stopping scheduler...")
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Println("Scheduler stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("scheduler did not stop in time: %w", ctx.Err())
+	}
+}
+
+// loop runs due schedules on every tick or when woken by a trigger
+func (s *Scheduler) loop() {
+	ticker := time.NewTicker(schedulerTick)
+	defer ticker.Stop()
+
+	for {
+		if err := s.syncSchedules(); err != nil {
+			log.Printf("Error syncing schedules: %v", err)
+		}
+		s.runDue()
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// syncSchedules creates a schedule for every subscription that does not have one yet.
+// Subreddits are refreshed with the listing they were subscribed with, the top listing of
+// the day when it was not recorded.
+func (s *Scheduler) syncSchedules() error {
+	subreddits, err := s.db.GetAllSubreddits()
+	if err != nil {
+		return fmt.Errorf("failed to fetch subreddits: %w", err)
+	}
+	for _, subreddit := range subreddits {
+		listing, timeWindow := subredditScheduleListing(subreddit.Subscription)
+		if _, err := s.db.EnsureSchedule(KindSubreddit, subreddit.Name, listing, timeWindow, s.firstRunAt()); err != nil {
+			return err
+		}
+	}
+
+	savedSearches, err := s.db.GetAllSavedSearches()
+	if err != nil {
+		return fmt.Errorf("failed to fetch saved searches: %w", err)
+	}
+	for _, search := range savedSearches {
+		if _, err := s.db.EnsureSchedule(KindSearch, strconv.FormatInt(search.Id, 10), "", "", s.firstRunAt()); err != nil {
+			return err
+		}
+	}
+
+	followedUsers, err := s.db.GetAllFollowedUsers()
+	if err != nil {
+		return fmt.Errorf("failed to fetch followed users: %w", err)
+	}
+	for _, user := range followedUsers {
+		if _, err := s.db.EnsureSchedule(KindUser, user.Name, "", "", s.firstRunAt()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subredditScheduleListing returns the listing and time window a subreddit's schedule
+// refreshes, falling back to the top listing of the day
+func subredditScheduleListing(subscription database.SubredditSubscriptionDao) (string, string) {
+	listing, timeWindow := subscription.Listing, subscription.TimeWindow
+	if listing == "" {
+		listing = string(reddit.ListingTop)
+	}
+	if timeWindow == "" {
+		timeWindow = string(reddit.TimeWindowDay)
+	}
+	return listing, timeWindow
+}
+
+// runDue starts every due schedule that is not already running. Overdue schedules,
+// such as those missed during a restart, run once rather than once per missed interval.
+func (s *Scheduler) runDue() {
+	schedules, err := s.db.GetDueSchedules(time.Now())
+	if err != nil {
+		log.Printf("Error fetching due schedules: %v", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		// Schedules still running from an earlier tick are skipped
+		_ = s.start(schedule)
+	}
+}
+
+// start runs a schedule in the background once a worker is free
+func (s *Scheduler) start(schedule database.ScheduleDao) error {
+	s.mu.Lock()
+	if s.running[schedule.Id] {
+		s.mu.Unlock()
+		return ErrScheduleRunning
+	}
+	s.running[schedule.Id] = true
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.finish(schedule.Id)
+
+		select {
+		case s.slots <- struct{}{}:
+		case <-s.ctx.Done():
+			return
+		}
+		defer func() { <-s.slots }()
+		s.run(schedule)
+	}()
+	return nil
+}
+
+// finish marks a schedule as no longer running
+func (s *Scheduler) finish(id int64) {
+	s.mu.Lock()
+	delete(s.running, id)
+	s.mu.Unlock()
+}
+
+// run ingests the subscription of a schedule and records when it runs next
+func (s *Scheduler) run(schedule database.ScheduleDao) {
+	ranAt := time.Now()
+	log.Printf("Running %s schedule %d for %s", schedule.Kind, schedule.Id, schedule.Name)
+
+	lastError := ""
+	if err := s.ingest(schedule); err != nil {
+		if s.ctx.Err() != nil {
+			// Cancelled by shutdown, leave the schedule due so it is caught up after a restart
+			return
+		}
+		log.Printf("Error running %s schedule %d for %s: %v", schedule.Kind, schedule.Id, schedule.Name, err)
+		lastError = err.Error()
+	}
+
+	interval, err := s.interval(schedule)
+	if err != nil {
+		log.Printf("Error resolving interval of schedule %d: %v", schedule.Id, err)
+		interval = DefaultScheduleIntervals[string(reddit.ListingTop)]
+	}
+	if err := s.db.RecordScheduleRun(schedule.Id, ranAt, ranAt.Add(jitter(interval)), lastError); err != nil {
+		log.Printf("Error recording run of schedule %d: %v", schedule.Id, err)
+	}
+}
+
+// ingest fetches and stores the posts of the subscription a schedule refreshes
+func (s *Scheduler) ingest(schedule database.ScheduleDao) error {
+	switch schedule.Kind {
+	case KindSubreddit:
+		stored, err := s.db.GetSubredditSubscription(schedule.Name)
+		if err != nil {
+			return err
+		}
+		// The schedule's listing is refreshed with the rest of what the subreddit was subscribed with
+		subscription := subredditSubscription(schedule.Name, stored)
+		if schedule.Listing != "" {
+			subscription.Listing = reddit.Listing(schedule.Listing)
+			subscription.TimeWindow = reddit.TimeWindow(schedule.TimeWindow)
+		}
+		_, err = ingestSubreddit(s.ctx, s.db, s.client, subscription)
+		return err
+	case KindSearch:
+		searchId, err := strconv.ParseInt(schedule.Name, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid saved search id %q: %w", schedule.Name, err)
+		}
+		search, err := s.db.GetSavedSearch(searchId)
+		if err != nil {
+			return err
+		}
+		_, _, err = ingestSearch(s.ctx, s.db, s.client, savedSearchSubscription(search))
+		return err
+	case KindUser:
+		user, err := s.db.GetFollowedUser(schedule.Name)
+		if err != nil {
+			return err
+		}
+		subscription := followedUserSubscription(user)
+		if schedule.Listing != "" {
+			subscription.Listing = reddit.Listing(schedule.Listing)
+			subscription.TimeWindow = reddit.TimeWindow(schedule.TimeWindow)
+		}
+		_, _, err = ingestUser(s.ctx, s.db, s.client, subscription)
+		return err
+	default:
+		return fmt.Errorf("unknown schedule kind %q", schedule.Kind)
+	}
+}
+
+// interval resolves the interval of a schedule: its own, or the default of its listing type
+func (s *Scheduler) interval(schedule database.ScheduleDao) (time.Duration, error) {
+	if schedule.Interval > 0 {
+		return schedule.Interval, nil
+	}
+
+	key := schedule.Listing
+	switch {
+	case schedule.Kind == KindSearch:
+		key = scheduleIntervalSearch
+	case schedule.Kind == KindUser && key == "":
+		user, err := s.db.GetFollowedUser(schedule.Name)
+		if err != nil {
+			return 0, err
+		}
+		key = user.Listing
+	}
+	if interval, ok := s.intervals[key]; ok {
+		return interval, nil
+	}
+	return 0, fmt.Errorf("no default interval for listing %q", key)
+}
+
+// firstRunAt spreads the first run of new schedules over a short window
+func (s *Scheduler) firstRunAt() time.Time {
+	return time.Now().Add(rand.N(scheduleSpread))
+}
+
+// jitter moves an interval by up to scheduleJitter in either direction
+func jitter(interval time.Duration) time.Duration {
+	spread := time.Duration(float64(interval) * scheduleJitter)
+	if spread <= 0 {
+		return interval
+	}
+	return interval - spread + rand.N(2*spread+1)
+}
+
+// Schedules lists every schedule with its resolved interval
+func (s *Scheduler) Schedules() ([]ScheduleFrontendResponse, error) {
+	if err := s.syncSchedules(); err != nil {
+		return nil, err
+	}
+	daos, err := s.db.GetAllSchedules()
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]ScheduleFrontendResponse, len(daos))
+	for i, dao := range daos {
+		responses[i] = s.convertToScheduleResponse(dao)
+	}
+	return responses, nil
+}
+
+// Configure creates or updates the schedule of a subscription listing
+func (s *Scheduler) Configure(request ScheduleFrontendRequest) (ScheduleFrontendResponse, error) {
+	kind, name, err := s.scheduleSubscription(request)
+	if err != nil {
+		return ScheduleFrontendResponse{}, err
+	}
+
+	var interval time.Duration
+	if request.Interval != "" {
+		interval, err = time.ParseDuration(request.Interval)
+		if err != nil || interval < minScheduleInterval {
+			return ScheduleFrontendResponse{}, fmt.Errorf("%w: interval must be a duration of at least %s", ErrInvalidRequest, minScheduleInterval)
+		}
+	}
+	if kind == KindSearch && request.Listing != "" {
+		return ScheduleFrontendResponse{}, fmt.Errorf("%w: saved search schedules have no listing", ErrInvalidRequest)
+	}
+	if request.Listing == reddit.ListingRising && kind == KindUser {
+		return ScheduleFrontendResponse{}, fmt.Errorf("%w: listing %s is not available for users", ErrInvalidRequest, request.Listing)
+	}
+
+	listing, timeWindow := request.Listing, request.TimeWindow
+	if kind == KindSubreddit && listing == "" {
+		listing = reddit.ListingTop
+	}
+	if listing != "" && timeWindow == "" {
+		timeWindow = reddit.TimeWindowDay
+	}
+
+	id, err := s.db.UpsertSchedule(kind, name, string(listing), string(timeWindow), interval, s.firstRunAt())
+	if err != nil {
+		return ScheduleFrontendResponse{}, err
+	}
+	dao, err := s.db.GetSchedule(id)
+	if err != nil {
+		return ScheduleFrontendResponse{}, err
+	}
+	s.notify()
+	return s.convertToScheduleResponse(dao), nil
+}
+
+// scheduleSubscription resolves the subscription a schedule request refers to
+func (s *Scheduler) scheduleSubscription(request ScheduleFrontendRequest) (string, string, error) {
+	switch request.Kind {
+	case KindSubreddit:
+		name, err := reddit.NormalizeSubredditName(request.Name)
+		if err != nil {
+			return "", "", err
+		}
+		name, err = s.db.GetSubredditName(name)
+		if err != nil {
+			return "", "", err
+		}
+		return KindSubreddit, name, nil
+	case KindSearch:
+		searchId, err := strconv.ParseInt(request.Name, 10, 64)
+		if err != nil {
+			return "", "", fmt.Errorf("%w: saved search id must be a number", ErrInvalidRequest)
+		}
+		if _, err := s.db.GetSavedSearch(searchId); err != nil {
+			return "", "", err
+		}
+		return KindSearch, request.Name, nil
+	case KindUser:
+		user, err := s.db.GetFollowedUser(request.Name)
+		if err != nil {
+			return "", "", err
+		}
+		return KindUser, user.Name, nil
+	default:
+		return "", "", fmt.Errorf("%w: kind must be one of %s, %s, %s", ErrInvalidRequest, KindSubreddit, KindSearch, KindUser)
+	}
+}
+
+// SetPaused pauses or resumes a schedule. A resumed schedule that is overdue runs on the next tick.
+func (s *Scheduler) SetPaused(id int64, paused bool) (ScheduleFrontendResponse, error) {
+	if err := s.db.SetSchedulePaused(id, paused); err != nil {
+		return ScheduleFrontendResponse{}, err
+	}
+	dao, err := s.db.GetSchedule(id)
+	if err != nil {
+		return ScheduleFrontendResponse{}, err
+	}
+	if !paused {
+		s.notify()
+	}
+	return s.convertToScheduleResponse(dao), nil
+}
+
+// Trigger runs a schedule now, whether or not it is paused, without waiting for it to finish
+func (s *Scheduler) Trigger(id int64) (ScheduleFrontendResponse, error) {
+	dao, err := s.db.GetSchedule(id)
+	if err != nil {
+		return ScheduleFrontendResponse{}, err
+	}
+	if err := s.start(dao); err != nil {
+		return ScheduleFrontendResponse{}, err
+	}
+	return s.convertToScheduleResponse(dao), nil
+}
+
+// notify wakes the scheduler loop without blocking
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// convertToScheduleResponse converts a schedule to its frontend representation
+func (s *Scheduler) convertToScheduleResponse(dao database.ScheduleDao) ScheduleFrontendResponse {
+	response := ScheduleFrontendResponse{
+		Id:         dao.Id,
+		Kind:       dao.Kind,
+		Name:       dao.Name,
+		Listing:    reddit.Listing(dao.Listing),
+		TimeWindow: reddit.TimeWindow(dao.TimeWindow),
+		Paused:     dao.Paused,
+		NextRunAt:  dao.NextRunAt,
+		LastRunAt:  dao.LastRunAt,
+		LastError:  dao.LastError,
+	}
+	if interval, err := s.interval(dao); err == nil {
+		response.Interval = interval.String()
+	}
+	s.mu.Lock()
+	response.Running = s.running[dao.Id]
+	s.mu.Unlock()
+	return response
+}
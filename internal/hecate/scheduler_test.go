@@ -0,0 +1,128 @@
+package hecate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samratjha96/hecate/internal/database"
+	"github.com/samratjha96/hecate/internal/reddit"
+)
+
+func TestSyncSchedulesUsesSubscribedListing(t *testing.T) {
+	db := newTestStore(t)
+	client := newReplayClient()
+	subscription := RedditSubscription{Name: "golang", Listing: reddit.ListingTop, TimeWindow: reddit.TimeWindowWeek, Limit: 2, MaxPosts: 4}
+	if _, err := IngestSubreddit(context.Background(), db, client, subscription); err != nil {
+		t.Fatalf("IngestSubreddit: %v", err)
+	}
+	// Subreddits subscribed before their listing was recorded have none
+	if _, err := db.UpsertSubreddit("legacy", reddit.SubredditAbout{}); err != nil {
+		t.Fatalf("UpsertSubreddit: %v", err)
+	}
+
+	scheduler := NewScheduler(db, client, DefaultScheduleIntervals, 1)
+	if err := scheduler.syncSchedules(); err != nil {
+		t.Fatalf("syncSchedules: %v", err)
+	}
+	schedules, err := db.GetAllSchedules()
+	if err != nil {
+		t.Fatalf("GetAllSchedules: %v", err)
+	}
+
+	want := map[string][2]string{
+		"golang": {"top", "week"},
+		"legacy": {"top", "day"},
+	}
+	if len(schedules) != len(want) {
+		t.Fatalf("got %d schedules, want %d", len(schedules), len(want))
+	}
+	for _, schedule := range schedules {
+		if got := [2]string{schedule.Listing, schedule.TimeWindow}; got != want[schedule.Name] {
+			t.Errorf("schedule of %s refreshes %v, want %v", schedule.Name, got, want[schedule.Name])
+		}
+	}
+}
+
+func TestScheduledSubredditRunUsesSubscription(t *testing.T) {
+	db := newTestStore(t)
+	if _, err := db.UpsertSubreddit("golang", reddit.SubredditAbout{}); err != nil {
+		t.Fatalf("UpsertSubreddit: %v", err)
+	}
+	subscription := RedditSubscription{Name: "golang", Listing: reddit.ListingTop, TimeWindow: reddit.TimeWindowWeek,
+		Limit: 2, MaxPosts: 4, Comments: &CommentSettings{Depth: 3}}
+	if err := db.SetSubredditSubscription("golang", subredditSubscriptionDao(subscription)); err != nil {
+		t.Fatalf("SetSubredditSubscription: %v", err)
+	}
+
+	// The replayer only serves the recorded requests, so the run must make the same ones
+	scheduler := NewScheduler(db, newReplayClient(), DefaultScheduleIntervals, 1)
+	if err := scheduler.syncSchedules(); err != nil {
+		t.Fatalf("syncSchedules: %v", err)
+	}
+	schedules, err := db.GetAllSchedules()
+	if err != nil {
+		t.Fatalf("GetAllSchedules: %v", err)
+	}
+	if len(schedules) != 1 {
+		t.Fatalf("got %d schedules, want 1", len(schedules))
+	}
+	if err := scheduler.ingest(schedules[0]); err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+
+	posts, err := db.GetSubredditPosts("golang", database.PostQuery{}, database.Paginate{Limit: 10})
+	if err != nil {
+		t.Fatalf("GetSubredditPosts: %v", err)
+	}
+	if len(posts.Items) != 4 {
+		t.Fatalf("stored %d posts, want the 4 the subscription asks for", len(posts.Items))
+	}
+	for _, post := range posts.Items {
+		comments, err := db.GetPostComments(post.PostId)
+		if err != nil {
+			t.Fatalf("GetPostComments(%s): %v", post.PostId, err)
+		}
+		if len(comments) == 0 {
+			t.Errorf("stored no comments for post %s", post.PostId)
+		}
+	}
+}
+
+func TestResubscribingMovesSchedule(t *testing.T) {
+	db := newTestStore(t)
+	if _, err := db.UpsertSubreddit("golang", reddit.SubredditAbout{}); err != nil {
+		t.Fatalf("UpsertSubreddit: %v", err)
+	}
+	scheduler := NewScheduler(db, newReplayClient(), DefaultScheduleIntervals, 1)
+
+	subscriptions := []struct {
+		subscription RedditSubscription
+		listing      [2]string
+	}{
+		{RedditSubscription{Listing: reddit.ListingTop, TimeWindow: reddit.TimeWindowWeek}, [2]string{"top", "week"}},
+		{RedditSubscription{Listing: reddit.ListingTop, TimeWindow: reddit.TimeWindowMonth}, [2]string{"top", "month"}},
+		{RedditSubscription{Listing: reddit.ListingNew}, [2]string{"new", "day"}},
+	}
+	var id int64
+	for _, tt := range subscriptions {
+		if err := recordSubredditSubscription(db, "golang", tt.subscription); err != nil {
+			t.Fatalf("recordSubredditSubscription: %v", err)
+		}
+		if err := scheduler.syncSchedules(); err != nil {
+			t.Fatalf("syncSchedules: %v", err)
+		}
+		schedules, err := db.GetAllSchedules()
+		if err != nil {
+			t.Fatalf("GetAllSchedules: %v", err)
+		}
+		if len(schedules) != 1 {
+			t.Fatalf("got %d schedules after subscribing to %v, want 1", len(schedules), tt.listing)
+		}
+		if id == 0 {
+			id = schedules[0].Id
+		}
+		if got := [2]string{schedules[0].Listing, schedules[0].TimeWindow}; got != tt.listing || schedules[0].Id != id {
+			t.Errorf("schedule %d refreshes %v, want schedule %d to refresh %v", schedules[0].Id, got, id, tt.listing)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package hecate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/samratjha96/hecate/internal/database"
+	"github.com/samratjha96/hecate/internal/reddit"
+)
+
+// IngestSearch saves a search query and ingests its results. The posts are stored
+// under their real subreddit and linked to the saved search, so a post matching
+// several subscriptions is listed by each of them.
+func IngestSearch(ctx context.Context, db database.Store, client *reddit.Client, search SearchSubscription) (SavedSearchFrontendResponse, error) {
+	response, _, err := ingestSearch(ctx, db, client, search)
+	return response, err
+}
+
+// ingestSearch ingests a saved search, also reporting how its posts were stored
+func ingestSearch(ctx context.Context, db database.Store, client *reddit.Client, search SearchSubscription) (SavedSearchFrontendResponse, IngestCounts, error) {
+	search, err := normalizeSearch(search)
+	if err != nil {
+		return SavedSearchFrontendResponse{}, IngestCounts{}, err
+	}
+
+	log.Printf("Searching Reddit for %q (Subreddit: %q, Sort: %s, Time window: %s)", search.Query, search.Subreddit, search.Sort, search.TimeWindow)
+	posts, err := client.Search(ctx, search.Query, reddit.SearchOptions{
+		Subreddit:  search.Subreddit,
+		Sort:       search.Sort,
+		TimeWindow: search.TimeWindow,
+		Limit:      search.Limit,
+		MaxPosts:   search.MaxPosts,
+	})
+	if err != nil {
+		return SavedSearchFrontendResponse{}, IngestCounts{}, fmt.Errorf("failed to search posts: %w", err)
+	}
+
+	searchId, err := db.UpsertSavedSearch(search.Query, search.Subreddit, string(search.Sort), string(search.TimeWindow))
+	if err != nil {
+		return SavedSearchFrontendResponse{}, IngestCounts{Fetched: len(posts)}, err
+	}
+
+	counts, err := upsertPosts(ctx, db, posts, "", database.SourceTypeSearch, strconv.FormatInt(searchId, 10))
+	if err != nil {
+		return SavedSearchFrontendResponse{}, counts, err
+	}
+
+	return SavedSearchFrontendResponse{
+		Id:            searchId,
+		Query:         search.Query,
+		Subreddit:     search.Subreddit,
+		Sort:          search.Sort,
+		TimeWindow:    search.TimeWindow,
+		NumberOfPosts: len(posts),
+	}, counts, nil
+}
+
+// normalizeSearch validates a search subscription and fills in its defaults
+func normalizeSearch(search SearchSubscription) (SearchSubscription, error) {
+	search.Query = strings.TrimSpace(search.Query)
+	if search.Query == "" {
+		return search, fmt.Errorf("%w: search query cannot be empty", ErrInvalidRequest)
+	}
+	if search.Subreddit != "" {
+		subreddit, err := reddit.NormalizeSubredditName(search.Subreddit)
+		if err != nil {
+			return search, err
+		}
+		search.Subreddit = subreddit
+	}
+	if search.Sort == "" {
+		search.Sort = reddit.SearchSortRelevance
+	}
+	if search.TimeWindow == "" {
+		search.TimeWindow = reddit.TimeWindowAll
+	}
+	return search, nil
+}
+
+// savedSearchSubscription converts a stored saved search back to a subscription
+func savedSearchSubscription(dao database.SavedSearchDao) SearchSubscription {
+	return SearchSubscription{
+		Query:      dao.Query,
+		Subreddit:  dao.Subreddit,
+		Sort:       reddit.SearchSort(dao.Sort),
+		TimeWindow: reddit.TimeWindow(dao.TimeWindow),
+	}
+}
+
+// GetSavedSearches retrieves all saved search queries
+func GetSavedSearches(db database.Store) ([]SavedSearchFrontendResponse, error) {
+	daos, err := db.GetAllSavedSearches()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch saved searches: %w", err)
+	}
+
+	responses := make([]SavedSearchFrontendResponse, len(daos))
+	for i, dao := range daos {
+		responses[i] = SavedSearchFrontendResponse{
+			Id:         dao.Id,
+			Query:      dao.Query,
+			Subreddit:  dao.Subreddit,
+			Sort:       reddit.SearchSort(dao.Sort),
+			TimeWindow: reddit.TimeWindow(dao.TimeWindow),
+		}
+	}
+	return responses, nil
+}
+
+// GetPostsForSavedSearch retrieves a page of the posts ingested through a saved search
+func GetPostsForSavedSearch(db database.Store, searchId int64, pagination database.Paginate) (PostsResponse, error) {
+	if _, err := db.GetSavedSearch(searchId); err != nil {
+		return PostsResponse{}, err
+	}
+
+	page, err := db.GetSourcePosts(database.SourceTypeSearch, strconv.FormatInt(searchId, 10), pagination)
+	if err != nil {
+		return PostsResponse{}, fmt.Errorf("failed to get posts for saved search %d: %w", searchId, err)
+	}
+
+	log.Printf("Retrieved %d posts for saved search: %d", len(page.Items), searchId)
+	return convertToPostsResponse(page), nil
+}
@@ -1,22 +1,81 @@
 package hecate
 
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/samratjha96/hecate/internal/reddit"
+)
+
 type RedditSubscription struct {
-	Name   string `json:"name"`
-	SortBy string `json:"sortBy"`
+	Name       string            `json:"name"`
+	Listing    reddit.Listing    `json:"listing"`
+	TimeWindow reddit.TimeWindow `json:"timeWindow"`
+	// SortBy is the legacy name of TimeWindow, used when TimeWindow is not set
+	SortBy reddit.TimeWindow `json:"sortBy,omitempty"`
+	// Limit is the number of posts requested per page, up to 100
+	Limit int `json:"limit,omitempty"`
+	// MaxPosts is the total number of posts to ingest, following pages as needed
+	MaxPosts int `json:"maxPosts,omitempty"`
+	// Comments enables ingesting the comment trees of the fetched posts
+	Comments *CommentSettings `json:"comments,omitempty"`
+}
+
+// CommentSettings controls how much of each post's comment tree is ingested
+type CommentSettings struct {
+	// Depth is the maximum number of reply levels
+	Depth int `json:"depth,omitempty"`
+	// Limit is the number of top comments requested per post
+	Limit int `json:"limit,omitempty"`
 }
 
+// Subscription kinds listed by GET /api/subreddits
+const (
+	KindSubreddit = "subreddit"
+	KindSearch    = "search"
+	KindUser      = "user"
+)
+
 type SubredditFrontendResponse struct {
-	Name                string `json:"name"`
-	NumberOfSubscribers int    `json:"numberOfSubscribers"`
+	// Kind is the subscription kind, a subreddit, a saved search or a followed user
+	Kind string `json:"kind"`
+	// Name is the subreddit name, the query of a saved search or the name of a followed user
+	Name string `json:"name"`
+	// SearchId identifies a saved search
+	SearchId int64 `json:"searchId,omitempty"`
+	// Subreddit is the subreddit a saved search is restricted to
+	Subreddit           string    `json:"subreddit,omitempty"`
+	NumberOfSubscribers int       `json:"numberOfSubscribers"`
+	Title               string    `json:"title"`
+	PublicDescription   string    `json:"publicDescription"`
+	CreatedAt           time.Time `json:"createdAt"`
+	Nsfw                bool      `json:"nsfw"`
+	ActiveUsers         int       `json:"activeUsers"`
+	IconUrl             string    `json:"iconUrl"`
 }
 
 type SubredditPostFrontendResponse struct {
+	PostId        string `json:"postId"`
+	Fullname      string `json:"fullname"`
 	Title         string `json:"title"`
 	Content       string `json:"content"`
+	Author        string `json:"author"`
 	DiscussionURL string `json:"discussionUrl"`
-	CommentCount  int    `json:"commentCount"`
-	Upvotes       int    `json:"upvotes"`
-	SubredditName string `json:"subredditName,omitempty"`
+	// Url is the link target, the external article for link posts
+	Url              string                   `json:"url"`
+	Domain           string                   `json:"domain"`
+	IsSelf           bool                     `json:"isSelf"`
+	Thumbnail        string                   `json:"thumbnail,omitempty"`
+	Flair            string                   `json:"flair,omitempty"`
+	Stickied         bool                     `json:"stickied"`
+	Pinned           bool                     `json:"pinned"`
+	Nsfw             bool                     `json:"nsfw"`
+	Spoiler          bool                     `json:"spoiler"`
+	CommentCount     int                      `json:"commentCount"`
+	Upvotes          int                      `json:"upvotes"`
+	UpvoteRatio      float64                  `json:"upvoteRatio"`
+	SubredditName    string                   `json:"subredditName,omitempty"`
+	CrosspostParents []reddit.CrosspostParent `json:"crosspostParents,omitempty"`
 }
 
 type SubscribeFrontendRequest struct {
@@ -24,9 +83,243 @@ type SubscribeFrontendRequest struct {
 }
 
 type IngestAllFrontendRequest struct {
-	SortBy string `json:"sortBy"`
+	Listing    reddit.Listing    `json:"listing"`
+	TimeWindow reddit.TimeWindow `json:"timeWindow"`
+	// SortBy is the legacy name of TimeWindow, used when TimeWindow is not set
+	SortBy reddit.TimeWindow `json:"sortBy,omitempty"`
+	// Comments enables ingesting the comment trees of the fetched posts
+	Comments *CommentSettings `json:"comments,omitempty"`
+	// Concurrency is the number of subscriptions ingested in parallel
+	Concurrency int `json:"concurrency,omitempty"`
 }
 
-type SearchPostsResponse struct {
+// IngestCounts tallies the posts fetched for a subscription and how storing them went
+type IngestCounts struct {
+	Fetched  int `json:"fetched"`
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+	Failed   int `json:"failed"`
+}
+
+// IngestResult is the outcome of ingesting one subscription
+type IngestResult struct {
+	// Kind is the subscription kind, a subreddit, saved search or followed user
+	Kind string `json:"kind"`
+	// Name is the subreddit name, saved search id or username
+	Name string `json:"name"`
+	IngestCounts
+	Error string `json:"error,omitempty"`
+
+	err error
+}
+
+// IngestReport summarizes a run over every subscription
+type IngestReport struct {
+	Results             []IngestResult `json:"results"`
+	Totals              IngestCounts   `json:"totals"`
+	FailedSubscriptions int            `json:"failedSubscriptions"`
+
+	firstErr error
+}
+
+// ScheduleFrontendRequest configures how often a subscription listing is refreshed
+type ScheduleFrontendRequest struct {
+	Kind string `json:"kind"`
+	// Name is the subreddit name, saved search id or username
+	Name       string            `json:"name"`
+	Listing    reddit.Listing    `json:"listing,omitempty"`
+	TimeWindow reddit.TimeWindow `json:"timeWindow,omitempty"`
+	// Interval is a duration such as "30m", the default of the listing type when empty
+	Interval string `json:"interval,omitempty"`
+}
+
+type ScheduleFrontendResponse struct {
+	Id         int64             `json:"id"`
+	Kind       string            `json:"kind"`
+	Name       string            `json:"name"`
+	Listing    reddit.Listing    `json:"listing,omitempty"`
+	TimeWindow reddit.TimeWindow `json:"timeWindow,omitempty"`
+	Interval   string            `json:"interval"`
+	Paused     bool              `json:"paused"`
+	Running    bool              `json:"running"`
+	NextRunAt  time.Time         `json:"nextRunAt"`
+	LastRunAt  *time.Time        `json:"lastRunAt,omitempty"`
+	LastError  string            `json:"lastError,omitempty"`
+}
+
+// JobProgress counts the subscriptions a job has finished out of its total
+type JobProgress struct {
+	Completed int `json:"completed"`
+	Total     int `json:"total"`
+}
+
+type JobFrontendResponse struct {
+	Id       int64       `json:"id"`
+	Kind     string      `json:"kind"`
+	Status   string      `json:"status"`
+	Progress JobProgress `json:"progress"`
+	// Request is the request the job was enqueued with
+	Request json.RawMessage `json:"request"`
+	// Result is the report of a finished job
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	StartedAt  *time.Time      `json:"startedAt,omitempty"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+}
+
+type CommentFrontendResponse struct {
+	Id        string                    `json:"id"`
+	Author    string                    `json:"author"`
+	Content   string                    `json:"content"`
+	Upvotes   int                       `json:"upvotes"`
+	CreatedAt time.Time                 `json:"createdAt"`
+	Replies   []CommentFrontendResponse `json:"replies"`
+}
+
+type PostCommentsResponse struct {
+	PostId   string                    `json:"postId"`
+	Comments []CommentFrontendResponse `json:"comments"`
+}
+
+// SearchSubscription is a saved Reddit search query whose results are ingested like a subreddit
+type SearchSubscription struct {
+	Query string `json:"query"`
+	// Subreddit restricts the search to a single subreddit when set
+	Subreddit  string            `json:"subreddit,omitempty"`
+	Sort       reddit.SearchSort `json:"sort"`
+	TimeWindow reddit.TimeWindow `json:"timeWindow"`
+	// Limit is the number of posts requested per page, up to 100
+	Limit int `json:"limit,omitempty"`
+	// MaxPosts is the total number of posts to ingest, following pages as needed
+	MaxPosts int `json:"maxPosts,omitempty"`
+}
+
+type SearchSubscribeFrontendRequest struct {
+	Search SearchSubscription `json:"search"`
+}
+
+type SavedSearchFrontendResponse struct {
+	Id            int64             `json:"id"`
+	Query         string            `json:"query"`
+	Subreddit     string            `json:"subreddit,omitempty"`
+	Sort          reddit.SearchSort `json:"sort"`
+	TimeWindow    reddit.TimeWindow `json:"timeWindow"`
+	NumberOfPosts int               `json:"numberOfPosts"`
+}
+
+// UserSubscription follows the posts a Reddit user submits
+type UserSubscription struct {
+	Name       string            `json:"name"`
+	Listing    reddit.Listing    `json:"listing"`
+	TimeWindow reddit.TimeWindow `json:"timeWindow"`
+	// Limit is the number of posts requested per page, up to 100
+	Limit int `json:"limit,omitempty"`
+	// MaxPosts is the total number of posts to ingest, following pages as needed
+	MaxPosts int `json:"maxPosts,omitempty"`
+}
+
+type UserSubscribeFrontendRequest struct {
+	User UserSubscription `json:"user"`
+}
+
+type FollowedUserFrontendResponse struct {
+	Name          string            `json:"name"`
+	Listing       reddit.Listing    `json:"listing"`
+	TimeWindow    reddit.TimeWindow `json:"timeWindow"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	NumberOfPosts int               `json:"numberOfPosts"`
+}
+
+// SubredditsResponse is a page of subscriptions
+type SubredditsResponse struct {
+	Subreddits []SubredditFrontendResponse `json:"subreddits"`
+	// Next is the cursor of the following page, omitted on the last page
+	Next string `json:"next,omitempty"`
+}
+
+// PostsResponse is a page of posts, newest first
+type PostsResponse struct {
 	Posts []SubredditPostFrontendResponse `json:"posts"`
+	// Next is the cursor of the following page, omitted on the last page
+	Next string `json:"next,omitempty"`
+}
+
+type SearchPostsResponse struct {
+	Posts []SearchPostFrontendResponse `json:"posts"`
+}
+
+type SearchPostFrontendResponse struct {
+	SubredditPostFrontendResponse
+	// Snippet is the best matching fragment of the post, HTML-escaped with matches in <mark> tags
+	Snippet string  `json:"snippet"`
+	Score   float64 `json:"score"`
+}
+
+// listingOptions resolves the listing of a subscription, falling back to the legacy sortBy field
+func (s RedditSubscription) listingOptions() reddit.ListingOptions {
+	timeWindow := s.TimeWindow
+	if timeWindow == "" {
+		timeWindow = s.SortBy
+	}
+	return reddit.ListingOptions{
+		Listing:    s.Listing,
+		TimeWindow: timeWindow,
+		Limit:      s.Limit,
+		MaxPosts:   s.MaxPosts,
+	}
+}
+
+// commentOptions converts comment settings to client options
+func (s *CommentSettings) commentOptions() reddit.CommentOptions {
+	return reddit.CommentOptions{
+		Depth: s.Depth,
+		Limit: s.Limit,
+	}
+}
+
+// ResolvedTimeWindow returns the requested time window, falling back to the legacy sortBy field
+func (r IngestAllFrontendRequest) ResolvedTimeWindow() reddit.TimeWindow {
+	if r.TimeWindow != "" {
+		return r.TimeWindow
+	}
+	return r.SortBy
+}
+
+// failed records the error that stopped a subscription from being ingested
+func (r IngestResult) failed(err error) IngestResult {
+	r.err = err
+	r.Error = err.Error()
+	return r
+}
+
+// newIngestReport totals the results of a run
+func newIngestReport(results []IngestResult) IngestReport {
+	report := IngestReport{Results: results}
+	if report.Results == nil {
+		report.Results = []IngestResult{}
+	}
+	for _, result := range results {
+		report.Totals.Fetched += result.Fetched
+		report.Totals.Inserted += result.Inserted
+		report.Totals.Updated += result.Updated
+		report.Totals.Failed += result.Failed
+		if result.err != nil {
+			report.FailedSubscriptions++
+			if report.firstErr == nil {
+				report.firstErr = result.err
+			}
+		}
+	}
+	return report
+}
+
+// Options converts the request to ingestion options, resolving the legacy sortBy field
+func (r IngestAllFrontendRequest) Options() IngestAllOptions {
+	return IngestAllOptions{
+		Listing:     r.Listing,
+		TimeWindow:  r.ResolvedTimeWindow(),
+		Comments:    r.Comments,
+		Concurrency: r.Concurrency,
+	}
 }
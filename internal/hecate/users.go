@@ -0,0 +1,131 @@
+package hecate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/samratjha96/hecate/internal/database"
+	"github.com/samratjha96/hecate/internal/reddit"
+)
+
+// IngestUser follows a Reddit user and ingests the posts they submitted. The posts
+// are stored under their real subreddit and linked to the user, so they are listed
+// by every subscription that matched them.
+func IngestUser(ctx context.Context, db database.Store, client *reddit.Client, user UserSubscription) (FollowedUserFrontendResponse, error) {
+	response, _, err := ingestUser(ctx, db, client, user)
+	return response, err
+}
+
+// ingestUser ingests a followed user, also reporting how their posts were stored
+func ingestUser(ctx context.Context, db database.Store, client *reddit.Client, user UserSubscription) (FollowedUserFrontendResponse, IngestCounts, error) {
+	user, err := normalizeUser(user)
+	if err != nil {
+		return FollowedUserFrontendResponse{}, IngestCounts{}, err
+	}
+
+	log.Printf("Fetching posts submitted by u/%s (Listing: %s, Time window: %s)", user.Name, user.Listing, user.TimeWindow)
+	posts, err := client.DescribeUser(ctx, user.Name, reddit.ListingOptions{
+		Listing:    user.Listing,
+		TimeWindow: user.TimeWindow,
+		Limit:      user.Limit,
+		MaxPosts:   user.MaxPosts,
+	})
+	if err != nil {
+		return FollowedUserFrontendResponse{}, IngestCounts{}, fmt.Errorf("failed to fetch user posts: %w", err)
+	}
+
+	// Submissions carry the author's canonical name, which may differ in case from the request
+	for _, post := range posts {
+		if strings.EqualFold(post.Author, user.Name) {
+			user.Name = post.Author
+			break
+		}
+	}
+
+	if _, err := db.UpsertFollowedUser(user.Name, string(user.Listing), string(user.TimeWindow)); err != nil {
+		return FollowedUserFrontendResponse{}, IngestCounts{Fetched: len(posts)}, err
+	}
+
+	counts, err := upsertPosts(ctx, db, posts, "", database.SourceTypeUser, user.Name)
+	if err != nil {
+		return FollowedUserFrontendResponse{}, counts, err
+	}
+
+	dao, err := db.GetFollowedUser(user.Name)
+	if err != nil {
+		return FollowedUserFrontendResponse{}, counts, err
+	}
+	response := convertToFollowedUserResponse(dao)
+	response.NumberOfPosts = len(posts)
+	return response, counts, nil
+}
+
+// normalizeUser validates a user subscription and fills in its defaults
+func normalizeUser(user UserSubscription) (UserSubscription, error) {
+	name, err := reddit.NormalizeUsername(user.Name)
+	if err != nil {
+		return user, err
+	}
+	user.Name = name
+	if user.Listing == "" {
+		user.Listing = reddit.ListingNew
+	}
+	if user.Listing == reddit.ListingRising {
+		return user, fmt.Errorf("%w: listing %s is not available for users", ErrInvalidRequest, user.Listing)
+	}
+	if user.TimeWindow == "" {
+		user.TimeWindow = reddit.TimeWindowAll
+	}
+	return user, nil
+}
+
+// followedUserSubscription converts a stored followed user back to a subscription
+func followedUserSubscription(dao database.FollowedUserDao) UserSubscription {
+	return UserSubscription{
+		Name:       dao.Name,
+		Listing:    reddit.Listing(dao.Listing),
+		TimeWindow: reddit.TimeWindow(dao.TimeWindow),
+	}
+}
+
+// convertToFollowedUserResponse converts a followed user to its frontend representation
+func convertToFollowedUserResponse(dao database.FollowedUserDao) FollowedUserFrontendResponse {
+	return FollowedUserFrontendResponse{
+		Name:       dao.Name,
+		Listing:    reddit.Listing(dao.Listing),
+		TimeWindow: reddit.TimeWindow(dao.TimeWindow),
+		CreatedAt:  dao.CreatedAt,
+	}
+}
+
+// GetFollowedUsers retrieves all followed Reddit users
+func GetFollowedUsers(db database.Store) ([]FollowedUserFrontendResponse, error) {
+	daos, err := db.GetAllFollowedUsers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch followed users: %w", err)
+	}
+
+	responses := make([]FollowedUserFrontendResponse, len(daos))
+	for i, dao := range daos {
+		responses[i] = convertToFollowedUserResponse(dao)
+	}
+	return responses, nil
+}
+
+// GetPostsForUser retrieves a page of the posts ingested for a followed user
+func GetPostsForUser(db database.Store, username string, pagination database.Paginate) (PostsResponse, error) {
+	user, err := db.GetFollowedUser(username)
+	if err != nil {
+		return PostsResponse{}, err
+	}
+
+	page, err := db.GetSourcePosts(database.SourceTypeUser, user.Name, pagination)
+	if err != nil {
+		return PostsResponse{}, fmt.Errorf("failed to get posts for followed user %s: %w", user.Name, err)
+	}
+
+	log.Printf("Retrieved %d posts for followed user: %s", len(page.Items), user.Name)
+	return convertToPostsResponse(page), nil
+}
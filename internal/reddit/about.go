@@ -0,0 +1,97 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// subredditNamePattern matches the names Reddit allows for subreddits
+var subredditNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_]{1,20}$`)
+
+// NormalizeSubredditName strips an r/ prefix and surrounding whitespace from a
+// subreddit name and checks that Reddit would accept it
+func NormalizeSubredditName(name string) (string, error) {
+	normalized := strings.TrimSpace(name)
+	normalized = strings.TrimPrefix(normalized, "/")
+	if len(normalized) > 2 && strings.EqualFold(normalized[:2], "r/") {
+		normalized = normalized[2:]
+	}
+	normalized = strings.TrimSuffix(normalized, "/")
+
+	if !subredditNamePattern.MatchString(normalized) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	return normalized, nil
+}
+
+// SubredditAbout describes a subreddit as reported by its about.json
+type SubredditAbout struct {
+	// Name is the canonical display name of the subreddit
+	Name string
+	// Fullname is the type-prefixed id Reddit uses to reference the subreddit, e.g. t5_2qh1o
+	Fullname            string
+	Title               string
+	PublicDescription   string
+	CreatedAt           time.Time
+	Nsfw                bool
+	ActiveUsers         int
+	IconUrl             string
+	NumberOfSubscribers int
+}
+
+// aboutResponseJson represents the JSON structure of a subreddit about.json response
+type aboutResponseJson struct {
+	Kind string `json:"kind"`
+	Data struct {
+		DisplayName       string  `json:"display_name"`
+		Name              string  `json:"name"`
+		Title             string  `json:"title"`
+		PublicDescription string  `json:"public_description"`
+		CreatedUtc        float64 `json:"created_utc"`
+		Over18            bool    `json:"over18"`
+		ActiveUserCount   int     `json:"active_user_count"`
+		AccountsActive    int     `json:"accounts_active"`
+		CommunityIcon     string  `json:"community_icon"`
+		IconImg           string  `json:"icon_img"`
+		Subscribers       int     `json:"subscribers"`
+	} `json:"data"`
+}
+
+// AboutSubreddit fetches the metadata of a subreddit
+func (c *Client) AboutSubreddit(ctx context.Context, subreddit string) (SubredditAbout, error) {
+	if subreddit == "" {
+		return SubredditAbout{}, fmt.Errorf("subreddit cannot be empty")
+	}
+
+	path := fmt.Sprintf("/r/%s/about.json", url.PathEscape(subreddit))
+	responseJson, err := getJSON[aboutResponseJson](ctx, c, path, nil)
+	if err != nil {
+		return SubredditAbout{}, fmt.Errorf("failed to decode JSON response: %w", err)
+	}
+
+	if responseJson.Kind != "t5" {
+		return SubredditAbout{}, fmt.Errorf("%w: %s", ErrNotFound, subreddit)
+	}
+
+	data := responseJson.Data
+	about := SubredditAbout{
+		Name:                data.DisplayName,
+		Fullname:            data.Name,
+		Title:               html.UnescapeString(data.Title),
+		PublicDescription:   html.UnescapeString(data.PublicDescription),
+		CreatedAt:           time.Unix(int64(data.CreatedUtc), 0).UTC(),
+		Nsfw:                data.Over18,
+		ActiveUsers:         max(data.ActiveUserCount, data.AccountsActive),
+		IconUrl:             html.UnescapeString(data.CommunityIcon),
+		NumberOfSubscribers: data.Subscribers,
+	}
+	if about.IconUrl == "" {
+		about.IconUrl = html.UnescapeString(data.IconImg)
+	}
+	return about, nil
+}
@@ -2,23 +2,89 @@ package reddit
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"html"
+	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
 
 const (
-	baseURL         = "https://www.reddit.com/r/%s/top.json?t=%s"
-	defaultTimeout  = 30 * time.Second
-	userAgentString = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:123.0) Gecko/20100101 Firefox/123.0"
+	anonymousBaseURL = "https://www.reddit.com"
+	oauthBaseURL     = "https://oauth.reddit.com"
+	defaultTimeout   = 30 * time.Second
+	// DefaultPageSize is the number of posts Reddit returns per page when no limit is given
+	DefaultPageSize = 25
+	// MaxPageSize is the largest page Reddit serves for a listing
+	MaxPageSize = 100
 )
 
 // Client represents a Reddit API client
 type Client struct {
-	httpClient *http.Client
+	// requestDoer sends every HTTP request, an *http.Client unless injected
+	requestDoer RequestDoer
+	// doer sends API requests through the rate limiter
+	doer       RequestDoer
 	userAgent  string
+	baseURL    string
+	tokenURL   string
+	tokens     *tokenSource
+	limiter    *RateLimiter
+	maxRetries int
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithCredentials authenticates the client with OAuth2 against oauth.reddit.com.
+// Credentials that are not configured leave the client in anonymous mode.
+func WithCredentials(credentials Credentials) Option {
+	return func(c *Client) {
+		if !credentials.Configured() {
+			return
+		}
+		c.tokens = &tokenSource{credentials: credentials}
+	}
+}
+
+// WithBaseURL overrides the Reddit API host, e.g. to point at a local stand-in
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithRequestDoer sends all requests, including token requests, through doer
+// instead of the default *http.Client
+func WithRequestDoer(doer RequestDoer) Option {
+	return func(c *Client) {
+		c.requestDoer = doer
+	}
+}
+
+// WithRateLimiter paces the client through a limiter shared with other clients
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(c *Client) {
+		c.limiter = limiter
+	}
+}
+
+// WithMaxRetries sets how many times 429 and 5xx responses are retried
+func WithMaxRetries(maxRetries int) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+	}
+}
+
+// WithTokenURL overrides the OAuth2 token endpoint
+func WithTokenURL(tokenURL string) Option {
+	return func(c *Client) {
+		c.tokenURL = tokenURL
+	}
 }
 
 // Subreddit represents a Reddit subreddit
@@ -30,13 +96,38 @@ type Subreddit struct {
 
 // RedditPost represents a single Reddit post
 type RedditPost struct {
-	PostId        string
-	Title         string
-	Content       string
+	PostId string
+	// Fullname is the type-prefixed id Reddit uses to reference the post, e.g. t3_abc123
+	Fullname string
+	Title    string
+	Content  string
+	Author   string
+	// Subreddit is the subreddit the post was submitted to
+	Subreddit     string
 	DiscussionUrl string
-	CommentCount  int
-	Upvotes       int
-	TimePosted    time.Time
+	// Url is the link target, the external article for link posts
+	Url          string
+	Domain       string
+	IsSelf       bool
+	Thumbnail    string
+	Flair        string
+	Stickied     bool
+	Pinned       bool
+	Nsfw         bool
+	Spoiler      bool
+	CommentCount int
+	Upvotes      int
+	UpvoteRatio  float64
+	TimePosted   time.Time
+	// CrosspostParents are the posts this post was crossposted from
+	CrosspostParents []CrosspostParent
+}
+
+// CrosspostParent references the original of a crossposted post
+type CrosspostParent struct {
+	PostId    string `json:"postId"`
+	Subreddit string `json:"subreddit"`
+	Permalink string `json:"permalink"`
 }
 
 // RedditPosts is a slice of RedditPost
@@ -45,90 +136,356 @@ type RedditPosts []RedditPost
 // subredditResponseJson represents the JSON structure of a Reddit API response
 type subredditResponseJson struct {
 	Data struct {
+		After    string `json:"after"`
 		Children []struct {
-			Data struct {
-				Id                   string  `json:"id"`
-				Title                string  `json:"title"`
-				SelfText             string  `json:"selftext"`
-				Upvotes              int     `json:"ups"`
-				Url                  string  `json:"url"`
-				Time                 float64 `json:"created"`
-				CommentsCount        int     `json:"num_comments"`
-				Domain               string  `json:"domain"`
-				Permalink            string  `json:"permalink"`
-				Stickied             bool    `json:"stickied"`
-				Pinned               bool    `json:"pinned"`
-				IsSelf               bool    `json:"is_self"`
-				Thumbnail            string  `json:"thumbnail"`
-				Flair                string  `json:"link_flair_text"`
-				SubredditSubscribers int     `json:"subreddit_subscribers"`
-				ParentList           []struct {
-					Id        string `json:"id"`
-					Subreddit string `json:"subreddit"`
-					Permalink string `json:"permalink"`
-				} `json:"crosspost_parent_list"`
-			} `json:"data"`
+			Data postJson `json:"data"`
 		} `json:"children"`
 	} `json:"data"`
 }
 
-// NewClient creates a new Reddit API client
-func NewClient(userAgent string) *Client {
-	return &Client{
-		httpClient: &http.Client{Timeout: defaultTimeout},
-		userAgent:  userAgent,
+// postJson represents the JSON structure of a single post in a Reddit listing
+type postJson struct {
+	Id                   string  `json:"id"`
+	Name                 string  `json:"name"`
+	Title                string  `json:"title"`
+	SelfText             string  `json:"selftext"`
+	Author               string  `json:"author"`
+	Subreddit            string  `json:"subreddit"`
+	Upvotes              int     `json:"ups"`
+	UpvoteRatio          float64 `json:"upvote_ratio"`
+	Over18               bool    `json:"over_18"`
+	Spoiler              bool    `json:"spoiler"`
+	Url                  string  `json:"url"`
+	Time                 float64 `json:"created"`
+	CommentsCount        int     `json:"num_comments"`
+	Domain               string  `json:"domain"`
+	Permalink            string  `json:"permalink"`
+	Stickied             bool    `json:"stickied"`
+	Pinned               bool    `json:"pinned"`
+	IsSelf               bool    `json:"is_self"`
+	Thumbnail            string  `json:"thumbnail"`
+	Flair                string  `json:"link_flair_text"`
+	SubredditSubscribers int     `json:"subreddit_subscribers"`
+	ParentList           []struct {
+		Id        string `json:"id"`
+		Subreddit string `json:"subreddit"`
+		Permalink string `json:"permalink"`
+	} `json:"crosspost_parent_list"`
+}
+
+// ListingOptions selects a listing and controls how many posts are fetched from it
+type ListingOptions struct {
+	// Listing is the sort order of the listing, defaulting to top
+	Listing Listing
+	// TimeWindow is the period covered by top and controversial listings, defaulting to day
+	TimeWindow TimeWindow
+	// Limit is the number of posts requested per page, capped at MaxPageSize
+	Limit int
+	// MaxPosts is the total number of posts to fetch across pages. Zero fetches a single page.
+	MaxPosts int
+}
+
+// NewClient creates a new Reddit API client. Without credentials the client
+// uses the anonymous www.reddit.com endpoints.
+func NewClient(userAgent string, opts ...Option) *Client {
+	c := &Client{
+		requestDoer: &http.Client{Timeout: defaultTimeout},
+		userAgent:   userAgent,
+		tokenURL:    defaultTokenURL,
+		maxRetries:  DefaultMaxRetries,
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	if c.limiter == nil {
+		c.limiter = NewRateLimiter(DefaultMaxRateLimitWait)
+	}
+	c.doer = limitedDoer{next: c.requestDoer, limiter: c.limiter}
+
+	if c.tokens != nil {
+		c.tokens.tokenURL = c.tokenURL
+		c.tokens.userAgent = c.userAgent
+		c.tokens.requestDoer = c.requestDoer
+	}
+	if c.baseURL == "" {
+		c.baseURL = anonymousBaseURL
+		if c.tokens != nil {
+			c.baseURL = oauthBaseURL
+		}
+	}
+	return c
 }
 
-// makeRequest creates a new HTTP request for the Reddit API
-func makeRequest(subreddit, sort string) (*http.Request, error) {
-	requestUrl := fmt.Sprintf(baseURL, subreddit, strings.ToLower(sort))
-	request, err := http.NewRequest("GET", requestUrl, nil)
+// Authenticated reports whether the client uses OAuth2 instead of anonymous access
+func (c *Client) Authenticated() bool {
+	return c.tokens != nil
+}
+
+// newRequest creates a new HTTP request for the Reddit API, authorized when credentials are set
+func (c *Client) newRequest(ctx context.Context, path string, query url.Values) (*http.Request, error) {
+	requestUrl := c.baseURL + path
+	if len(query) > 0 {
+		requestUrl += "?" + query.Encode()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	request.Header.Set("User-Agent", userAgentString)
+	request.Header.Set("User-Agent", c.userAgent)
+
+	if c.tokens != nil {
+		token, err := c.tokens.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to obtain access token: %w", err)
+		}
+		request.Header.Set("Authorization", "bearer "+token)
+	}
 	return request, nil
 }
 
-// DescribeSubreddit fetches and describes a subreddit
-func (c *Client) DescribeSubreddit(ctx context.Context, subreddit, sort string) (Subreddit, error) {
-	if subreddit == "" || sort == "" {
-		return Subreddit{}, fmt.Errorf("subreddit and sort cannot be empty")
+// getJSON fetches a Reddit API path and decodes the JSON response. It refreshes
+// the access token once if Reddit rejects it and retries 429 and 5xx responses
+// with jittered exponential backoff, honoring Retry-After.
+func getJSON[T any](ctx context.Context, c *Client, path string, query url.Values) (T, error) {
+	refreshedToken := false
+	for retry := 0; ; {
+		request, err := c.newRequest(ctx, path, query)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+
+		result, err := decodeJSONFromRequest[T](ctx, c.doer, request)
+		var statusErr *statusError
+		if !errors.As(err, &statusErr) {
+			return result, err
+		}
+
+		if statusErr.StatusCode == http.StatusUnauthorized && c.tokens != nil && !refreshedToken {
+			c.tokens.Invalidate()
+			refreshedToken = true
+			continue
+		}
+
+		if !retryable(statusErr.StatusCode) {
+			return result, err
+		}
+
+		delay := retryBackoff(retry)
+		if statusErr.HasRetryAfter {
+			delay = statusErr.RetryAfter
+		}
+		if retry >= c.maxRetries || delay > c.limiter.maxWait {
+			if statusErr.StatusCode == http.StatusTooManyRequests {
+				return result, &RateLimitError{ResetAt: time.Now().Add(delay)}
+			}
+			return result, err
+		}
+
+		log.Printf("Retrying %s in %s after status %d", path, delay, statusErr.StatusCode)
+		if err := sleep(ctx, delay); err != nil {
+			return result, err
+		}
+		retry++
 	}
+}
 
-	request, err := makeRequest(subreddit, sort)
+// DescribeSubreddit fetches and describes a subreddit, following the listing's
+// after cursor until opts.MaxPosts posts have been fetched or the listing ends
+func (c *Client) DescribeSubreddit(ctx context.Context, subreddit string, opts ListingOptions) (Subreddit, error) {
+	if subreddit == "" {
+		return Subreddit{}, fmt.Errorf("subreddit cannot be empty")
+	}
+	opts, err := opts.normalize()
 	if err != nil {
 		return Subreddit{}, err
 	}
 
-	responseJson, err := decodeJSONFromRequest[subredditResponseJson](ctx, c.httpClient, request)
+	path, query := listingRequest(url.PathEscape(subreddit), opts)
+	children, err := c.fetchListing(ctx, path, query, opts)
 	if err != nil {
-		return Subreddit{}, fmt.Errorf("failed to decode JSON response: %w", err)
+		return Subreddit{}, err
 	}
 
-	if len(responseJson.Data.Children) == 0 {
-		return Subreddit{}, fmt.Errorf("no posts found for subreddit: %s", subreddit)
+	posts := make([]RedditPost, 0, len(children))
+	for _, post := range children {
+		posts = append(posts, post.toRedditPost())
 	}
 
-	posts := make([]RedditPost, 0, len(responseJson.Data.Children))
-	for _, child := range responseJson.Data.Children {
-		post := child.Data
-		forumPost := RedditPost{
-			PostId:        post.Id,
-			Title:         html.UnescapeString(post.Title),
-			Content:       html.UnescapeString(post.SelfText),
-			DiscussionUrl: fmt.Sprintf("https://reddit.com%s", post.Permalink),
-			CommentCount:  post.CommentsCount,
-			Upvotes:       post.Upvotes,
-			TimePosted:    time.Unix(int64(post.Time), 0),
-		}
-		posts = append(posts, forumPost)
+	// Listings only carry the subscriber count on posts, AboutSubreddit is authoritative
+	numberOfSubscribers := 0
+	if len(children) > 0 {
+		numberOfSubscribers = children[0].SubredditSubscribers
 	}
 
 	return Subreddit{
 		Name:                subreddit,
-		NumberOfSubscribers: responseJson.Data.Children[0].Data.SubredditSubscribers,
+		NumberOfSubscribers: numberOfSubscribers,
 		Posts:               posts,
 	}, nil
 }
+
+// DescribeSubreddits fetches a combined listing of several subreddits (r/a+b+c),
+// requesting all of them at once, and attributes every post back to its subreddit
+// using the listing's subreddit field. opts.MaxPosts applies to the combined listing.
+// The result holds one Subreddit per requested name, in order.
+func (c *Client) DescribeSubreddits(ctx context.Context, subreddits []string, opts ListingOptions) ([]Subreddit, error) {
+	if len(subreddits) == 0 {
+		return nil, fmt.Errorf("subreddits cannot be empty")
+	}
+	opts, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	escaped := make([]string, len(subreddits))
+	results := make([]Subreddit, len(subreddits))
+	indexByName := make(map[string]int, len(subreddits))
+	for i, subreddit := range subreddits {
+		if subreddit == "" {
+			return nil, fmt.Errorf("subreddit cannot be empty")
+		}
+		escaped[i] = url.PathEscape(subreddit)
+		results[i] = Subreddit{Name: subreddit, Posts: RedditPosts{}}
+		indexByName[strings.ToLower(subreddit)] = i
+	}
+
+	path, query := listingRequest(strings.Join(escaped, "+"), opts)
+	children, err := c.fetchListing(ctx, path, query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, post := range children {
+		i, ok := indexByName[strings.ToLower(post.Subreddit)]
+		if !ok {
+			continue
+		}
+		results[i].Posts = append(results[i].Posts, post.toRedditPost())
+		if post.SubredditSubscribers > 0 {
+			results[i].NumberOfSubscribers = post.SubredditSubscribers
+		}
+	}
+	return results, nil
+}
+
+// listingRequest builds the path and query of a listing of one or more "+"-joined subreddits
+func listingRequest(subreddits string, opts ListingOptions) (string, url.Values) {
+	path := fmt.Sprintf("/r/%s/%s.json", subreddits, opts.Listing)
+	query := url.Values{}
+	if opts.Listing.HasTimeWindow() {
+		query.Set("t", string(opts.TimeWindow))
+	}
+	return path, query
+}
+
+// toRedditPost converts a listing entry to a RedditPost
+func (post postJson) toRedditPost() RedditPost {
+	redditPost := RedditPost{
+		PostId:        post.Id,
+		Fullname:      post.Name,
+		Title:         html.UnescapeString(post.Title),
+		Content:       html.UnescapeString(post.SelfText),
+		Author:        post.Author,
+		Subreddit:     post.Subreddit,
+		DiscussionUrl: fmt.Sprintf("https://reddit.com%s", post.Permalink),
+		Url:           html.UnescapeString(post.Url),
+		Domain:        post.Domain,
+		IsSelf:        post.IsSelf,
+		Flair:         html.UnescapeString(post.Flair),
+		Stickied:      post.Stickied,
+		Pinned:        post.Pinned,
+		Nsfw:          post.Over18,
+		Spoiler:       post.Spoiler,
+		CommentCount:  post.CommentsCount,
+		Upvotes:       post.Upvotes,
+		UpvoteRatio:   post.UpvoteRatio,
+		TimePosted:    time.Unix(int64(post.Time), 0),
+	}
+	if redditPost.Fullname == "" && post.Id != "" {
+		redditPost.Fullname = "t3_" + post.Id
+	}
+	// Reddit uses placeholders such as "self" and "default" when there is no thumbnail image
+	if strings.HasPrefix(post.Thumbnail, "http") {
+		redditPost.Thumbnail = html.UnescapeString(post.Thumbnail)
+	}
+	for _, parent := range post.ParentList {
+		redditPost.CrosspostParents = append(redditPost.CrosspostParents, CrosspostParent{
+			PostId:    parent.Id,
+			Subreddit: parent.Subreddit,
+			Permalink: fmt.Sprintf("https://reddit.com%s", parent.Permalink),
+		})
+	}
+	return redditPost
+}
+
+// normalize applies listing defaults and validates the options
+func (opts ListingOptions) normalize() (ListingOptions, error) {
+	if opts.Listing == "" {
+		opts.Listing = ListingTop
+	}
+	if opts.TimeWindow == "" {
+		opts.TimeWindow = TimeWindowDay
+	}
+
+	var err error
+	if opts.Listing, err = ParseListing(string(opts.Listing)); err != nil {
+		return opts, err
+	}
+	if opts.TimeWindow, err = ParseTimeWindow(string(opts.TimeWindow)); err != nil {
+		return opts, err
+	}
+	if opts.Limit < 0 || opts.MaxPosts < 0 {
+		return opts, fmt.Errorf("limit and max posts cannot be negative")
+	}
+	return opts, nil
+}
+
+// fetchListing pages through a listing using its after cursor
+func (c *Client) fetchListing(ctx context.Context, path string, query url.Values, opts ListingOptions) ([]postJson, error) {
+	pageSize := opts.Limit
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+	pageSize = min(pageSize, MaxPageSize)
+
+	maxPosts := opts.MaxPosts
+	if maxPosts == 0 {
+		maxPosts = pageSize
+	}
+
+	var posts []postJson
+	after := ""
+	for len(posts) < maxPosts {
+		pageQuery := url.Values{}
+		for key, values := range query {
+			pageQuery[key] = values
+		}
+		pageQuery.Set("limit", strconv.Itoa(min(pageSize, maxPosts-len(posts))))
+		if after != "" {
+			pageQuery.Set("after", after)
+			pageQuery.Set("count", strconv.Itoa(len(posts)))
+		}
+
+		responseJson, err := getJSON[subredditResponseJson](ctx, c, path, pageQuery)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode JSON response: %w", err)
+		}
+
+		for _, child := range responseJson.Data.Children {
+			posts = append(posts, child.Data)
+		}
+
+		after = responseJson.Data.After
+		if after == "" || len(responseJson.Data.Children) == 0 {
+			break
+		}
+	}
+
+	if len(posts) > maxPosts {
+		posts = posts[:maxPosts]
+	}
+	return posts, nil
+}
@@ -0,0 +1,152 @@
+package reddit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTokenURL = "https://www.reddit.com/api/v1/access_token"
+	// tokenExpiryDelta refreshes tokens slightly before Reddit expires them
+	tokenExpiryDelta = time.Minute
+)
+
+// Credentials holds the OAuth2 credentials of a Reddit application.
+// Username and Password are only set for script apps using the password grant,
+// otherwise the application-only client credentials grant is used.
+type Credentials struct {
+	ClientID     string
+	ClientSecret string
+	Username     string
+	Password     string
+}
+
+// CredentialsFromEnv reads Reddit credentials from the REDDIT_CLIENT_ID,
+// REDDIT_CLIENT_SECRET, REDDIT_USERNAME and REDDIT_PASSWORD environment variables
+func CredentialsFromEnv() Credentials {
+	return Credentials{
+		ClientID:     os.Getenv("REDDIT_CLIENT_ID"),
+		ClientSecret: os.Getenv("REDDIT_CLIENT_SECRET"),
+		Username:     os.Getenv("REDDIT_USERNAME"),
+		Password:     os.Getenv("REDDIT_PASSWORD"),
+	}
+}
+
+// Configured reports whether enough credentials are set to authenticate
+func (c Credentials) Configured() bool {
+	return c.ClientID != ""
+}
+
+// grantForm builds the token request form for the grant matching the credentials
+func (c Credentials) grantForm() url.Values {
+	form := url.Values{}
+	if c.Username != "" {
+		form.Set("grant_type", "password")
+		form.Set("username", c.Username)
+		form.Set("password", c.Password)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	return form
+}
+
+// tokenResponseJson represents the JSON structure of a Reddit access token response
+type tokenResponseJson struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Error       string `json:"error"`
+}
+
+// tokenSource fetches, caches and refreshes OAuth2 bearer tokens
+type tokenSource struct {
+	credentials Credentials
+	tokenURL    string
+	userAgent   string
+	requestDoer RequestDoer
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// Token returns a valid bearer token, fetching a new one when the cached token has expired
+func (ts *tokenSource) Token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token != "" && time.Now().Before(ts.expiresAt.Add(-tokenExpiryDelta)) {
+		return ts.token, nil
+	}
+
+	token, err := ts.fetchToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	ts.token = token.AccessToken
+	ts.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return ts.token, nil
+}
+
+// Invalidate drops the cached token so the next call to Token fetches a new one
+func (ts *tokenSource) Invalidate() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.token = ""
+}
+
+// fetchToken requests a new access token from the token endpoint
+func (ts *tokenSource) fetchToken(ctx context.Context) (tokenResponseJson, error) {
+	var token tokenResponseJson
+
+	form := ts.credentials.grantForm()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return token, fmt.Errorf("failed to create token request: %w", err)
+	}
+	request.SetBasicAuth(ts.credentials.ClientID, ts.credentials.ClientSecret)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("User-Agent", ts.userAgent)
+
+	response, err := ts.requestDoer.Do(request)
+	if err != nil {
+		return token, fmt.Errorf("failed to send token request: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return token, fmt.Errorf("failed to read token response body: %w", err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return token, fmt.Errorf(
+			"unexpected status code %d from token endpoint, response: %s",
+			response.StatusCode,
+			truncateString(string(body), maxTruncateLength),
+		)
+	}
+
+	if err := json.Unmarshal(body, &token); err != nil {
+		return token, fmt.Errorf("failed to unmarshal token response: %w", err)
+	}
+
+	// Reddit reports bad credentials with a 200 and an error field
+	if token.Error != "" {
+		return token, fmt.Errorf("token endpoint returned error: %s", token.Error)
+	}
+	if token.AccessToken == "" {
+		return token, fmt.Errorf("token endpoint returned no access token")
+	}
+
+	return token, nil
+}
@@ -0,0 +1,239 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// listingBody is a listing holding a single post, enough for DescribeSubreddit to succeed
+const listingBody = `{"data": {"after": "", "children": [{"data": {"id": "abc123", "title": "Hello", "subreddit": "golang", "created": 1700000000}}]}}`
+
+// tokenServer is a stand-in for Reddit's token endpoint that hands out numbered tokens
+type tokenServer struct {
+	*httptest.Server
+	expiresIn int
+
+	mu    sync.Mutex
+	forms []map[string]string
+}
+
+func newTokenServer(t *testing.T, expiresIn int) *tokenServer {
+	ts := &tokenServer{expiresIn: expiresIn}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, ok := r.BasicAuth()
+		if r.Method != http.MethodPost || !ok || clientID != "client-id" || clientSecret != "client-secret" {
+			http.Error(w, `{"error": "invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ts.mu.Lock()
+		ts.forms = append(ts.forms, map[string]string{
+			"grant_type": r.PostForm.Get("grant_type"),
+			"username":   r.PostForm.Get("username"),
+			"password":   r.PostForm.Get("password"),
+		})
+		token := fmt.Sprintf("token-%d", len(ts.forms))
+		ts.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": %q, "token_type": "bearer", "expires_in": %d}`, token, ts.expiresIn)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// requests returns the forms of the token requests received so far
+func (ts *tokenServer) requests() []map[string]string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return append([]map[string]string(nil), ts.forms...)
+}
+
+// newAPIServer serves listingBody to requests carrying an accepted bearer token and
+// records the Authorization header of every request
+func newAPIServer(t *testing.T, accepted func(authorization string) bool) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		mu.Lock()
+		authorizations = append(authorizations, authorization)
+		mu.Unlock()
+
+		if !accepted(authorization) {
+			http.Error(w, `{"message": "Unauthorized", "error": 401}`, http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, listingBody)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), authorizations...)
+	}
+}
+
+func acceptAnyToken(authorization string) bool {
+	return authorization != ""
+}
+
+func TestClientCredentialsGrant(t *testing.T) {
+	tokens := newTokenServer(t, 3600)
+	api, authorizations := newAPIServer(t, acceptAnyToken)
+	client := NewClient("hecate-test",
+		WithCredentials(Credentials{ClientID: "client-id", ClientSecret: "client-secret"}),
+		WithBaseURL(api.URL),
+		WithTokenURL(tokens.URL),
+	)
+
+	if !client.Authenticated() {
+		t.Fatal("client with credentials is not authenticated")
+	}
+	subreddit, err := client.DescribeSubreddit(context.Background(), "golang", ListingOptions{})
+	if err != nil {
+		t.Fatalf("DescribeSubreddit: %v", err)
+	}
+	if len(subreddit.Posts) != 1 {
+		t.Fatalf("got %d posts, want 1", len(subreddit.Posts))
+	}
+
+	requests := tokens.requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d token requests, want 1", len(requests))
+	}
+	if requests[0]["grant_type"] != "client_credentials" || requests[0]["username"] != "" {
+		t.Errorf("token request form is %v, want the client_credentials grant", requests[0])
+	}
+	if got := authorizations(); len(got) != 1 || got[0] != "bearer token-1" {
+		t.Errorf("API requests were authorized with %q, want bearer token-1", got)
+	}
+}
+
+func TestPasswordGrant(t *testing.T) {
+	tokens := newTokenServer(t, 3600)
+	api, _ := newAPIServer(t, acceptAnyToken)
+	client := NewClient("hecate-test",
+		WithCredentials(Credentials{ClientID: "client-id", ClientSecret: "client-secret", Username: "someone", Password: "hunter2"}),
+		WithBaseURL(api.URL),
+		WithTokenURL(tokens.URL),
+	)
+
+	if _, err := client.DescribeSubreddit(context.Background(), "golang", ListingOptions{}); err != nil {
+		t.Fatalf("DescribeSubreddit: %v", err)
+	}
+
+	requests := tokens.requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d token requests, want 1", len(requests))
+	}
+	want := map[string]string{"grant_type": "password", "username": "someone", "password": "hunter2"}
+	for key, value := range want {
+		if requests[0][key] != value {
+			t.Errorf("token request %s is %q, want %q", key, requests[0][key], value)
+		}
+	}
+}
+
+func TestTokenCachedUntilExpiry(t *testing.T) {
+	tests := []struct {
+		name          string
+		expiresIn     int
+		tokenRequests int
+	}{
+		{"valid token is reused", 3600, 1},
+		// Tokens are refreshed tokenExpiryDelta before Reddit expires them
+		{"expiring token is refreshed", int(tokenExpiryDelta.Seconds()) - 1, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := newTokenServer(t, tt.expiresIn)
+			api, _ := newAPIServer(t, acceptAnyToken)
+			client := NewClient("hecate-test",
+				WithCredentials(Credentials{ClientID: "client-id", ClientSecret: "client-secret"}),
+				WithBaseURL(api.URL),
+				WithTokenURL(tokens.URL),
+			)
+
+			for range 3 {
+				if _, err := client.DescribeSubreddit(context.Background(), "golang", ListingOptions{}); err != nil {
+					t.Fatalf("DescribeSubreddit: %v", err)
+				}
+			}
+			if got := len(tokens.requests()); got != tt.tokenRequests {
+				t.Errorf("got %d token requests for 3 API requests, want %d", got, tt.tokenRequests)
+			}
+		})
+	}
+}
+
+func TestRejectedTokenIsRefreshedOnce(t *testing.T) {
+	tokens := newTokenServer(t, 3600)
+	// Only the second token is accepted, as if Reddit revoked the first one early
+	api, authorizations := newAPIServer(t, func(authorization string) bool {
+		return authorization == "bearer token-2"
+	})
+	client := NewClient("hecate-test",
+		WithCredentials(Credentials{ClientID: "client-id", ClientSecret: "client-secret"}),
+		WithBaseURL(api.URL),
+		WithTokenURL(tokens.URL),
+	)
+
+	if _, err := client.DescribeSubreddit(context.Background(), "golang", ListingOptions{}); err != nil {
+		t.Fatalf("DescribeSubreddit: %v", err)
+	}
+	want := []string{"bearer token-1", "bearer token-2"}
+	if got := authorizations(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("API requests were authorized with %q, want %q", got, want)
+	}
+	if got := len(tokens.requests()); got != 2 {
+		t.Errorf("got %d token requests, want 2", got)
+	}
+}
+
+func TestRejectedRefreshedTokenFails(t *testing.T) {
+	tokens := newTokenServer(t, 3600)
+	api, authorizations := newAPIServer(t, func(string) bool { return false })
+	client := NewClient("hecate-test",
+		WithCredentials(Credentials{ClientID: "client-id", ClientSecret: "client-secret"}),
+		WithBaseURL(api.URL),
+		WithTokenURL(tokens.URL),
+	)
+
+	if _, err := client.DescribeSubreddit(context.Background(), "golang", ListingOptions{}); err == nil {
+		t.Fatal("DescribeSubreddit succeeded with every token rejected")
+	}
+	if got := len(authorizations()); got != 2 {
+		t.Errorf("got %d API requests, want the rejected request to be retried once", got)
+	}
+}
+
+func TestTokenEndpointError(t *testing.T) {
+	// Reddit reports bad credentials with a 200 and an error field
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"error": "invalid_grant"}`)
+	}))
+	defer tokens.Close()
+	api, authorizations := newAPIServer(t, acceptAnyToken)
+	client := NewClient("hecate-test",
+		WithCredentials(Credentials{ClientID: "client-id", ClientSecret: "client-secret", Username: "someone", Password: "wrong"}),
+		WithBaseURL(api.URL),
+		WithTokenURL(tokens.URL),
+	)
+
+	if _, err := client.DescribeSubreddit(context.Background(), "golang", ListingOptions{}); err == nil {
+		t.Fatal("DescribeSubreddit succeeded without a token")
+	}
+	if got := len(authorizations()); got != 0 {
+		t.Errorf("got %d API requests, want none without a token", got)
+	}
+}
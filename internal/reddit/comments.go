@@ -0,0 +1,272 @@
+package reddit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultCommentDepth is the number of reply levels fetched when no depth is given
+	DefaultCommentDepth = 5
+	// DefaultCommentLimit is the number of comments requested when no limit is given
+	DefaultCommentLimit = 100
+	// maxMoreChildren is the number of comment ids Reddit expands per morechildren request
+	maxMoreChildren = 100
+	// defaultMaxMoreRequests bounds the morechildren requests made for a single post
+	defaultMaxMoreRequests = 5
+)
+
+// Comment represents a single Reddit comment and its replies
+type Comment struct {
+	CommentId string
+	// ParentId is the id of the parent comment, empty for top-level comments
+	ParentId   string
+	Author     string
+	Content    string
+	Upvotes    int
+	Depth      int
+	TimePosted time.Time
+	Replies    []Comment
+}
+
+// CommentOptions controls how much of a comment tree is fetched
+type CommentOptions struct {
+	// Depth is the maximum number of reply levels, defaulting to DefaultCommentDepth
+	Depth int
+	// Limit is the number of comments requested, defaulting to DefaultCommentLimit
+	Limit int
+	// MaxMoreRequests bounds the requests made to expand "more" stubs. Negative disables expansion.
+	MaxMoreRequests int
+}
+
+// thingJson represents a Reddit thing of any kind
+type thingJson struct {
+	Kind string          `json:"kind"`
+	Data json.RawMessage `json:"data"`
+}
+
+// commentListingJson represents the JSON structure of a listing of comments
+type commentListingJson struct {
+	Data struct {
+		Children []thingJson `json:"children"`
+	} `json:"data"`
+}
+
+// commentJson represents the JSON structure of a t1 comment
+type commentJson struct {
+	Id       string          `json:"id"`
+	ParentId string          `json:"parent_id"`
+	Author   string          `json:"author"`
+	Body     string          `json:"body"`
+	Score    int             `json:"score"`
+	Depth    int             `json:"depth"`
+	Created  float64         `json:"created_utc"`
+	Replies  json.RawMessage `json:"replies"`
+}
+
+// moreJson represents the JSON structure of a "more" stub hiding further comments
+type moreJson struct {
+	ParentId string   `json:"parent_id"`
+	Depth    int      `json:"depth"`
+	Children []string `json:"children"`
+}
+
+// moreChildrenResponseJson represents the JSON structure of a morechildren response
+type moreChildrenResponseJson struct {
+	Json struct {
+		Errors [][]string `json:"errors"`
+		Data   struct {
+			Things []thingJson `json:"things"`
+		} `json:"data"`
+	} `json:"json"`
+}
+
+// commentTree collects comments and "more" stubs while a thread is decoded
+type commentTree struct {
+	maxDepth int
+	byId     map[string]*Comment
+	order    []string
+	more     []moreJson
+}
+
+// DescribeComments fetches the comment tree of a post, expanding "more" stubs within the requested depth
+func (c *Client) DescribeComments(ctx context.Context, postId string, opts CommentOptions) ([]Comment, error) {
+	if postId == "" {
+		return nil, fmt.Errorf("post id cannot be empty")
+	}
+	if opts.Depth == 0 {
+		opts.Depth = DefaultCommentDepth
+	}
+	if opts.Limit == 0 {
+		opts.Limit = DefaultCommentLimit
+	}
+	if opts.MaxMoreRequests == 0 {
+		opts.MaxMoreRequests = defaultMaxMoreRequests
+	}
+	if opts.Depth < 0 || opts.Limit < 0 {
+		return nil, fmt.Errorf("comment depth and limit cannot be negative")
+	}
+
+	path := fmt.Sprintf("/comments/%s.json", url.PathEscape(postId))
+	query := url.Values{
+		"depth": {strconv.Itoa(opts.Depth)},
+		"limit": {strconv.Itoa(opts.Limit)},
+		"sort":  {"top"},
+	}
+	// The response is the post listing followed by the comment listing
+	responseJson, err := getJSON[[]commentListingJson](ctx, c, path, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JSON response: %w", err)
+	}
+	if len(responseJson) < 2 {
+		return nil, fmt.Errorf("unexpected comments response for post: %s", postId)
+	}
+
+	tree := &commentTree{maxDepth: opts.Depth, byId: map[string]*Comment{}}
+	if err := tree.addThings(responseJson[1].Data.Children); err != nil {
+		return nil, err
+	}
+
+	for requests := 0; requests < opts.MaxMoreRequests && len(tree.more) > 0; requests++ {
+		ids := tree.nextMoreIds()
+		if len(ids) == 0 {
+			break
+		}
+		things, err := c.moreChildren(ctx, postId, ids)
+		if err != nil {
+			return nil, err
+		}
+		if err := tree.addThings(things); err != nil {
+			return nil, err
+		}
+	}
+
+	return tree.build(), nil
+}
+
+// moreChildren expands the comments hidden behind "more" stubs
+func (c *Client) moreChildren(ctx context.Context, postId string, ids []string) ([]thingJson, error) {
+	query := url.Values{
+		"api_type":       {"json"},
+		"link_id":        {"t3_" + postId},
+		"children":       {strings.Join(ids, ",")},
+		"limit_children": {"false"},
+	}
+	responseJson, err := getJSON[moreChildrenResponseJson](ctx, c, "/api/morechildren.json", query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand more comments: %w", err)
+	}
+	if len(responseJson.Json.Errors) > 0 {
+		return nil, fmt.Errorf("failed to expand more comments: %v", responseJson.Json.Errors)
+	}
+	return responseJson.Json.Data.Things, nil
+}
+
+// addThings records comments and "more" stubs, walking nested replies
+func (t *commentTree) addThings(things []thingJson) error {
+	for _, thing := range things {
+		switch thing.Kind {
+		case "t1":
+			var comment commentJson
+			if err := json.Unmarshal(thing.Data, &comment); err != nil {
+				return fmt.Errorf("failed to unmarshal comment: %w", err)
+			}
+			if comment.Depth >= t.maxDepth {
+				continue
+			}
+			if _, seen := t.byId[comment.Id]; !seen {
+				t.order = append(t.order, comment.Id)
+			}
+			t.byId[comment.Id] = &Comment{
+				CommentId:  comment.Id,
+				ParentId:   parentCommentId(comment.ParentId),
+				Author:     comment.Author,
+				Content:    html.UnescapeString(comment.Body),
+				Upvotes:    comment.Score,
+				Depth:      comment.Depth,
+				TimePosted: time.Unix(int64(comment.Created), 0),
+			}
+
+			// Replies are an empty string when a comment has none
+			if bytes.HasPrefix(bytes.TrimSpace(comment.Replies), []byte("{")) {
+				var replies commentListingJson
+				if err := json.Unmarshal(comment.Replies, &replies); err != nil {
+					return fmt.Errorf("failed to unmarshal comment replies: %w", err)
+				}
+				if err := t.addThings(replies.Data.Children); err != nil {
+					return err
+				}
+			}
+		case "more":
+			var more moreJson
+			if err := json.Unmarshal(thing.Data, &more); err != nil {
+				return fmt.Errorf("failed to unmarshal more comments: %w", err)
+			}
+			// "Continue this thread" stubs have no children to expand
+			if len(more.Children) > 0 && more.Depth < t.maxDepth {
+				t.more = append(t.more, more)
+			}
+		}
+	}
+	return nil
+}
+
+// nextMoreIds takes up to one request's worth of comment ids out of the pending "more" stubs
+func (t *commentTree) nextMoreIds() []string {
+	var ids []string
+	for len(t.more) > 0 && len(ids) < maxMoreChildren {
+		more := &t.more[0]
+		take := min(len(more.Children), maxMoreChildren-len(ids))
+		ids = append(ids, more.Children[:take]...)
+		more.Children = more.Children[take:]
+		if len(more.Children) == 0 {
+			t.more = t.more[1:]
+		}
+	}
+	return ids
+}
+
+// build links the collected comments into a tree, preserving Reddit's order
+func (t *commentTree) build() []Comment {
+	children := map[string][]string{}
+	for _, id := range t.order {
+		comment := t.byId[id]
+		// Comments whose parent was not fetched become top-level
+		if _, ok := t.byId[comment.ParentId]; !ok {
+			comment.ParentId = ""
+		}
+		children[comment.ParentId] = append(children[comment.ParentId], id)
+	}
+
+	var link func(parent string) []Comment
+	link = func(parent string) []Comment {
+		ids := children[parent]
+		if len(ids) == 0 {
+			return nil
+		}
+		comments := make([]Comment, 0, len(ids))
+		for _, id := range ids {
+			comment := *t.byId[id]
+			comment.Replies = link(id)
+			comments = append(comments, comment)
+		}
+		return comments
+	}
+	return link("")
+}
+
+// parentCommentId strips the t1_ prefix from a parent fullname, returning
+// an empty id when the parent is the post itself
+func parentCommentId(fullname string) string {
+	if strings.HasPrefix(fullname, "t1_") {
+		return strings.TrimPrefix(fullname, "t1_")
+	}
+	return ""
+}
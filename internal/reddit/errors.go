@@ -0,0 +1,61 @@
+package reddit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors describing why Reddit refused a request. Match them with errors.Is.
+var (
+	ErrNotFound            = errors.New("subreddit not found")
+	ErrPrivate             = errors.New("subreddit is private")
+	ErrBanned              = errors.New("subreddit is banned")
+	ErrQuarantined         = errors.New("subreddit is quarantined")
+	ErrRateLimited         = errors.New("reddit rate limit exhausted")
+	ErrUpstreamUnavailable = errors.New("reddit is unavailable")
+	ErrInvalidName         = errors.New("invalid subreddit name")
+	ErrInvalidUsername     = errors.New("invalid username")
+)
+
+// errorResponseJson represents the JSON body Reddit sends with 403 and 404 responses
+type errorResponseJson struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// classifyStatus maps a non-200 response to one of the sentinel errors
+func classifyStatus(statusCode int, body []byte) error {
+	var errorJson errorResponseJson
+	_ = json.Unmarshal(body, &errorJson)
+
+	switch reason := strings.ToLower(errorJson.Reason); {
+	case reason == "private" || reason == "gold_only":
+		return ErrPrivate
+	case reason == "banned":
+		return ErrBanned
+	case reason == "quarantined":
+		return ErrQuarantined
+	}
+
+	switch {
+	case statusCode == http.StatusNotFound:
+		return ErrNotFound
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case statusCode >= http.StatusInternalServerError:
+		return ErrUpstreamUnavailable
+	}
+	return nil
+}
+
+// redirectedToSearch reports whether Reddit redirected a subreddit request to the
+// subreddit search, which is how anonymous requests for missing subreddits end up
+func redirectedToSearch(request *http.Request, response *http.Response) bool {
+	if response.Request == nil || response.Request.URL == nil {
+		return false
+	}
+	return response.Request.URL.Path != request.URL.Path &&
+		strings.HasPrefix(response.Request.URL.Path, "/subreddits/search")
+}
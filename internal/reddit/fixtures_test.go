@@ -0,0 +1,221 @@
+package reddit
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixtureDir holds the responses the replay tests are served
+const fixtureDir = "testdata/fixtures"
+
+// record re-records the fixtures from Reddit instead of replaying them. Reddit's content
+// changes, so expectations need updating after recording:
+//
+//	go test ./internal/reddit -run Replay -record
+var record = flag.Bool("record", false, "record fixtures from Reddit instead of replaying them")
+
+// newFixtureClient creates an anonymous client replaying fixtureDir, or recording to it with -record
+func newFixtureClient(t *testing.T) *Client {
+	t.Helper()
+	if *record {
+		return NewClient("hecate-test", WithRequestDoer(NewRecorder(fixtureDir, &http.Client{Timeout: 30 * time.Second})))
+	}
+	return NewClient("hecate-test", WithRequestDoer(NewReplayer(fixtureDir)))
+}
+
+func TestReplayListing(t *testing.T) {
+	client := newFixtureClient(t)
+
+	// Two pages of two posts, the second requested with the first page's after cursor
+	subreddit, err := client.DescribeSubreddit(context.Background(), "golang",
+		ListingOptions{Listing: ListingTop, TimeWindow: TimeWindowWeek, Limit: 2, MaxPosts: 4})
+	if err != nil {
+		t.Fatalf("DescribeSubreddit: %v", err)
+	}
+
+	var ids []string
+	for _, post := range subreddit.Posts {
+		ids = append(ids, post.PostId)
+		if post.Subreddit != "golang" {
+			t.Errorf("post %s is in r/%s, want r/golang", post.PostId, post.Subreddit)
+		}
+	}
+	if want := "1gx0a1b,1gx0b2c,1gx1c3d,1gx1d4e"; strings.Join(ids, ",") != want {
+		t.Errorf("got posts %v, want %s", ids, want)
+	}
+	if subreddit.NumberOfSubscribers != 262145 {
+		t.Errorf("got %d subscribers, want 262145", subreddit.NumberOfSubscribers)
+	}
+
+	link, self := subreddit.Posts[0], subreddit.Posts[1]
+	if link.IsSelf || link.Domain != "go.dev" || link.Url != "https://go.dev/doc/devel/release#go1.23.3" || link.Thumbnail != "" {
+		t.Errorf("link post is %+v, want a go.dev link without a thumbnail", link)
+	}
+	if !self.IsSelf || self.Fullname != "t3_1gx0b2c" || self.Upvotes != 187 || self.CommentCount != 96 {
+		t.Errorf("self post is %+v, want t3_1gx0b2c with 187 upvotes and 96 comments", self)
+	}
+	if !strings.Contains(self.Content, `the "internal" packages`) {
+		t.Errorf("self post content %q is not unescaped", self.Content)
+	}
+	if got := subreddit.Posts[2].Flair; got != "show & tell" {
+		t.Errorf("got flair %q, want it unescaped", got)
+	}
+	if want := time.Unix(1731341234, 0); !self.TimePosted.Equal(want) {
+		t.Errorf("self post was made at %s, want %s", self.TimePosted, want)
+	}
+}
+
+func TestReplayAbout(t *testing.T) {
+	client := newFixtureClient(t)
+
+	about, err := client.AboutSubreddit(context.Background(), "golang")
+	if err != nil {
+		t.Fatalf("AboutSubreddit: %v", err)
+	}
+	if about.Name != "golang" || about.Fullname != "t5_2rc7j" || about.Title != "The Go Programming Language" {
+		t.Errorf("got %+v, want r/golang", about)
+	}
+	if about.NumberOfSubscribers != 262145 || about.ActiveUsers != 312 || about.Nsfw {
+		t.Errorf("got %d subscribers, %d active users and nsfw %t, want 262145, 312 and false",
+			about.NumberOfSubscribers, about.ActiveUsers, about.Nsfw)
+	}
+	if want := time.Unix(1258329595, 0).UTC(); !about.CreatedAt.Equal(want) {
+		t.Errorf("created at %s, want %s", about.CreatedAt, want)
+	}
+	if strings.Contains(about.IconUrl, "&amp;") {
+		t.Errorf("icon URL %q is not unescaped", about.IconUrl)
+	}
+}
+
+func TestReplayComments(t *testing.T) {
+	client := newFixtureClient(t)
+
+	// The thread hides two comments behind a "more" stub, expanded with morechildren
+	comments, err := client.DescribeComments(context.Background(), "1gx0b2c", CommentOptions{Depth: 3})
+	if err != nil {
+		t.Fatalf("DescribeComments: %v", err)
+	}
+
+	if got := describeTree(comments); got != "l0b2c1(l0b2c2(l0b2c3)) l0b2c4 l0b2c5(l0b2c6)" {
+		t.Errorf("got comment tree %s", got)
+	}
+	reply := comments[0].Replies[0]
+	if reply.ParentId != "l0b2c1" || reply.Depth != 1 || reply.Author != "featurefirst" {
+		t.Errorf("reply is %+v, want a depth 1 reply to l0b2c1 by featurefirst", reply)
+	}
+	if !strings.Contains(reply.Content, ">80%") {
+		t.Errorf("reply content %q is not unescaped", reply.Content)
+	}
+	if expanded := comments[2]; expanded.ParentId != "" || expanded.Upvotes != 9 {
+		t.Errorf("expanded comment is %+v, want a top-level comment with 9 upvotes", expanded)
+	}
+}
+
+// describeTree writes a comment tree as ids with their replies in parentheses
+func describeTree(comments []Comment) string {
+	parts := make([]string, len(comments))
+	for i, comment := range comments {
+		parts[i] = comment.CommentId
+		if len(comment.Replies) > 0 {
+			parts[i] += "(" + describeTree(comment.Replies) + ")"
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestReplaySearch(t *testing.T) {
+	client := newFixtureClient(t)
+
+	posts, err := client.Search(context.Background(), "kyoto itinerary",
+		SearchOptions{Subreddit: "JapanTravel", Sort: SearchSortTop, TimeWindow: TimeWindowYear, Limit: 3})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(posts) != 3 {
+		t.Fatalf("got %d posts, want 3", len(posts))
+	}
+	for _, post := range posts {
+		if post.Subreddit != "JapanTravel" {
+			t.Errorf("post %s is in r/%s, want the search restricted to r/JapanTravel", post.PostId, post.Subreddit)
+		}
+		if !strings.Contains(strings.ToLower(post.Title+post.Content), "kyoto") {
+			t.Errorf("post %q does not mention kyoto", post.Title)
+		}
+	}
+	if posts[0].PostId != "1fa2k9z" || posts[0].Flair != "Itinerary" {
+		t.Errorf("first result is %+v, want the 1fa2k9z itinerary", posts[0])
+	}
+}
+
+func TestReplayUser(t *testing.T) {
+	client := newFixtureClient(t)
+
+	posts, err := client.DescribeUser(context.Background(), "spez", ListingOptions{Listing: ListingNew, Limit: 2})
+	if err != nil {
+		t.Fatalf("DescribeUser: %v", err)
+	}
+
+	var subreddits []string
+	for _, post := range posts {
+		subreddits = append(subreddits, post.Subreddit)
+		if post.Author != "spez" {
+			t.Errorf("post %s is by %s, want spez", post.PostId, post.Author)
+		}
+	}
+	// Submissions span subreddits and come newest first
+	if want := "reddit,announcements"; strings.Join(subreddits, ",") != want {
+		t.Errorf("got posts in %v, want %s", subreddits, want)
+	}
+	if len(posts) == 2 && !posts[0].TimePosted.After(posts[1].TimePosted) {
+		t.Errorf("posts are not newest first")
+	}
+}
+
+func TestReplayMissingFixture(t *testing.T) {
+	client := NewClient("hecate-test", WithRequestDoer(NewReplayer(fixtureDir)))
+
+	_, err := client.AboutSubreddit(context.Background(), "neverrecorded")
+	if !errors.Is(err, ErrFixtureNotFound) {
+		t.Fatalf("got %v, want ErrFixtureNotFound", err)
+	}
+}
+
+func TestRecorderRedactsTokens(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		fmt.Fprint(w, `{"access_token": "secret-token", "refresh_token": "secret-refresh", "expires_in": 3600}`)
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/api/v1/access_token", nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	response, err := NewRecorder(dir, http.DefaultClient).Do(request)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	response.Body.Close()
+
+	data, err := os.ReadFile(filepath.Join(dir, fixtureName(request)))
+	if err != nil {
+		t.Fatalf("fixture was not saved: %v", err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("fixture leaks a secret: %s", data)
+	}
+	if !strings.Contains(string(data), `\"access_token\": \"redacted\"`) {
+		t.Errorf("fixture does not redact the access token: %s", data)
+	}
+}
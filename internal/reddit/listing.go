@@ -0,0 +1,138 @@
+package reddit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Listing is the sort order of a subreddit listing
+type Listing string
+
+const (
+	ListingHot           Listing = "hot"
+	ListingNew           Listing = "new"
+	ListingRising        Listing = "rising"
+	ListingTop           Listing = "top"
+	ListingControversial Listing = "controversial"
+)
+
+// Listings is every listing type Reddit supports
+var Listings = []Listing{ListingHot, ListingNew, ListingRising, ListingTop, ListingControversial}
+
+// ParseListing parses a listing type case-insensitively
+func ParseListing(s string) (Listing, error) {
+	listing := Listing(strings.ToLower(strings.TrimSpace(s)))
+	for _, valid := range Listings {
+		if listing == valid {
+			return listing, nil
+		}
+	}
+	return "", fmt.Errorf("invalid listing %q, must be one of %s", s, joinEnum(Listings))
+}
+
+// HasTimeWindow reports whether the listing is restricted to a time window
+func (l Listing) HasTimeWindow() bool {
+	return l == ListingTop || l == ListingControversial
+}
+
+// UnmarshalText validates a listing while decoding it, leaving empty values unset
+func (l *Listing) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*l = ""
+		return nil
+	}
+	listing, err := ParseListing(string(text))
+	if err != nil {
+		return err
+	}
+	*l = listing
+	return nil
+}
+
+// TimeWindow is the period a top or controversial listing covers
+type TimeWindow string
+
+const (
+	TimeWindowHour  TimeWindow = "hour"
+	TimeWindowDay   TimeWindow = "day"
+	TimeWindowWeek  TimeWindow = "week"
+	TimeWindowMonth TimeWindow = "month"
+	TimeWindowYear  TimeWindow = "year"
+	TimeWindowAll   TimeWindow = "all"
+)
+
+// TimeWindows is every time window Reddit supports
+var TimeWindows = []TimeWindow{TimeWindowHour, TimeWindowDay, TimeWindowWeek, TimeWindowMonth, TimeWindowYear, TimeWindowAll}
+
+// ParseTimeWindow parses a time window case-insensitively
+func ParseTimeWindow(s string) (TimeWindow, error) {
+	window := TimeWindow(strings.ToLower(strings.TrimSpace(s)))
+	for _, valid := range TimeWindows {
+		if window == valid {
+			return window, nil
+		}
+	}
+	return "", fmt.Errorf("invalid time window %q, must be one of %s", s, joinEnum(TimeWindows))
+}
+
+// UnmarshalText validates a time window while decoding it, leaving empty values unset
+func (w *TimeWindow) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*w = ""
+		return nil
+	}
+	window, err := ParseTimeWindow(string(text))
+	if err != nil {
+		return err
+	}
+	*w = window
+	return nil
+}
+
+// SearchSort is the sort order of search results
+type SearchSort string
+
+const (
+	SearchSortRelevance SearchSort = "relevance"
+	SearchSortHot       SearchSort = "hot"
+	SearchSortTop       SearchSort = "top"
+	SearchSortNew       SearchSort = "new"
+	SearchSortComments  SearchSort = "comments"
+)
+
+// SearchSorts is every search sort order Reddit supports
+var SearchSorts = []SearchSort{SearchSortRelevance, SearchSortHot, SearchSortTop, SearchSortNew, SearchSortComments}
+
+// ParseSearchSort parses a search sort order case-insensitively
+func ParseSearchSort(s string) (SearchSort, error) {
+	sort := SearchSort(strings.ToLower(strings.TrimSpace(s)))
+	for _, valid := range SearchSorts {
+		if sort == valid {
+			return sort, nil
+		}
+	}
+	return "", fmt.Errorf("invalid search sort %q, must be one of %s", s, joinEnum(SearchSorts))
+}
+
+// UnmarshalText validates a search sort order while decoding it, leaving empty values unset
+func (s *SearchSort) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*s = ""
+		return nil
+	}
+	sort, err := ParseSearchSort(string(text))
+	if err != nil {
+		return err
+	}
+	*s = sort
+	return nil
+}
+
+// joinEnum formats enum values for error messages
+func joinEnum[T ~string](values []T) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = string(value)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,169 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxRateLimitWait is the longest a request waits for the budget to reset before failing
+	DefaultMaxRateLimitWait = 30 * time.Second
+	// DefaultMaxRetries is the number of times a 429 or 5xx response is retried
+	DefaultMaxRetries = 3
+	baseRetryBackoff  = 500 * time.Millisecond
+	maxRetryBackoff   = 30 * time.Second
+)
+
+// RateLimitError is returned when the request budget is exhausted and does not reset
+// within the limiter's maximum wait, or Reddit keeps answering with 429
+type RateLimitError struct {
+	ResetAt time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%v, resets at %s", ErrRateLimited, e.ResetAt.Format(time.RFC3339))
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// RateLimiter paces requests using the budget Reddit reports in the
+// X-Ratelimit-Remaining and X-Ratelimit-Reset response headers. A single
+// limiter can be shared by several clients using the same credentials.
+type RateLimiter struct {
+	maxWait time.Duration
+
+	mu sync.Mutex
+	// remaining is the number of requests left in the window, negative when unknown
+	remaining float64
+	resetAt   time.Time
+	// next is the earliest time the next request may be sent
+	next time.Time
+}
+
+// NewRateLimiter creates a limiter that waits at most maxWait for the budget to free up
+func NewRateLimiter(maxWait time.Duration) *RateLimiter {
+	return &RateLimiter{
+		maxWait:   maxWait,
+		remaining: -1,
+	}
+}
+
+// Wait blocks until a request may be sent, spreading the remaining budget evenly
+// over the rest of the window
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.remaining >= 0 && now.After(l.resetAt) {
+		l.remaining = -1
+	}
+
+	var sendAt time.Time
+	switch {
+	case l.remaining < 0:
+		sendAt = now
+	case l.remaining < 1:
+		sendAt = l.resetAt
+	default:
+		interval := time.Duration(float64(l.resetAt.Sub(now)) / l.remaining)
+		sendAt = l.next.Add(interval)
+		l.remaining--
+	}
+	if sendAt.Before(now) {
+		sendAt = now
+	}
+
+	delay := sendAt.Sub(now)
+	if delay > l.maxWait {
+		resetAt := l.resetAt
+		l.mu.Unlock()
+		return &RateLimitError{ResetAt: resetAt}
+	}
+	l.next = sendAt
+	l.mu.Unlock()
+
+	return sleep(ctx, delay)
+}
+
+// Update records the budget reported by a response
+func (l *RateLimiter) Update(header http.Header) {
+	remaining, err := strconv.ParseFloat(header.Get("X-Ratelimit-Remaining"), 64)
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseFloat(header.Get("X-Ratelimit-Reset"), 64)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.remaining = remaining
+	l.resetAt = time.Now().Add(time.Duration(reset * float64(time.Second)))
+}
+
+// limitedDoer paces every request through a RateLimiter
+type limitedDoer struct {
+	next    RequestDoer
+	limiter *RateLimiter
+}
+
+func (d limitedDoer) Do(request *http.Request) (*http.Response, error) {
+	if err := d.limiter.Wait(request.Context()); err != nil {
+		return nil, err
+	}
+	response, err := d.next.Do(request)
+	if err == nil {
+		d.limiter.Update(response.Header)
+	}
+	return response, err
+}
+
+// retryable reports whether a response status is worth retrying
+func retryable(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
+
+// retryBackoff returns the jittered exponential delay before a retry
+func retryBackoff(retry int) time.Duration {
+	backoff := min(baseRetryBackoff<<retry, maxRetryBackoff)
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date,
+// reporting whether the header held a delay at all. An explicit zero is a valid delay.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// sleep waits for the delay or until the context is done
+func sleep(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,255 @@
+package reddit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// scriptedResponse is a response of a scripted server, a listing when the status is 200
+type scriptedResponse struct {
+	status int
+	header map[string]string
+}
+
+// newScriptedServer answers the nth request with the nth response, repeating the last
+// one, and counts the requests it receives
+func newScriptedServer(t *testing.T, responses ...scriptedResponse) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		response := responses[min(n, len(responses))-1]
+		for name, value := range response.header {
+			w.Header().Set(name, value)
+		}
+		if response.status != http.StatusOK {
+			http.Error(w, http.StatusText(response.status), response.status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, listingBody)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func describe(client *Client) error {
+	_, err := client.DescribeSubreddit(context.Background(), "golang", ListingOptions{})
+	return err
+}
+
+func TestRateLimiterWaitsForReset(t *testing.T) {
+	server, requests := newScriptedServer(t, scriptedResponse{
+		status: http.StatusOK,
+		header: map[string]string{"X-Ratelimit-Remaining": "0", "X-Ratelimit-Reset": "0.3"},
+	})
+	client := NewClient("hecate-test", WithBaseURL(server.URL), WithRateLimiter(NewRateLimiter(time.Second)))
+
+	if err := describe(client); err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	start := time.Now()
+	if err := describe(client); err != nil {
+		t.Fatalf("second request: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("second request was sent after %s, want it to wait for the budget to reset", elapsed)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+}
+
+func TestRateLimiterSpreadsRemainingBudget(t *testing.T) {
+	server, _ := newScriptedServer(t, scriptedResponse{
+		status: http.StatusOK,
+		header: map[string]string{"X-Ratelimit-Remaining": "2", "X-Ratelimit-Reset": "0.4"},
+	})
+	client := NewClient("hecate-test", WithBaseURL(server.URL), WithRateLimiter(NewRateLimiter(time.Second)))
+
+	if err := describe(client); err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	start := time.Now()
+	if err := describe(client); err != nil {
+		t.Fatalf("second request: %v", err)
+	}
+	// Two requests left over 0.4s are sent about 0.2s apart
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond || elapsed > 350*time.Millisecond {
+		t.Errorf("second request was sent after %s, want about 200ms", elapsed)
+	}
+}
+
+func TestRateLimiterFailsWhenResetIsTooFar(t *testing.T) {
+	server, requests := newScriptedServer(t, scriptedResponse{
+		status: http.StatusOK,
+		header: map[string]string{"X-Ratelimit-Remaining": "0", "X-Ratelimit-Reset": "600"},
+	})
+	client := NewClient("hecate-test", WithBaseURL(server.URL), WithRateLimiter(NewRateLimiter(time.Second)))
+
+	if err := describe(client); err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	err := describe(client)
+	var rateLimitErr *RateLimitError
+	if !errors.As(err, &rateLimitErr) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("got %v, want a RateLimitError", err)
+	}
+	if until := time.Until(rateLimitErr.ResetAt); until < 590*time.Second || until > 600*time.Second {
+		t.Errorf("budget resets in %s, want about 600s", until)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("got %d requests, want the throttled request not to be sent", got)
+	}
+}
+
+func TestRateLimiterIsSharedBetweenClients(t *testing.T) {
+	server, requests := newScriptedServer(t, scriptedResponse{
+		status: http.StatusOK,
+		header: map[string]string{"X-Ratelimit-Remaining": "0", "X-Ratelimit-Reset": "600"},
+	})
+	limiter := NewRateLimiter(time.Second)
+	first := NewClient("hecate-test", WithBaseURL(server.URL), WithRateLimiter(limiter))
+	second := NewClient("hecate-test", WithBaseURL(server.URL), WithRateLimiter(limiter))
+
+	if err := describe(first); err != nil {
+		t.Fatalf("first client: %v", err)
+	}
+	if err := describe(second); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("second client got %v, want the budget spent by the first client to be exhausted", err)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("got %d requests, want 1", got)
+	}
+}
+
+func TestTooManyRequestsHonorsRetryAfter(t *testing.T) {
+	server, requests := newScriptedServer(t,
+		scriptedResponse{status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": "1"}},
+		scriptedResponse{status: http.StatusOK},
+	)
+	client := NewClient("hecate-test", WithBaseURL(server.URL))
+
+	start := time.Now()
+	if err := describe(client); err != nil {
+		t.Fatalf("DescribeSubreddit: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want the 1s Retry-After to be honored", elapsed)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+}
+
+func TestZeroRetryAfterRetriesImmediately(t *testing.T) {
+	server, requests := newScriptedServer(t,
+		scriptedResponse{status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": "0"}},
+		scriptedResponse{status: http.StatusOK},
+	)
+	client := NewClient("hecate-test", WithBaseURL(server.URL))
+
+	start := time.Now()
+	if err := describe(client); err != nil {
+		t.Fatalf("DescribeSubreddit: %v", err)
+	}
+	// Without Retry-After the first retry backs off for at least half of baseRetryBackoff
+	if elapsed := time.Since(start); elapsed >= baseRetryBackoff/2 {
+		t.Errorf("retried after %s, want Retry-After: 0 to retry without backing off", elapsed)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+}
+
+func TestTooManyRequestsReturnsRateLimitError(t *testing.T) {
+	t.Run("retries exhausted", func(t *testing.T) {
+		server, requests := newScriptedServer(t,
+			scriptedResponse{status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": "0"}})
+		client := NewClient("hecate-test", WithBaseURL(server.URL), WithMaxRetries(2))
+
+		err := describe(client)
+		var rateLimitErr *RateLimitError
+		if !errors.As(err, &rateLimitErr) {
+			t.Fatalf("got %v, want a RateLimitError", err)
+		}
+		if got := requests.Load(); got != 3 {
+			t.Errorf("got %d requests, want 1 and 2 retries", got)
+		}
+	})
+
+	t.Run("retry after beyond the maximum wait", func(t *testing.T) {
+		server, requests := newScriptedServer(t,
+			scriptedResponse{status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": "120"}})
+		client := NewClient("hecate-test", WithBaseURL(server.URL), WithRateLimiter(NewRateLimiter(time.Second)))
+
+		err := describe(client)
+		var rateLimitErr *RateLimitError
+		if !errors.As(err, &rateLimitErr) {
+			t.Fatalf("got %v, want a RateLimitError", err)
+		}
+		if until := time.Until(rateLimitErr.ResetAt); until < 110*time.Second || until > 120*time.Second {
+			t.Errorf("rate limit resets in %s, want about 120s", until)
+		}
+		if got := requests.Load(); got != 1 {
+			t.Errorf("got %d requests, want no retry", got)
+		}
+	})
+}
+
+func TestServerErrorsAreRetriedUpToMaxRetries(t *testing.T) {
+	server, requests := newScriptedServer(t,
+		scriptedResponse{status: http.StatusServiceUnavailable, header: map[string]string{"Retry-After": "0"}})
+	client := NewClient("hecate-test", WithBaseURL(server.URL), WithMaxRetries(2))
+
+	err := describe(client)
+	if !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Fatalf("got %v, want ErrUpstreamUnavailable", err)
+	}
+	var rateLimitErr *RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		t.Errorf("got a RateLimitError for a 503")
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("got %d requests, want 1 and 2 retries", got)
+	}
+}
+
+func TestClientErrorsAreNotRetried(t *testing.T) {
+	server, requests := newScriptedServer(t, scriptedResponse{status: http.StatusForbidden})
+	client := NewClient("hecate-test", WithBaseURL(server.URL))
+
+	if err := describe(client); err == nil {
+		t.Fatal("DescribeSubreddit succeeded on a 403")
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("got %d requests, want 1", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		delay time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{"5", 5 * time.Second, true},
+		{" 7 ", 7 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0, true},
+	}
+	for _, tt := range tests {
+		delay, ok := parseRetryAfter(tt.value)
+		if delay != tt.delay || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %s, %t, want %s, %t", tt.value, delay, ok, tt.delay, tt.ok)
+		}
+	}
+}
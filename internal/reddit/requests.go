@@ -3,9 +3,11 @@ package reddit
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 )
 
 const (
@@ -17,6 +19,28 @@ type RequestDoer interface {
 	Do(*http.Request) (*http.Response, error)
 }
 
+// statusError is returned when Reddit responds with a non-200 status code
+type statusError struct {
+	StatusCode int
+	URL        string
+	Body       string
+	// RetryAfter is the delay requested by the Retry-After header
+	RetryAfter time.Duration
+	// HasRetryAfter reports whether the response carried a Retry-After header, which
+	// may ask for no delay at all
+	HasRetryAfter bool
+	// Kind is the sentinel error the status maps to, nil when it has no specific meaning
+	Kind error
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d for %s, response: %s", e.StatusCode, e.URL, e.Body)
+}
+
+func (e *statusError) Unwrap() error {
+	return e.Kind
+}
+
 // truncateString truncates a string to a maximum length
 func truncateString(s string, maxLen int) string {
 	asRunes := []rune(s)
@@ -33,22 +57,33 @@ func decodeJSONFromRequest[T any](ctx context.Context, client RequestDoer, reque
 	request = request.WithContext(ctx)
 	response, err := client.Do(request)
 	if err != nil {
-		return result, fmt.Errorf("failed to send request: %w", err)
+		var rateLimitErr *RateLimitError
+		if ctx.Err() != nil || errors.As(err, &rateLimitErr) {
+			return result, fmt.Errorf("failed to send request: %w", err)
+		}
+		return result, fmt.Errorf("failed to send request: %w: %w", ErrUpstreamUnavailable, err)
 	}
 	defer response.Body.Close()
 
+	if redirectedToSearch(request, response) {
+		return result, fmt.Errorf("%w: %s redirected to subreddit search", ErrNotFound, request.URL)
+	}
+
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
 		return result, fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	if response.StatusCode != http.StatusOK {
-		return result, fmt.Errorf(
-			"unexpected status code %d for %s, response: %s",
-			response.StatusCode,
-			request.URL,
-			truncateString(string(body), maxTruncateLength),
-		)
+		retryAfter, hasRetryAfter := parseRetryAfter(response.Header.Get("Retry-After"))
+		return result, &statusError{
+			StatusCode:    response.StatusCode,
+			URL:           request.URL.String(),
+			Body:          truncateString(string(body), maxTruncateLength),
+			RetryAfter:    retryAfter,
+			HasRetryAfter: hasRetryAfter,
+			Kind:          classifyStatus(response.StatusCode, body),
+		}
 	}
 
 	err = json.Unmarshal(body, &result)
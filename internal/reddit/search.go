@@ -0,0 +1,70 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// SearchOptions controls a search and how many results are fetched
+type SearchOptions struct {
+	// Subreddit restricts the search to a single subreddit when set
+	Subreddit string
+	// Sort is the sort order of the results, defaulting to relevance
+	Sort SearchSort
+	// TimeWindow limits results to a period, defaulting to all
+	TimeWindow TimeWindow
+	// Limit is the number of posts requested per page, capped at MaxPageSize
+	Limit int
+	// MaxPosts is the total number of posts to fetch across pages. Zero fetches a single page.
+	MaxPosts int
+}
+
+// Search runs a Reddit search query, across all of Reddit or restricted to opts.Subreddit
+func (c *Client) Search(ctx context.Context, query string, opts SearchOptions) (RedditPosts, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("search query cannot be empty")
+	}
+	if opts.Sort == "" {
+		opts.Sort = SearchSortRelevance
+	}
+	if opts.TimeWindow == "" {
+		opts.TimeWindow = TimeWindowAll
+	}
+
+	var err error
+	if opts.Sort, err = ParseSearchSort(string(opts.Sort)); err != nil {
+		return nil, err
+	}
+	if opts.TimeWindow, err = ParseTimeWindow(string(opts.TimeWindow)); err != nil {
+		return nil, err
+	}
+	if opts.Limit < 0 || opts.MaxPosts < 0 {
+		return nil, fmt.Errorf("limit and max posts cannot be negative")
+	}
+
+	path := "/search.json"
+	values := url.Values{
+		"q":    {query},
+		"sort": {string(opts.Sort)},
+		"t":    {string(opts.TimeWindow)},
+		"type": {"link"},
+	}
+	if opts.Subreddit != "" {
+		path = fmt.Sprintf("/r/%s/search.json", url.PathEscape(opts.Subreddit))
+		values.Set("restrict_sr", "1")
+	}
+
+	children, err := c.fetchListing(ctx, path, values, ListingOptions{Limit: opts.Limit, MaxPosts: opts.MaxPosts})
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make(RedditPosts, 0, len(children))
+	for _, post := range children {
+		posts = append(posts, post.toRedditPost())
+	}
+	return posts, nil
+}
@@ -0,0 +1,64 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// usernamePattern matches the names Reddit allows for user accounts
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,20}$`)
+
+// NormalizeUsername strips a u/ or user/ prefix and surrounding whitespace from a
+// username and checks that Reddit would accept it
+func NormalizeUsername(name string) (string, error) {
+	normalized := strings.TrimSpace(name)
+	normalized = strings.TrimPrefix(normalized, "/")
+	for _, prefix := range []string{"user/", "u/"} {
+		if len(normalized) > len(prefix) && strings.EqualFold(normalized[:len(prefix)], prefix) {
+			normalized = normalized[len(prefix):]
+			break
+		}
+	}
+	normalized = strings.TrimSuffix(normalized, "/")
+
+	if !usernamePattern.MatchString(normalized) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidUsername, name)
+	}
+	return normalized, nil
+}
+
+// DescribeUser fetches the posts a user submitted. The listing is sent as the sort
+// order of the user's submissions; rising is not available for users.
+func (c *Client) DescribeUser(ctx context.Context, username string, opts ListingOptions) (RedditPosts, error) {
+	if username == "" {
+		return nil, fmt.Errorf("username cannot be empty")
+	}
+	opts, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Listing == ListingRising {
+		return nil, fmt.Errorf("listing %s is not available for users", opts.Listing)
+	}
+
+	path := fmt.Sprintf("/user/%s/submitted.json", url.PathEscape(username))
+	query := url.Values{}
+	query.Set("sort", string(opts.Listing))
+	if opts.Listing.HasTimeWindow() {
+		query.Set("t", string(opts.TimeWindow))
+	}
+
+	children, err := c.fetchListing(ctx, path, query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make(RedditPosts, 0, len(children))
+	for _, post := range children {
+		posts = append(posts, post.toRedditPost())
+	}
+	return posts, nil
+}
@@ -0,0 +1,238 @@
+package searchquery
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Dialect is the SQL dialect a query is compiled to
+type Dialect int
+
+const (
+	// SQLite matches text against the posts_fts FTS5 table
+	SQLite Dialect = iota
+	// PostgreSQL matches text against the search_vector tsvector column of posts
+	PostgreSQL
+	// SQLiteLike matches text with LIKE, for SQLite builds without FTS5
+	SQLiteLike
+)
+
+// sqliteDateTime is the layout of SQLite's datetime(), which after: and before: compare against
+const sqliteDateTime = "2006-01-02 15:04:05"
+
+// fieldColumns are the posts columns filtered by each field. Nullable columns are coalesced
+// so excluding a value keeps the posts that have none.
+var fieldColumns = map[Dialect]map[string]string{
+	SQLite: {
+		FieldSubreddit: "p.subreddit_name",
+		FieldAuthor:    "COALESCE(p.author, '')",
+		FieldFlair:     "COALESCE(p.flair, '')",
+		FieldScore:     "COALESCE(p.upvotes, 0)",
+		FieldComments:  "COALESCE(p.comment_count, 0)",
+		FieldAfter:     "datetime(p.created_at)",
+		FieldBefore:    "datetime(p.created_at)",
+		FieldNsfw:      "COALESCE(p.nsfw, 0)",
+		FieldSelf:      "COALESCE(p.is_self, 0)",
+	},
+	PostgreSQL: {
+		FieldSubreddit: "p.subreddit_name",
+		FieldAuthor:    "COALESCE(p.author, '')",
+		FieldFlair:     "COALESCE(p.flair, '')",
+		FieldScore:     "COALESCE(p.upvotes, 0)",
+		FieldComments:  "COALESCE(p.comment_count, 0)",
+		FieldAfter:     "p.created_at",
+		FieldBefore:    "p.created_at",
+		FieldNsfw:      "COALESCE(p.nsfw, FALSE)",
+		FieldSelf:      "COALESCE(p.is_self, FALSE)",
+	},
+}
+
+func init() {
+	fieldColumns[SQLiteLike] = fieldColumns[SQLite]
+}
+
+// Compiled is a query compiled to SQL
+type Compiled struct {
+	// Where is a condition on the posts table aliased p, using numbered placeholders
+	Where string
+	// Args are the values of the placeholders in Where, in order
+	Args []any
+}
+
+// compiler numbers placeholders while walking the query
+type compiler struct {
+	dialect Dialect
+	next    int
+	args    []any
+}
+
+// param binds a value and returns its placeholder
+func (c *compiler) param(value any) string {
+	c.args = append(c.args, value)
+	placeholder := fmt.Sprintf("$%d", c.next)
+	c.next++
+	return placeholder
+}
+
+// Compile compiles the query to SQL whose placeholders are numbered from firstParam, so
+// the condition can follow placeholders the surrounding statement already uses.
+// Values are always bound as arguments, never spliced into the SQL.
+func (q *Query) Compile(dialect Dialect, firstParam int) Compiled {
+	c := &compiler{dialect: dialect, next: firstParam}
+	where := q.root.sql(c)
+	return Compiled{Where: where, Args: c.args}
+}
+
+// Match returns a full-text query matching any of the text the query looks for, to rank
+// posts and build snippets with: an FTS5 expression on SQLite and to_tsquery input on
+// PostgreSQL. It is empty when the query only filters or excludes, and with SQLiteLike,
+// which has no full-text queries.
+func (q *Query) Match(dialect Dialect) string {
+	var matches []string
+	for _, text := range collectText(q.root, nil) {
+		if expression := text.expression(dialect); expression != "" {
+			matches = append(matches, expression)
+		}
+	}
+	separator := " OR "
+	if dialect == PostgreSQL {
+		separator = " | "
+	}
+	return strings.Join(matches, separator)
+}
+
+// Terms returns the words and phrases the query looks for, without the excluded ones
+func (q *Query) Terms() []string {
+	var terms []string
+	for _, text := range collectText(q.root, nil) {
+		terms = append(terms, text.text)
+	}
+	return terms
+}
+
+// collectText gathers the text terms that are not excluded
+func collectText(n node, texts []textNode) []textNode {
+	switch n := n.(type) {
+	case andNode:
+		for _, operand := range n.operands {
+			texts = collectText(operand, texts)
+		}
+	case orNode:
+		for _, operand := range n.operands {
+			texts = collectText(operand, texts)
+		}
+	case textNode:
+		texts = append(texts, n)
+	}
+	return texts
+}
+
+// LikePattern returns a LIKE pattern matching text anywhere, with the LIKE wildcards in
+// text escaped by a backslash
+func LikePattern(text string) string {
+	return "%" + likeEscaper.Replace(text) + "%"
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (n andNode) sql(c *compiler) string {
+	return joinOperands(c, n.operands, " AND ")
+}
+
+func (n orNode) sql(c *compiler) string {
+	return joinOperands(c, n.operands, " OR ")
+}
+
+func joinOperands(c *compiler, operands []node, separator string) string {
+	conditions := make([]string, len(operands))
+	for i, operand := range operands {
+		conditions[i] = operand.sql(c)
+	}
+	return "(" + strings.Join(conditions, separator) + ")"
+}
+
+func (n notNode) sql(c *compiler) string {
+	return "NOT " + n.operand.sql(c)
+}
+
+func (n textNode) sql(c *compiler) string {
+	if c.dialect == SQLiteLike {
+		// Prefixes match as any other text, since the pattern matches anywhere in a word
+		pattern := c.param(LikePattern(n.text))
+		return "(p.title LIKE " + pattern + " ESCAPE '\\' OR COALESCE(p.content, '') LIKE " + pattern + " ESCAPE '\\')"
+	}
+	expression := n.expression(c.dialect)
+	if c.dialect == PostgreSQL {
+		if expression == "" {
+			// Text without any word, such as punctuation, matches nothing
+			return "(FALSE)"
+		}
+		return "(p.search_vector @@ to_tsquery('english', " + c.param(expression) + "))"
+	}
+	return "(p.id IN (SELECT rowid FROM posts_fts WHERE posts_fts MATCH " + c.param(expression) + "))"
+}
+
+// expression is the full-text expression of the text in a dialect
+func (n textNode) expression(dialect Dialect) string {
+	switch dialect {
+	case PostgreSQL:
+		return n.tsqueryExpression()
+	case SQLiteLike:
+		return ""
+	default:
+		return n.ftsExpression()
+	}
+}
+
+// ftsExpression quotes the text as an FTS5 string, so operators and punctuation in it
+// are matched literally
+func (n textNode) ftsExpression() string {
+	expression := `"` + strings.ReplaceAll(n.text, `"`, `""`) + `"`
+	if n.prefix {
+		expression += "*"
+	}
+	return expression
+}
+
+// tsqueryExpression turns the text into to_tsquery input matching its words in order.
+// Only letters and digits are kept, so operators in the text cannot change the query.
+func (n textNode) tsqueryExpression() string {
+	words := strings.FieldsFunc(n.text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return ""
+	}
+	for i, word := range words {
+		words[i] = "'" + word + "'"
+	}
+	if n.prefix {
+		words[len(words)-1] += ":*"
+	}
+	return strings.Join(words, " <-> ")
+}
+
+func (n fieldNode) sql(c *compiler) string {
+	column := fieldColumns[c.dialect][n.field]
+	switch n.field {
+	case FieldSubreddit, FieldAuthor, FieldFlair:
+		if c.dialect == PostgreSQL {
+			return "(LOWER(" + column + ") = LOWER(" + c.param(n.value) + "))"
+		}
+		return "(" + column + " = " + c.param(n.value) + " COLLATE NOCASE)"
+	case FieldAfter, FieldBefore:
+		op := ">="
+		if n.field == FieldBefore {
+			op = "<"
+		}
+		date := n.value.(time.Time)
+		if c.dialect == PostgreSQL {
+			return "(" + column + " " + op + " " + c.param(date) + ")"
+		}
+		return "(" + column + " " + op + " " + c.param(date.Format(sqliteDateTime)) + ")"
+	default:
+		return "(" + column + " " + n.op + " " + c.param(n.value) + ")"
+	}
+}
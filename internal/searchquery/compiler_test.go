@@ -0,0 +1,155 @@
+package searchquery
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompile(t *testing.T) {
+	const (
+		ftsKyoto  = "(p.id IN (SELECT rowid FROM posts_fts WHERE posts_fts MATCH $1))"
+		likeTokyo = `(p.title LIKE $4 ESCAPE '\' OR COALESCE(p.content, '') LIKE $4 ESCAPE '\')`
+		likeOsaka = `(p.title LIKE $5 ESCAPE '\' OR COALESCE(p.content, '') LIKE $5 ESCAPE '\')`
+	)
+	newYear := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	february := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		query      string
+		dialect    Dialect
+		firstParam int
+		where      string
+		args       []any
+	}{
+		{
+			name: "fts word", query: "kyoto", dialect: SQLite, firstParam: 1,
+			where: ftsKyoto,
+			args:  []any{`"kyoto"`},
+		},
+		{
+			name: "fts phrase and prefix", query: `"trip report" temple*`, dialect: SQLite, firstParam: 1,
+			where: "((p.id IN (SELECT rowid FROM posts_fts WHERE posts_fts MATCH $1)) AND (p.id IN (SELECT rowid FROM posts_fts WHERE posts_fts MATCH $2)))",
+			args:  []any{`"trip report"`, `"temple"*`},
+		},
+		{
+			name: "fts operators are quoted", query: `"NEAR kyoto"`, dialect: SQLite, firstParam: 1,
+			where: ftsKyoto,
+			args:  []any{`"NEAR kyoto"`},
+		},
+		{
+			name: "tsquery phrase and prefix", query: `"trip report" temple*`, dialect: PostgreSQL, firstParam: 3,
+			where: "((p.search_vector @@ to_tsquery('english', $3)) AND (p.search_vector @@ to_tsquery('english', $4)))",
+			args:  []any{"'trip' <-> 'report'", "'temple':*"},
+		},
+		{
+			name: "tsquery drops punctuation", query: `"it's | !kyoto"`, dialect: PostgreSQL, firstParam: 1,
+			where: "(p.search_vector @@ to_tsquery('english', $1))",
+			args:  []any{"'it' <-> 's' <-> 'kyoto'"},
+		},
+		{
+			name: "tsquery without words", query: "++", dialect: PostgreSQL, firstParam: 1,
+			where: "(FALSE)",
+		},
+		{
+			name: "like word", query: "kyoto", dialect: SQLiteLike, firstParam: 2,
+			where: `(p.title LIKE $2 ESCAPE '\' OR COALESCE(p.content, '') LIKE $2 ESCAPE '\')`,
+			args:  []any{"%kyoto%"},
+		},
+		{
+			name: "like escapes wildcards", query: `100%_done`, dialect: SQLiteLike, firstParam: 1,
+			where: `(p.title LIKE $1 ESCAPE '\' OR COALESCE(p.content, '') LIKE $1 ESCAPE '\')`,
+			args:  []any{`%100\%\_done%`},
+		},
+		{
+			name: "like OR group and score", query: "(tokyo OR osaka) score:>=50", dialect: SQLiteLike, firstParam: 4,
+			where: "((" + likeTokyo + " OR " + likeOsaka + ") AND (COALESCE(p.upvotes, 0) >= $6))",
+			args:  []any{"%tokyo%", "%osaka%", 50},
+		},
+		{
+			name: "sqlite excluded and text fields", query: "-flair:question subreddit:r/JapanTravel author:u/spez", dialect: SQLite, firstParam: 1,
+			where: "(NOT (COALESCE(p.flair, '') = $1 COLLATE NOCASE) AND (p.subreddit_name = $2 COLLATE NOCASE) AND (COALESCE(p.author, '') = $3 COLLATE NOCASE))",
+			args:  []any{"question", "JapanTravel", "spez"},
+		},
+		{
+			name: "postgres excluded and text fields", query: "-flair:question subreddit:r/JapanTravel author:u/spez", dialect: PostgreSQL, firstParam: 2,
+			where: "(NOT (LOWER(COALESCE(p.flair, '')) = LOWER($2)) AND (LOWER(p.subreddit_name) = LOWER($3)) AND (LOWER(COALESCE(p.author, '')) = LOWER($4)))",
+			args:  []any{"question", "JapanTravel", "spez"},
+		},
+		{
+			name: "sqlite dates", query: "after:2025-01-01 before:2025-02-01", dialect: SQLite, firstParam: 1,
+			where: "((datetime(p.created_at) >= $1) AND (datetime(p.created_at) < $2))",
+			args:  []any{"2025-01-01 00:00:00", "2025-02-01 00:00:00"},
+		},
+		{
+			name: "postgres dates", query: "after:2025-01-01 before:2025-02-01", dialect: PostgreSQL, firstParam: 1,
+			where: "((p.created_at >= $1) AND (p.created_at < $2))",
+			args:  []any{newYear, february},
+		},
+		{
+			name: "sqlite flags and comments", query: "nsfw:false self:yes comments:<10", dialect: SQLite, firstParam: 5,
+			where: "((COALESCE(p.nsfw, 0) = $5) AND (COALESCE(p.is_self, 0) = $6) AND (COALESCE(p.comment_count, 0) < $7))",
+			args:  []any{false, true, 10},
+		},
+		{
+			name: "postgres flags", query: "nsfw:false self:yes", dialect: PostgreSQL, firstParam: 1,
+			where: "((COALESCE(p.nsfw, FALSE) = $1) AND (COALESCE(p.is_self, FALSE) = $2))",
+			args:  []any{false, true},
+		},
+		{
+			name: "mixed query", query: "kyoto -subreddit:japan (tokyo OR osaka*) score:>=50", dialect: PostgreSQL, firstParam: 3,
+			where: "((p.search_vector @@ to_tsquery('english', $3)) AND NOT (LOWER(p.subreddit_name) = LOWER($4)) AND " +
+				"((p.search_vector @@ to_tsquery('english', $5)) OR (p.search_vector @@ to_tsquery('english', $6))) AND (COALESCE(p.upvotes, 0) >= $7))",
+			args: []any{"'kyoto'", "japan", "'tokyo'", "'osaka':*", 50},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.query, err)
+			}
+			compiled := query.Compile(tt.dialect, tt.firstParam)
+			if compiled.Where != tt.where {
+				t.Errorf("Where = %s\nwant    %s", compiled.Where, tt.where)
+			}
+			if !reflect.DeepEqual(compiled.Args, tt.args) {
+				t.Errorf("Args = %#v, want %#v", compiled.Args, tt.args)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		query   string
+		dialect Dialect
+		want    string
+	}{
+		{query: `kyoto "trip report" temple* -osaka`, dialect: SQLite, want: `"kyoto" OR "trip report" OR "temple"*`},
+		{query: `kyoto "trip report" temple* -osaka`, dialect: PostgreSQL, want: `'kyoto' | 'trip' <-> 'report' | 'temple':*`},
+		{query: `kyoto "trip report" temple* -osaka`, dialect: SQLiteLike, want: ""},
+		{query: "subreddit:golang -kyoto", dialect: SQLite, want: ""},
+	}
+	for _, tt := range tests {
+		query, err := Parse(tt.query)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.query, err)
+		}
+		if got := query.Match(tt.dialect); got != tt.want {
+			t.Errorf("Match(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestTerms(t *testing.T) {
+	query, err := Parse(`kyoto (osaka OR "nara park") -tokyo flair:trip`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"kyoto", "osaka", "nara park"}
+	if got := query.Terms(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Terms() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,426 @@
+// Package searchquery parses the post search language and compiles it to parameterized
+// SQL. A query combines free text with field filters:
+//
+//	japan "trip report" kyoto*      every word, "phrase" and prefix* must match
+//	subreddit:japantravel           posts of a subreddit
+//	author:someone                  posts of an author
+//	flair:"trip report"             posts with a flair
+//	score:>500 comments:>=50        upvote and comment counts, with >, >=, <, <= or =
+//	after:2025-01-01                posts made on or after a date
+//	before:2025-02-01               posts made before a date
+//	nsfw:false self:true            NSFW and self posts
+//	-keyword -flair:question        exclusion
+//	(tokyo OR osaka) budget         OR groups, binding looser than the implicit AND
+//
+// The compiled SQL filters the posts table under the alias p and matches text against
+// the posts_fts full-text index on SQLite, or the search_vector column on PostgreSQL.
+package searchquery
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SyntaxError describes a malformed query and where the problem is
+type SyntaxError struct {
+	// Pos is the 1-based character position of the problem in the query
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+// dateLayout is the layout of after: and before: dates
+const dateLayout = "2006-01-02"
+
+// Field names accepted before a colon
+const (
+	FieldSubreddit = "subreddit"
+	FieldAuthor    = "author"
+	FieldFlair     = "flair"
+	FieldScore     = "score"
+	FieldComments  = "comments"
+	FieldAfter     = "after"
+	FieldBefore    = "before"
+	FieldNsfw      = "nsfw"
+	FieldSelf      = "self"
+)
+
+var fieldNames = []string{FieldSubreddit, FieldAuthor, FieldFlair, FieldScore, FieldComments, FieldAfter, FieldBefore, FieldNsfw, FieldSelf}
+
+// Query is a parsed search query
+type Query struct {
+	root node
+}
+
+// Parse parses a search query, returning a *SyntaxError when it is malformed
+func Parse(input string) (*Query, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, &SyntaxError{Pos: 1, Msg: "the query is empty"}
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind == tokenRParen {
+		return nil, &SyntaxError{Pos: tok.pos, Msg: "unexpected ')' without a matching '('"}
+	}
+	return &Query{root: root}, nil
+}
+
+// node is an expression of the query
+type node interface {
+	sql(c *compiler) string
+}
+
+type (
+	andNode struct{ operands []node }
+	orNode  struct{ operands []node }
+	notNode struct{ operand node }
+	// textNode matches a word or phrase in post titles and bodies
+	textNode struct {
+		text   string
+		prefix bool
+	}
+	// fieldNode filters posts on a column
+	fieldNode struct {
+		field string
+		op    string
+		value any
+	}
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenPhrase
+	tokenField
+	tokenMinus
+	tokenLParen
+	tokenRParen
+	tokenOr
+	tokenAnd
+)
+
+type token struct {
+	kind tokenKind
+	// text is the word, the phrase without quotes or the field name
+	text string
+	// value is the raw value of a field, quoted reports whether it was a phrase
+	value  string
+	quoted bool
+	prefix bool
+	pos    int
+	// valuePos is the position of a field value
+	valuePos int
+}
+
+// lex splits a query into tokens. Positions are 1-based character offsets.
+func lex(input string) ([]token, error) {
+	runes := []rune(input)
+	var tokens []token
+
+	// readPhrase reads a quoted phrase starting at the quote at i
+	readPhrase := func(i int) (string, int, error) {
+		end := i + 1
+		for end < len(runes) && runes[end] != '"' {
+			end++
+		}
+		if end == len(runes) {
+			return "", 0, &SyntaxError{Pos: i + 1, Msg: `unterminated quote, add a closing "`}
+		}
+		return string(runes[i+1 : end]), end + 1, nil
+	}
+	// readWord reads up to the next space, parenthesis or quote
+	readWord := func(i int) (string, int) {
+		end := i
+		for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune(`()"`, runes[end]) {
+			end++
+		}
+		return string(runes[i:end]), end
+	}
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, pos: i + 1})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, pos: i + 1})
+			i++
+		case r == '"':
+			phrase, end, err := readPhrase(i)
+			if err != nil {
+				return nil, err
+			}
+			tok := token{kind: tokenPhrase, text: phrase, pos: i + 1}
+			if end < len(runes) && runes[end] == '*' {
+				tok.prefix = true
+				end++
+			}
+			tokens = append(tokens, tok)
+			i = end
+		case r == '-':
+			if i+1 == len(runes) || unicode.IsSpace(runes[i+1]) || runes[i+1] == ')' {
+				return nil, &SyntaxError{Pos: i + 1, Msg: "'-' must be followed by the term to exclude"}
+			}
+			tokens = append(tokens, token{kind: tokenMinus, pos: i + 1})
+			i++
+		default:
+			word, end := readWord(i)
+			tok := token{kind: tokenWord, text: word, pos: i + 1}
+
+			if colon := strings.IndexRune(word, ':'); colon > 0 && isFieldName(word[:colon]) {
+				tok.kind = tokenField
+				tok.text = strings.ToLower(word[:colon])
+				tok.value = word[colon+1:]
+				tok.valuePos = i + len([]rune(word[:colon])) + 2
+				if tok.value == "" && end < len(runes) && runes[end] == '"' {
+					phrase, phraseEnd, err := readPhrase(end)
+					if err != nil {
+						return nil, err
+					}
+					tok.value, tok.quoted = phrase, true
+					end = phraseEnd
+				}
+				if tok.value == "" {
+					return nil, &SyntaxError{Pos: tok.valuePos, Msg: fmt.Sprintf("%s: needs a value", tok.text)}
+				}
+			} else if colon > 0 && isLetters(word[:colon]) && !strings.HasPrefix(word[colon+1:], "//") {
+				// Anything else before a colon is a mistyped field, except a URL scheme
+				return nil, &SyntaxError{Pos: i + 1, Msg: fmt.Sprintf("unknown field %q, use one of %s or quote the text", word[:colon], strings.Join(fieldNames, ", "))}
+			} else if word == "OR" {
+				tok.kind = tokenOr
+			} else if word == "AND" {
+				tok.kind = tokenAnd
+			} else if strings.HasSuffix(word, "*") {
+				tok.text = strings.TrimRight(word, "*")
+				tok.prefix = true
+				if tok.text == "" {
+					return nil, &SyntaxError{Pos: i + 1, Msg: "a prefix search needs at least one character before '*'"}
+				}
+			}
+
+			tokens = append(tokens, tok)
+			i = end
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(runes) + 1}), nil
+}
+
+// isFieldName reports whether a name before a colon is a field
+func isFieldName(name string) bool {
+	for _, field := range fieldNames {
+		if strings.EqualFold(name, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLetters reports whether a string is only letters, so it reads like a mistyped field
+func isLetters(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// parser is a recursive descent parser over the grammar
+//
+//	or    = and { "OR" and }
+//	and   = unary { ["AND"] unary }
+//	unary = "-" unary | "(" or ")" | field | word | phrase
+type parser struct {
+	tokens []token
+	i      int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.i]
+	if tok.kind != tokenEOF {
+		p.i++
+	}
+	return tok
+}
+
+func (p *parser) parseOr() (node, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	operands := []node{first}
+	for p.peek().kind == tokenOr {
+		p.next()
+		operand, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return orNode{operands: operands}, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	var operands []node
+	for {
+		tok := p.peek()
+		switch tok.kind {
+		case tokenEOF, tokenRParen, tokenOr:
+			if len(operands) == 0 {
+				return nil, p.missingOperand(tok)
+			}
+			if len(operands) == 1 {
+				return operands[0], nil
+			}
+			return andNode{operands: operands}, nil
+		case tokenAnd:
+			if len(operands) == 0 {
+				return nil, &SyntaxError{Pos: tok.pos, Msg: "AND needs a term on both sides"}
+			}
+			p.next()
+			if next := p.peek(); next.kind == tokenEOF || next.kind == tokenRParen || next.kind == tokenOr || next.kind == tokenAnd {
+				return nil, &SyntaxError{Pos: tok.pos, Msg: "AND needs a term on both sides"}
+			}
+		}
+
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+}
+
+// missingOperand explains why a term was expected but tok was found
+func (p *parser) missingOperand(tok token) error {
+	if p.i > 0 && p.tokens[p.i-1].kind == tokenOr {
+		return &SyntaxError{Pos: p.tokens[p.i-1].pos, Msg: "OR needs a term on both sides"}
+	}
+	switch tok.kind {
+	case tokenOr:
+		return &SyntaxError{Pos: tok.pos, Msg: "OR needs a term on both sides"}
+	case tokenRParen:
+		if p.i > 0 && p.tokens[p.i-1].kind == tokenLParen {
+			return &SyntaxError{Pos: p.tokens[p.i-1].pos, Msg: "empty parentheses"}
+		}
+		return &SyntaxError{Pos: tok.pos, Msg: "unexpected ')' without a matching '('"}
+	default:
+		if p.i > 0 && p.tokens[p.i-1].kind == tokenLParen {
+			return &SyntaxError{Pos: p.tokens[p.i-1].pos, Msg: "'(' is never closed, add a ')'"}
+		}
+		return &SyntaxError{Pos: tok.pos, Msg: "expected a search term"}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenMinus:
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	case tokenLParen:
+		group, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenRParen {
+			return nil, &SyntaxError{Pos: tok.pos, Msg: "'(' is never closed, add a ')'"}
+		}
+		return group, nil
+	case tokenWord, tokenPhrase:
+		if strings.TrimSpace(tok.text) == "" {
+			return nil, &SyntaxError{Pos: tok.pos, Msg: "empty phrase"}
+		}
+		return textNode{text: tok.text, prefix: tok.prefix}, nil
+	case tokenField:
+		return parseField(tok)
+	case tokenRParen:
+		return nil, &SyntaxError{Pos: tok.pos, Msg: "unexpected ')' without a matching '('"}
+	default:
+		return nil, &SyntaxError{Pos: tok.pos, Msg: "expected a search term"}
+	}
+}
+
+// parseField validates the value of a field filter
+func parseField(tok token) (node, error) {
+	field := fieldNode{field: tok.text, op: "="}
+	fail := func(format string, args ...any) error {
+		return &SyntaxError{Pos: tok.valuePos, Msg: fmt.Sprintf(format, args...)}
+	}
+
+	switch tok.text {
+	case FieldSubreddit:
+		field.value = strings.TrimPrefix(strings.TrimPrefix(tok.value, "/"), "r/")
+	case FieldAuthor:
+		field.value = strings.TrimPrefix(strings.TrimPrefix(tok.value, "/"), "u/")
+	case FieldFlair:
+		field.value = tok.value
+	case FieldScore, FieldComments:
+		op, number := splitComparison(tok.value)
+		n, err := strconv.Atoi(number)
+		if tok.quoted || err != nil {
+			return nil, fail("%s: needs a number, optionally after >, >=, <, <= or =, such as %s:>=50", tok.text, tok.text)
+		}
+		field.op, field.value = op, n
+	case FieldAfter, FieldBefore:
+		date, err := time.Parse(dateLayout, tok.value)
+		if err != nil {
+			return nil, fail("%s: needs a date such as %s:2025-01-01", tok.text, tok.text)
+		}
+		field.value = date
+	case FieldNsfw, FieldSelf:
+		switch strings.ToLower(tok.value) {
+		case "true", "yes":
+			field.value = true
+		case "false", "no":
+			field.value = false
+		default:
+			return nil, fail("%s: needs true or false", tok.text)
+		}
+	}
+
+	if field.value == "" {
+		return nil, fail("%s: needs a value", tok.text)
+	}
+	return field, nil
+}
+
+// splitComparison splits a value such as >=50 into its operator and operand
+func splitComparison(value string) (string, string) {
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(value, op) {
+			return op, value[len(op):]
+		}
+	}
+	return "=", value
+}
@@ -0,0 +1,114 @@
+package searchquery
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  node
+	}{
+		{input: "kyoto", want: textNode{text: "kyoto"}},
+		{input: `kyoto "trip report" temple*`, want: andNode{operands: []node{
+			textNode{text: "kyoto"},
+			textNode{text: "trip report"},
+			textNode{text: "temple", prefix: true},
+		}}},
+		{input: `"ramen shop"*`, want: textNode{text: "ramen shop", prefix: true}},
+		{input: "-keyword", want: notNode{operand: textNode{text: "keyword"}}},
+		{input: "-flair:question", want: notNode{operand: fieldNode{field: FieldFlair, op: "=", value: "question"}}},
+		{input: "--spam", want: notNode{operand: notNode{operand: textNode{text: "spam"}}}},
+		{input: "(tokyo OR osaka) budget", want: andNode{operands: []node{
+			orNode{operands: []node{textNode{text: "tokyo"}, textNode{text: "osaka"}}},
+			textNode{text: "budget"},
+		}}},
+		// OR binds looser than the implicit AND
+		{input: "tokyo OR osaka budget", want: orNode{operands: []node{
+			textNode{text: "tokyo"},
+			andNode{operands: []node{textNode{text: "osaka"}, textNode{text: "budget"}}},
+		}}},
+		{input: "tokyo AND osaka", want: andNode{operands: []node{textNode{text: "tokyo"}, textNode{text: "osaka"}}}},
+		// Operators are only recognized in upper case
+		{input: "tokyo or osaka", want: andNode{operands: []node{
+			textNode{text: "tokyo"}, textNode{text: "or"}, textNode{text: "osaka"},
+		}}},
+		{input: "subreddit:r/JapanTravel", want: fieldNode{field: FieldSubreddit, op: "=", value: "JapanTravel"}},
+		{input: "author:/u/spez", want: fieldNode{field: FieldAuthor, op: "=", value: "spez"}},
+		{input: `flair:"trip report"`, want: fieldNode{field: FieldFlair, op: "=", value: "trip report"}},
+		{input: "score:>500", want: fieldNode{field: FieldScore, op: ">", value: 500}},
+		{input: "comments:>=50", want: fieldNode{field: FieldComments, op: ">=", value: 50}},
+		{input: "score:<=10", want: fieldNode{field: FieldScore, op: "<=", value: 10}},
+		{input: "score:7", want: fieldNode{field: FieldScore, op: "=", value: 7}},
+		{input: "after:2025-01-01", want: fieldNode{field: FieldAfter, op: "=", value: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{input: "before:2025-02-01", want: fieldNode{field: FieldBefore, op: "=", value: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)}},
+		{input: "nsfw:no", want: fieldNode{field: FieldNsfw, op: "=", value: false}},
+		{input: "SELF:True", want: fieldNode{field: FieldSelf, op: "=", value: true}},
+		// A URL scheme is not a field
+		{input: "https://example.com", want: textNode{text: "https://example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			query, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(query.root, tt.want) {
+				t.Errorf("Parse(%q) = %#v, want %#v", tt.input, query.root, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+		msg   string
+	}{
+		{input: "", pos: 1, msg: "the query is empty"},
+		{input: "   ", pos: 1, msg: "the query is empty"},
+		{input: `kyoto "trip report`, pos: 7, msg: `unterminated quote, add a closing "`},
+		{input: `flair:"trip report`, pos: 7, msg: `unterminated quote, add a closing "`},
+		{input: `kyoto ""`, pos: 7, msg: "empty phrase"},
+		{input: "kyoto -", pos: 7, msg: "'-' must be followed by the term to exclude"},
+		{input: "(kyoto -)", pos: 8, msg: "'-' must be followed by the term to exclude"},
+		{input: "title:kyoto", pos: 1, msg: `unknown field "title", use one of subreddit, author, flair, score, comments, after, before, nsfw, self or quote the text`},
+		{input: "kyoto score:", pos: 13, msg: "score: needs a value"},
+		{input: "score:many", pos: 7, msg: "score: needs a number, optionally after >, >=, <, <= or =, such as score:>=50"},
+		{input: `comments:"50"`, pos: 10, msg: "comments: needs a number, optionally after >, >=, <, <= or =, such as comments:>=50"},
+		{input: "after:yesterday", pos: 7, msg: "after: needs a date such as after:2025-01-01"},
+		{input: "nsfw:maybe", pos: 6, msg: "nsfw: needs true or false"},
+		{input: "subreddit:r/", pos: 11, msg: "subreddit: needs a value"},
+		{input: "kyoto *", pos: 7, msg: "a prefix search needs at least one character before '*'"},
+		{input: "(tokyo osaka", pos: 1, msg: "'(' is never closed, add a ')'"},
+		{input: "tokyo)", pos: 6, msg: "unexpected ')' without a matching '('"},
+		{input: "kyoto ()", pos: 7, msg: "empty parentheses"},
+		{input: "tokyo OR", pos: 7, msg: "OR needs a term on both sides"},
+		{input: "OR tokyo", pos: 1, msg: "OR needs a term on both sides"},
+		{input: "(tokyo OR) osaka", pos: 8, msg: "OR needs a term on both sides"},
+		{input: "AND tokyo", pos: 1, msg: "AND needs a term on both sides"},
+		{input: "tokyo AND", pos: 7, msg: "AND needs a term on both sides"},
+		// Positions count characters, not bytes
+		{input: "京都 -", pos: 4, msg: "'-' must be followed by the term to exclude"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parse(%q) returned %v, want a *SyntaxError", tt.input, err)
+			}
+			if syntaxErr.Pos != tt.pos || syntaxErr.Msg != tt.msg {
+				t.Errorf("Parse(%q) failed at %d with %q, want %d with %q", tt.input, syntaxErr.Pos, syntaxErr.Msg, tt.pos, tt.msg)
+			}
+			if !strings.HasPrefix(err.Error(), "position ") {
+				t.Errorf("error %q does not start with its position", err)
+			}
+		})
+	}
+}
@@ -4,30 +4,65 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
 )
 
-func respondWithError(w http.ResponseWriter, code int, msg string) {
-	if code > 499 {
-		log.Printf("Server returned 5xx error: %v", msg)
-	}
+// problemContentType is the media type of RFC 7807 problem details
+const problemContentType = "application/problem+json"
 
-	type errResponse struct {
-		Error string `json:"error"`
+// problem is an RFC 7807 problem details object. Code is a stable, machine-readable
+// error code clients can switch on, and RequestId matches the server's request logs.
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail"`
+	Instance  string `json:"instance"`
+	Code      string `json:"code"`
+	RequestId string `json:"requestId,omitempty"`
+}
+
+// respondWithError responds with a problem details object carrying a stable error code
+func respondWithError(w http.ResponseWriter, r *http.Request, status int, errorCode, detail string) {
+	if status > 499 {
+		log.Printf("Server returned 5xx error: %v", detail)
 	}
 
-	respondWithJson(w, code, errResponse{
-		Error: msg,
+	respond(w, status, problemContentType, problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      errorCode,
+		RequestId: middleware.GetReqID(r.Context()),
 	})
+}
 
+func respondWithJson(w http.ResponseWriter, status int, payload interface{}) {
+	respond(w, status, "application/json", payload)
 }
-func respondWithJson(w http.ResponseWriter, code int, payload interface{}) {
+
+// respond writes a JSON payload with the given status and content type
+func respond(w http.ResponseWriter, status int, contentType string, payload interface{}) {
 	data, err := json.Marshal(payload)
 	if err != nil {
 		log.Printf("Failed to marshal json payload %v", err)
-		w.WriteHeader(code)
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(200)
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
 	w.Write(data)
 }
+
+// notFoundHandler responds to requests for routes that do not exist
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	respondWithError(w, r, http.StatusNotFound, "route_not_found", "No route matches "+r.URL.Path)
+}
+
+// methodNotAllowedHandler responds to requests using a method a route does not handle
+func methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	respondWithError(w, r, http.StatusMethodNotAllowed, "method_not_allowed", r.Method+" is not allowed on "+r.URL.Path)
+}
@@ -9,51 +9,56 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/samratjha96/hecate/internal/database"
+	"github.com/samratjha96/hecate/internal/hecate"
+	"github.com/samratjha96/hecate/internal/reddit"
 )
 
 func main() {
-	db, err := database.NewDB()
+	db, err := openStore()
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
-	if err := db.CreateTables(); err != nil {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(db, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := db.Migrate(); err != nil {
 		log.Fatal(err)
 	}
 
-	r := chi.NewRouter()
+	openAPI, err := loadOpenAPI()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: false,
-		MaxAge:           300,
-	}))
+	redditClient := hecate.NewRedditClient()
 
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("OK"))
-	})
+	jobs, err := hecate.NewJobRunner(db, redditClient)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	r.Route("/api", func(r chi.Router) {
-		r.Route("/subreddits", func(r chi.Router) {
-			r.Get("/", subredditGetHandler(db))
-			r.Get("/search", searchPostsHandler(db))
-			r.Get("/{subredditName}", subredditPostsGetHandler(db))
-			r.Post("/ingest", ingestSubredditHandler(db))
-			r.Post("/ingest-all", ingestAllSubredditsHandler(db))
-		})
-	})
+	scheduler := hecate.NewScheduler(db, redditClient, hecate.ScheduleIntervalsFromEnv(), 0)
+	if hecate.SchedulerEnabled() {
+		scheduler.Start()
+	} else {
+		log.Println("Scheduler disabled by SCHEDULER_ENABLED")
+	}
+
+	r := newRouter(openAPI, db, redditClient, jobs, scheduler)
+	if err := checkOpenAPIRoutes(openAPI, r); err != nil {
+		log.Fatal(err)
+	}
 
 	port := os.Getenv("SERVER_PORT")
 	if port == "" {
@@ -88,5 +93,76 @@ func main() {
 		log.Fatal("Server forced to shutdown:", err)
 	}
 
+	if err := scheduler.Stop(ctx); err != nil {
+		log.Printf("Scheduler forced to stop: %v", err)
+	}
+	if err := jobs.Stop(ctx); err != nil {
+		log.Printf("Jobs forced to stop: %v", err)
+	}
+
 	log.Println("Server exiting")
 }
+
+// newRouter routes the API to its handlers, validating requests against the OpenAPI document
+func newRouter(openAPI *openapi3.T, db database.Store, redditClient *reddit.Client, jobs *hecate.JobRunner, scheduler *hecate.Scheduler) chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(middleware.RequestID)
+	r.Use(middleware.RealIP)
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(cors.Handler(cors.Options{
+		AllowedOrigins:   []string{"*"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		ExposedHeaders:   []string{"Link"},
+		AllowCredentials: false,
+		MaxAge:           300,
+	}))
+	r.Use(validateRequests(openAPI, r))
+
+	r.NotFound(notFoundHandler)
+	r.MethodNotAllowed(methodNotAllowedHandler)
+
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("OK"))
+	})
+
+	r.Route("/api", func(r chi.Router) {
+		r.Get("/openapi.json", openAPIHandler)
+		r.Route("/subreddits", func(r chi.Router) {
+			r.Get("/", subredditGetHandler(db))
+			r.Get("/search", searchPostsHandler(db))
+			r.Get("/{subredditName}", subredditPostsGetHandler(db))
+			r.Post("/ingest", ingestSubredditHandler(db, redditClient))
+			r.Post("/ingest-all", ingestAllSubredditsHandler(jobs))
+		})
+		r.Route("/searches", func(r chi.Router) {
+			r.Get("/", savedSearchesGetHandler(db))
+			r.Get("/{searchId}", savedSearchPostsGetHandler(db))
+			r.Post("/ingest", ingestSearchHandler(db, redditClient))
+		})
+		r.Route("/users", func(r chi.Router) {
+			r.Get("/", followedUsersGetHandler(db))
+			r.Get("/{username}", userPostsGetHandler(db))
+			r.Post("/ingest", ingestUserHandler(db, redditClient))
+		})
+		r.Route("/schedules", func(r chi.Router) {
+			r.Get("/", schedulesGetHandler(scheduler))
+			r.Post("/", scheduleConfigureHandler(scheduler))
+			r.Post("/{scheduleId}/pause", schedulePauseHandler(scheduler, true))
+			r.Post("/{scheduleId}/resume", schedulePauseHandler(scheduler, false))
+			r.Post("/{scheduleId}/trigger", scheduleTriggerHandler(scheduler))
+		})
+		r.Route("/jobs", func(r chi.Router) {
+			r.Get("/", jobsGetHandler(jobs))
+			r.Get("/{jobId}", jobGetHandler(jobs))
+			r.Delete("/{jobId}", jobCancelHandler(jobs))
+		})
+		r.Route("/posts", func(r chi.Router) {
+			r.Get("/{postId}/comments", postCommentsGetHandler(db))
+		})
+	})
+	return r
+}
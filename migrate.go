@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/samratjha96/hecate/internal/database"
+)
+
+const migrateUsage = "usage: hecate migrate status | up [steps] | down [steps]"
+
+// runMigrateCommand drives schema migrations by hand. up applies every pending
+// migration unless a number of steps is given, down reverts the last one by default.
+func runMigrateCommand(db database.Store, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return fmt.Errorf(migrateUsage)
+	}
+
+	steps := 0
+	if len(args) == 2 {
+		parsed, err := strconv.Atoi(args[1])
+		if err != nil || parsed < 1 {
+			return fmt.Errorf("steps must be a positive number\n%s", migrateUsage)
+		}
+		steps = parsed
+	}
+
+	switch args[0] {
+	case "status":
+		return printMigrationStatus(db)
+	case "up":
+		applied, err := db.MigrateUp(steps)
+		fmt.Printf("Applied %d migrations\n", applied)
+		return err
+	case "down":
+		if steps == 0 {
+			steps = 1
+		}
+		reverted, err := db.MigrateDown(steps)
+		fmt.Printf("Reverted %d migrations\n", reverted)
+		return err
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], migrateUsage)
+	}
+}
+
+// printMigrationStatus prints every migration and when it was applied
+func printMigrationStatus(db database.Store) error {
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/go-chi/chi/v5"
+)
+
+// openAPIDocument is the OpenAPI 3 description of every route, served as is
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// errorCodeExtension names the error code a parameter's validation errors are reported with
+const errorCodeExtension = "x-error-code"
+
+// loadOpenAPI parses the embedded OpenAPI document and checks that it is valid
+func loadOpenAPI() (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(openAPIDocument)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	return doc, nil
+}
+
+// openAPIHandler serves the OpenAPI document
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument)
+}
+
+// checkOpenAPIRoutes returns an error listing the routes the OpenAPI document does not
+// describe and the operations it describes that have no route, so the document cannot
+// drift from the router
+func checkOpenAPIRoutes(doc *openapi3.T, router chi.Routes) error {
+	documented := make(map[string]bool)
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented[method+" "+path] = true
+		}
+	}
+
+	var undocumented []string
+	err := chi.Walk(router, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+		operation := method + " " + route
+		if !documented[operation] {
+			undocumented = append(undocumented, operation)
+		}
+		delete(documented, operation)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to walk routes: %w", err)
+	}
+
+	var unrouted []string
+	for operation := range documented {
+		unrouted = append(unrouted, operation)
+	}
+	sort.Strings(undocumented)
+	sort.Strings(unrouted)
+
+	var problems []string
+	if len(undocumented) > 0 {
+		problems = append(problems, "routes missing from the OpenAPI document: "+strings.Join(undocumented, ", "))
+	}
+	if len(unrouted) > 0 {
+		problems = append(problems, "OpenAPI operations without a route: "+strings.Join(unrouted, ", "))
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateRequests rejects requests whose parameters or body do not match their
+// operation in the OpenAPI document with a 400. Operations are found by routing the
+// request with router, so the document and the handlers agree on which operation a
+// request is for. Requests without a route are passed on for the router to reject.
+func validateRequests(doc *openapi3.T, router chi.Routes) func(http.Handler) http.Handler {
+	// Handlers apply their own defaults, so requests reach them as they were sent
+	options := &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc, SkipSettingDefaults: true}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			input := openAPIRequest(doc, router, r)
+			if input == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			input.Options = options
+			if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+				errorCode, detail := requestErrorProblem(err)
+				respondWithError(w, r, statusBadReq, errorCode, detail)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// openAPIRequest finds the operation of a request by routing it with router, returning
+// nil when the request has no route or its route is not in the OpenAPI document
+func openAPIRequest(doc *openapi3.T, router chi.Routes, r *http.Request) *openapi3filter.RequestValidationInput {
+	rctx := chi.NewRouteContext()
+	if !router.Match(rctx, r.Method, r.URL.Path) {
+		return nil
+	}
+	path := rctx.RoutePattern()
+	item := doc.Paths.Value(path)
+	if item == nil || item.GetOperation(r.Method) == nil {
+		return nil
+	}
+
+	pathParams := make(map[string]string, len(rctx.URLParams.Keys))
+	for i, key := range rctx.URLParams.Keys {
+		pathParams[key] = rctx.URLParams.Values[i]
+	}
+	return &openapi3filter.RequestValidationInput{
+		Request:    r,
+		PathParams: pathParams,
+		Route: &routers.Route{
+			Spec:      doc,
+			Path:      path,
+			PathItem:  item,
+			Method:    r.Method,
+			Operation: item.GetOperation(r.Method),
+		},
+	}
+}
+
+// requestErrorProblem maps a request validation error to an error code and a detail
+// naming the parameter or the location in the body that is invalid
+func requestErrorProblem(err error) (string, string) {
+	var requestErr *openapi3filter.RequestError
+	if !errors.As(err, &requestErr) {
+		return "invalid_request", err.Error()
+	}
+
+	if param := requestErr.Parameter; param != nil {
+		errorCode := "invalid_parameter"
+		if code, ok := param.Extensions[errorCodeExtension].(string); ok {
+			errorCode = code
+		}
+		if errors.Is(requestErr.Err, openapi3filter.ErrInvalidRequired) {
+			return errorCode, fmt.Sprintf("Missing %s parameter %q", param.In, param.Name)
+		}
+		return errorCode, fmt.Sprintf("Invalid %s parameter %q: %s", param.In, param.Name, validationReason(requestErr))
+	}
+
+	if requestErr.RequestBody != nil {
+		if errors.Is(requestErr.Err, openapi3filter.ErrInvalidRequired) {
+			return "missing_body", "Request body is required"
+		}
+		var schemaErr *openapi3.SchemaError
+		if errors.As(requestErr.Err, &schemaErr) {
+			return "invalid_body", fmt.Sprintf("Request body at /%s: %s",
+				strings.Join(schemaErr.JSONPointer(), "/"), schemaErr.Reason)
+		}
+		return "invalid_body", fmt.Sprintf("Invalid request body: %s", validationReason(requestErr))
+	}
+	return "invalid_request", requestErr.Error()
+}
+
+// validationReason describes why a value failed validation, without the schema that
+// kin-openapi appends to its errors
+func validationReason(requestErr *openapi3filter.RequestError) string {
+	err := requestErr.Err
+	var schemaErr *openapi3.SchemaError
+	var parseErr *openapi3filter.ParseError
+	switch {
+	case err == nil:
+		return requestErr.Reason
+	case errors.As(err, &schemaErr):
+		return schemaErr.Reason
+	case errors.As(err, &parseErr) && parseErr.Reason != "":
+		return parseErr.Reason
+	case errors.As(err, &parseErr) && parseErr.Cause != nil:
+		return parseErr.Cause.Error()
+	default:
+		return err.Error()
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestOpenAPIRoutes(t *testing.T) {
+	api := newTestAPI(t)
+	if err := checkOpenAPIRoutes(api.openAPI, api.router); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// validateResponse checks a response against its operation in the OpenAPI document.
+// Requests without an operation, which the router rejects, are checked to get a Problem.
+func validateResponse(t *testing.T, api *testAPI, request *http.Request, response *httptest.ResponseRecorder) {
+	t.Helper()
+	input := openAPIRequest(api.openAPI, api.router, request)
+	if input == nil {
+		var body any
+		if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode %s: %v", response.Body, err)
+		}
+		if err := api.openAPI.Components.Schemas["Problem"].Value.VisitJSON(body); err != nil {
+			t.Errorf("response to an unrouted request is not a Problem: %v", err)
+		}
+		return
+	}
+
+	err := openapi3filter.ValidateResponse(request.Context(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 response.Code,
+		Header:                 response.Result().Header,
+		Body:                   io.NopCloser(bytes.NewReader(response.Body.Bytes())),
+		Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+	})
+	if err != nil {
+		t.Errorf("%d response does not match the OpenAPI document: %v\n%s", response.Code, err, response.Body)
+	}
+}
+
+func TestResponsesMatchOpenAPI(t *testing.T) {
+	// validate checks the status first, so responses are validated for the case they test
+	validate := func(api *testAPI) func(t *testing.T, tt routeRequest, request *http.Request, response *httptest.ResponseRecorder) {
+		return func(t *testing.T, tt routeRequest, request *http.Request, response *httptest.ResponseRecorder) {
+			if response.Code != tt.status {
+				t.Fatalf("got status %d, want %d: %s", response.Code, tt.status, response.Body)
+			}
+			validateResponse(t, api, request, response)
+		}
+	}
+
+	t.Run("routes", func(t *testing.T) {
+		api := newTestAPI(t)
+		runRequests(t, api, routeRequests, validate(api))
+	})
+
+	t.Run("problems", func(t *testing.T) {
+		api := newTestAPI(t)
+		setUpProblems(t, api)
+		runRequests(t, api, problemRequests, validate(api))
+	})
+
+	t.Run("internal errors", func(t *testing.T) {
+		api := newTestAPI(t)
+		api.db.Close()
+		for _, target := range internalErrorTargets {
+			request := newJSONRequest("GET", target, "")
+			response := httptest.NewRecorder()
+			api.router.ServeHTTP(response, request)
+			if response.Code != http.StatusInternalServerError {
+				t.Fatalf("got status %d for %s, want 500: %s", response.Code, target, response.Body)
+			}
+			validateResponse(t, api, request, response)
+		}
+	})
+}
+
+func TestValidateRequestsRejectsMalformedBodies(t *testing.T) {
+	api := newTestAPI(t)
+	// The handlers decode bodies themselves, so only requests validation let through reach them
+	handler := middleware.RequestID(validateRequests(api.openAPI, api.router)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("%s %s reached the handler", r.Method, r.URL.Path)
+	})))
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		code   string
+	}{
+		{name: "syntax error", target: "/api/subreddits/ingest", body: `{"subreddit": `, code: "invalid_body"},
+		{name: "missing body", target: "/api/subreddits/ingest", code: "missing_body"},
+		{name: "missing property", target: "/api/subreddits/ingest", body: `{"subreddit": {"listing": "top"}}`, code: "invalid_body"},
+		{name: "wrong type", target: "/api/searches/ingest", body: `{"search": {"query": "go", "limit": "ten"}}`, code: "invalid_body"},
+		{name: "out of range", target: "/api/users/ingest", body: `{"user": {"name": "spez", "limit": 500}}`, code: "invalid_body"},
+		{name: "unknown enum value", target: "/api/schedules", body: `{"kind": "channel", "name": "golang"}`, code: "invalid_body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := newJSONRequest("POST", tt.target, tt.body)
+			if tt.body == "" {
+				request.Header.Set("Content-Type", "application/json")
+			}
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, request)
+			assertProblem(t, response, tt.target, http.StatusBadRequest, tt.code)
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/samratjha96/hecate/internal/database"
+	"github.com/samratjha96/hecate/internal/database/postgres"
+)
+
+// openStore connects to the storage backend named by DB_BACKEND: sqlite, the default,
+// keeps its file in DB_DIRECTORY and postgres connects to DATABASE_URL. The postgres
+// backend is experimental and only opened when HECATE_EXPERIMENTAL_POSTGRES is true.
+func openStore() (database.Store, error) {
+	switch backend := os.Getenv("DB_BACKEND"); backend {
+	case "", "sqlite":
+		return database.NewDB()
+	case "postgres":
+		if experimental, _ := strconv.ParseBool(os.Getenv("HECATE_EXPERIMENTAL_POSTGRES")); !experimental {
+			return nil, fmt.Errorf("the postgres backend is experimental and not yet supported, set HECATE_EXPERIMENTAL_POSTGRES=true to use it anyway")
+		}
+		log.Println("Using the experimental postgres backend")
+		return postgres.NewDB()
+	default:
+		return nil, fmt.Errorf("unknown DB_BACKEND %q, want sqlite or postgres", backend)
+	}
+}
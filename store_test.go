@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOpenStoreRefusesUnsupportedBackends(t *testing.T) {
+	tests := []struct {
+		name         string
+		backend      string
+		experimental string
+		want         string
+	}{
+		{name: "postgres without opting in", backend: "postgres", want: "HECATE_EXPERIMENTAL_POSTGRES=true"},
+		{name: "postgres opted out", backend: "postgres", experimental: "false", want: "HECATE_EXPERIMENTAL_POSTGRES=true"},
+		{name: "unknown backend", backend: "mysql", want: `unknown DB_BACKEND "mysql"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DB_BACKEND", tt.backend)
+			t.Setenv("HECATE_EXPERIMENTAL_POSTGRES", tt.experimental)
+			db, err := openStore()
+			if err == nil {
+				db.Close()
+				t.Fatal("openStore succeeded, want an error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("openStore error %q does not mention %q", err, tt.want)
+			}
+		})
+	}
+}